@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mfridman/rewrap/wrap"
+)
+
+// explanation is the report printed by "rewrap explain": wrap.Explanation, with the settings that
+// come from outside the file.
+type explanation struct {
+	File     string `json:"file"`
+	Config   string `json:"config,omitempty"`    // path of the .rewrap.toml that applies, if any
+	PathRule string `json:"path_rule,omitempty"` // pattern of the [[paths]] entry that applies, if any
+	Excluded bool   `json:"excluded,omitempty"`  // the file is excluded by the config, and never rewrapped
+	wrap.Explanation
+}
+
+// explain prints, as a line of JSON, what rewrap does with the line of a file given by loc, such
+// as "main.go:123", and why.
+func explain(w io.Writer, loc, langOverride string, opts wrap.Options) error {
+	i := strings.LastIndexByte(loc, ':')
+	line, err := strconv.Atoi(loc[i+1:])
+	if i < 0 || err != nil || line < 1 {
+		return fmt.Errorf("invalid location %q: must be file:line", loc)
+	}
+	file := loc[:i]
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", file, err)
+	}
+	cfg, err := wrap.FindConfig(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	lang, err := resolveLanguage(file, src, langOverride, cfg)
+	if err != nil {
+		return err
+	}
+	if opts, err = wrap.ResolveOptions(file, src, lang, cfg, opts); err != nil {
+		return err
+	}
+	e, ok := wrap.Explain(src, lang, opts, line)
+	if !ok {
+		return fmt.Errorf("%s has no line %d", file, line)
+	}
+	r := explanation{File: file, Excluded: cfg.Excluded(file), Explanation: e}
+	if cfg != nil {
+		r.Config = cfg.Path
+	}
+	if rule := cfg.PathRule(file); rule != nil {
+		r.PathRule = rule.Match
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
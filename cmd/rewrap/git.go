@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mfridman/rewrap/wrap"
+)
+
+// refValue is the value of the --changed flag, a git ref. It can be given without a value, like a
+// bool flag, to compare with HEAD.
+type refValue struct {
+	ref string
+}
+
+func (f *refValue) String() string   { return f.ref }
+func (f *refValue) Get() any         { return f.ref }
+func (f *refValue) IsBoolFlag() bool { return true }
+
+func (f *refValue) Set(s string) error {
+	switch s {
+	case "true":
+		f.ref = "HEAD"
+	case "false":
+		f.ref = ""
+	case "":
+		return fmt.Errorf("empty git ref")
+	default:
+		f.ref = s
+	}
+	return nil
+}
+
+// hunkHeader matches a unified diff hunk header. Group 1 is the first line of the hunk in the new
+// file, and group 2 its number of lines, if not 1.
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// changedLines returns the lines of file that differ from the git revision ref, including
+// uncommitted changes. A file that git does not track has changed as a whole. A ref that starts
+// with "-" is an error.
+func changedLines(ctx context.Context, file, ref string) ([]wrap.LineRange, error) {
+	if strings.HasPrefix(ref, "-") {
+		// git would take it for an option.
+		return nil, fmt.Errorf("invalid git revision %q", ref)
+	}
+	dir, base := filepath.Split(file)
+	if dir == "" {
+		dir = "."
+	}
+	tracked, err := git(ctx, dir, "ls-files", "--", base)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(tracked)) == 0 {
+		return []wrap.LineRange{{Start: 1, End: math.MaxInt}}, nil
+	}
+	out, err := git(ctx, dir, "diff", "--no-color", "--no-ext-diff", "-U0", ref, "--", base)
+	if err != nil {
+		return nil, err
+	}
+	return parseHunks(out), nil
+}
+
+// parseHunks returns the new-file line ranges of the hunks in a unified diff with no context lines.
+// A hunk that only deletes lines covers the lines on either side of the deletion, so that a comment
+// that lost a line is still rewrapped.
+func parseHunks(diff []byte) []wrap.LineRange {
+	var ranges []wrap.LineRange
+	for line := range strings.SplitSeq(string(diff), "\n") {
+		m := hunkHeader.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		start, _ := strconv.Atoi(m[1])
+		count := 1
+		if m[2] != "" {
+			count, _ = strconv.Atoi(m[2])
+		}
+		if count == 0 {
+			ranges = append(ranges, wrap.LineRange{Start: max(start, 1), End: start + 1})
+			continue
+		}
+		ranges = append(ranges, wrap.LineRange{Start: start, End: start + count - 1})
+	}
+	return ranges
+}
+
+// gitFiles returns the files below dir that git does not ignore, tracked or not, as paths joined to
+// dir and cleaned. It returns false if dir is not in a git work tree, or git cannot be run.
+func gitFiles(ctx context.Context, dir string) (map[string]bool, bool) {
+	if !inGitWorkTree(dir) {
+		return nil, false
+	}
+	out, err := git(ctx, dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, false
+	}
+	files := make(map[string]bool)
+	for name := range strings.SplitSeq(string(out), "\x00") {
+		if name != "" {
+			files[filepath.Join(dir, filepath.FromSlash(name))] = true
+		}
+	}
+	return files, true
+}
+
+// inGitWorkTree reports whether dir, or one of its parents, has a .git directory or file, the root
+// of a git work tree.
+func inGitWorkTree(dir string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// git runs git with args in dir and returns its standard output.
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mfridman/rewrap/wrap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHunks(t *testing.T) {
+	t.Parallel()
+
+	diff := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -3 +3 @@ package a
+-// old
++// new
+@@ -10,0 +11,2 @@ func f() {
++// added
++// lines
+@@ -20,2 +21,0 @@
+-// removed
+-// lines
+`
+	assert.Equal(t, []wrap.LineRange{{Start: 3, End: 3}, {Start: 11, End: 12}, {Start: 21, End: 22}}, parseHunks([]byte(diff)))
+	assert.Empty(t, parseHunks(nil))
+}
+
+func TestChanged(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := t.TempDir()
+	ctx := context.Background()
+	gitIn := func(args ...string) {
+		t.Helper()
+		_, err := git(ctx, dir, append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		require.NoError(t, err)
+	}
+	long := "// " + strings.Repeat("word ", 10) + "\n"
+	file := filepath.Join(dir, "a.go")
+	require.NoError(t, os.WriteFile(file, []byte(long+"var a = 1\n\n"+long+"var b = 2\n"), 0o644))
+	gitIn("init", "-q")
+	gitIn("add", "a.go")
+	gitIn("commit", "-q", "-m", "initial")
+
+	ranges, err := changedLines(ctx, file, "HEAD")
+	require.NoError(t, err)
+	assert.Empty(t, ranges)
+	out, err := run(t, "", "--changed", "-c", "30", file)
+	require.NoError(t, err)
+	assert.Equal(t, long+"var a = 1\n\n"+long+"var b = 2\n", out)
+
+	// Only the edited comment is rewrapped.
+	edited := "// " + strings.Repeat("word ", 11) + "\n"
+	require.NoError(t, os.WriteFile(file, []byte(long+"var a = 1\n\n"+edited+"var b = 2\n"), 0o644))
+	out, err = run(t, "", "--changed=HEAD", "-c", "30", file)
+	require.NoError(t, err)
+	assert.Equal(t, long+"var a = 1\n\n// word word word word word\n// word word word word word\n// word\nvar b = 2\n", out)
+
+	// Untracked files have changed as a whole.
+	untracked := filepath.Join(dir, "b.go")
+	require.NoError(t, os.WriteFile(untracked, []byte(long), 0o644))
+	ranges, err = changedLines(ctx, untracked, "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, []wrap.LineRange{{Start: 1, End: math.MaxInt}}, ranges)
+
+	_, err = run(t, "", "--changed=no-such-ref", file)
+	assert.ErrorContains(t, err, "git diff")
+	_, err = run(t, "", "--changed=--output=x", file)
+	assert.ErrorContains(t, err, `invalid git revision "--output=x"`)
+	assert.NoFileExists(t, filepath.Join(dir, "x"))
+}
+
+func TestGitFiles(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		".gitignore":   "gen/\n*.out.go\n",
+		"a.go":         "// a\n",
+		"gen/b.go":     "// b\n",
+		"sub/c.go":     "// c\n",
+		"sub/d.out.go": "// d\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+	_, err := git(context.Background(), dir, "init", "-q")
+	require.NoError(t, err)
+
+	// Files git ignores are skipped, whether or not they are tracked yet.
+	got, err := expandGlobs([]string{dir}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, ".gitignore"), filepath.Join(dir, "a.go"), filepath.Join(dir, "sub", "c.go")}, got)
+	got, err = expandGlobs([]string{filepath.Join(dir, "sub", "...")}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "sub", "c.go")}, got)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// windows reports whether paths and patterns follow Windows rules: both separators, drive letters
+// and UNC prefixes, and names that match whatever their case.
+var windows = runtime.GOOS == "windows"
+
+// globMeta are the characters that make a path element a pattern.
+const globMeta = "*?["
+
+// expandGlobs returns the files named by args. A directory, "dir/..." or "..." names the files below
+// it that rewrap knows (see walkFiles). A pattern is matched element by element against the paths
+// below the directory it starts with, where "**" matches any number of directories; only files
+// match. A literal path is returned as it is, whether or not it exists. Files in excludeDirs are
+// skipped.
+func expandGlobs(args []string, excludeDirs []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		// cmd.exe passes single quotes through, so a pattern quoted for a Unix shell, such as
+		// '**/*.go', arrives with them.
+		if len(arg) > 2 && arg[0] == '\'' && arg[len(arg)-1] == '\'' && hasGlobMeta(arg) {
+			arg = arg[1 : len(arg)-1]
+		}
+		// Go-style recursive shorthand: "dir/..." or just "...". A directory is the same as
+		// "dir/...".
+		root, recursive := "", false
+		if arg == "..." || strings.HasSuffix(toSlash(arg), "/...") {
+			root = strings.TrimSuffix(arg, "...")
+			root = strings.TrimRight(root, "/"+string(filepath.Separator))
+			recursive = true
+		} else if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			root, recursive = arg, true
+		}
+		if recursive {
+			if root == "" {
+				root = "."
+			}
+			matches, err := walkFiles(root, excludeDirs)
+			if err != nil {
+				return nil, fmt.Errorf("walk %s: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("pattern %q matched no files", arg)
+			}
+			files = append(files, matches...)
+			continue
+		}
+		if !hasGlobMeta(arg) {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := globFiles(arg, excludeDirs)
+		if err != nil {
+			return nil, fmt.Errorf("glob %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern %q matched no files", arg)
+		}
+		files = append(files, matches...)
+	}
+	return dedupe(files), nil
+}
+
+// hasGlobMeta reports whether arg has pattern characters, other than in a Windows volume such as
+// "\\?\C:".
+func hasGlobMeta(arg string) bool {
+	if windows {
+		arg = arg[len(windowsVolume(arg)):]
+	}
+	return strings.ContainsAny(arg, globMeta)
+}
+
+// globFiles returns the files matching the pattern arg, in lexical order. Directories in
+// excludeDirs are not entered, and, for a pattern without "**", files below them are skipped even
+// when the directory is above where the pattern starts.
+func globFiles(arg string, excludeDirs []string) ([]string, error) {
+	root, pattern := splitGlob(arg, windows)
+	elems := strings.Split(pattern, "/")
+	recursive := slices.Contains(elems, "**")
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if isExcludedDir(p, excludeDirs) {
+				return filepath.SkipDir
+			}
+			// Without "**", a match is exactly as deep as the pattern, so deeper directories, and
+			// those that do not match their element, hold none.
+			if rel != "." && !recursive {
+				relElems := strings.Split(rel, "/")
+				if len(relElems) >= len(elems) || !matchGlob(elems[:len(relElems)], relElems, windows) {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !matchGlob(elems, strings.Split(rel, "/"), windows) {
+			return nil
+		}
+		if !recursive && containsExcludedDir(p, excludeDirs) {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	return files, err
+}
+
+// splitGlob splits the pattern arg into the directory to walk, the elements of arg before the
+// first with pattern characters, and the rest of arg, with forward slashes. The directory is "."
+// if arg starts with a pattern. On Windows, either separator divides elements, and a drive letter
+// ("C:") or UNC prefix ("\\server\share") is kept in the directory, which uses backslashes.
+// Elsewhere, a backslash escapes the character after it, as in filepath.Match.
+func splitGlob(arg string, windows bool) (root, pattern string) {
+	vol := ""
+	if windows {
+		vol = windowsVolume(arg)
+		arg = toSlash(arg)
+	}
+	rest := arg[len(vol):]
+	elems := strings.Split(rest, "/")
+	i := slices.IndexFunc(elems, func(e string) bool { return strings.ContainsAny(e, globMeta) })
+	if i < 0 {
+		i = len(elems)
+	}
+	root = vol + strings.Join(elems[:i], "/")
+	if i == 1 && elems[0] == "" {
+		root = vol + "/" // the root of the file system or drive
+	}
+	if root == "" {
+		root = "."
+	}
+	if windows {
+		root = strings.ReplaceAll(root, "/", `\`)
+	}
+	return root, strings.Join(elems[i:], "/")
+}
+
+// windowsVolume returns the drive letter ("C:") or UNC prefix ("\\server\share", or "\\?\C:" for a
+// long path) that starts the Windows path p, or "".
+func windowsVolume(p string) string {
+	if len(p) >= 2 && p[1] == ':' && ('a' <= p[0]|0x20 && p[0]|0x20 <= 'z') {
+		return p[:2]
+	}
+	s := toSlash(p)
+	if !strings.HasPrefix(s, "//") {
+		return ""
+	}
+	host, rest, ok := strings.Cut(s[2:], "/")
+	if !ok || host == "" {
+		return ""
+	}
+	share, _, _ := strings.Cut(rest, "/")
+	if share == "" {
+		return ""
+	}
+	return p[:2+len(host)+1+len(share)]
+}
+
+// matchGlob reports whether the elements of a slash-separated relative path match the pattern
+// elements, where "**" matches any number of elements. With fold, as on Windows, case is ignored.
+func matchGlob(pattern, elems []string, fold bool) bool {
+	if len(pattern) == 0 {
+		return len(elems) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elems); i++ {
+			if matchGlob(pattern[1:], elems[i:], fold) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	p, e := pattern[0], elems[0]
+	if fold {
+		p, e = strings.ToLower(p), strings.ToLower(e)
+	}
+	if ok, _ := path.Match(p, e); !ok {
+		return false
+	}
+	return matchGlob(pattern[1:], elems[1:], fold)
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -277,6 +279,20 @@ func TestExpandGlobs(t *testing.T) {
 		require.Equal(t, []string{filepath.Join(root, "a.go")}, got)
 	})
 
+	t.Run("exclude_path_either_separator", func(t *testing.T) {
+		t.Parallel()
+		root := setup(t)
+		for _, exclude := range []string{"sub/deep", `sub\deep`} {
+			got, err := expandGlobs([]string{root + string(filepath.Separator) + "**/*.go"}, []string{toSlash(exclude)})
+			require.NoError(t, err)
+			want := []string{
+				filepath.Join(root, "a.go"),
+				filepath.Join(root, "sub", "c.go"),
+			}
+			require.ElementsMatch(t, want, got, exclude)
+		}
+	})
+
 	t.Run("recursive_shorthand_all_known_files", func(t *testing.T) {
 		t.Parallel()
 		root := setup(t)
@@ -338,6 +354,83 @@ func TestExpandGlobs(t *testing.T) {
 		require.ElementsMatch(t, want, got)
 	})
 
+	t.Run("overlapping_patterns_deduplicated", func(t *testing.T) {
+		t.Parallel()
+		root := setup(t)
+		got, err := expandGlobs([]string{
+			filepath.Join(root, "sub", "*.go"),
+			root + string(filepath.Separator) + "**/*.go",
+			strings.Join([]string{root, "sub", ".", "c.go"}, string(filepath.Separator)),
+		}, nil)
+		require.NoError(t, err)
+		// Each file is kept once, in the order and spelling it was first matched.
+		want := []string{
+			filepath.Join(root, "sub", "c.go"),
+			filepath.Join(root, "a.go"),
+			filepath.Join(root, "sub", "deep", "e.go"),
+		}
+		require.Equal(t, want, got)
+	})
+
+	t.Run("directory_is_recursive", func(t *testing.T) {
+		t.Parallel()
+		root := setup(t)
+		got, err := expandGlobs([]string{filepath.Join(root, "sub")}, nil)
+		require.NoError(t, err)
+		want := []string{
+			filepath.Join(root, "sub", "c.go"),
+			filepath.Join(root, "sub", "deep", "e.go"),
+		}
+		require.ElementsMatch(t, want, got)
+	})
+
+	t.Run("default_excludes", func(t *testing.T) {
+		t.Parallel()
+		root := setup(t)
+		for _, f := range []string{"node_modules/x/a.js", "vendor/v/b.go", ".git/hooks/c.sh"} {
+			path := filepath.Join(root, filepath.FromSlash(f))
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			require.NoError(t, os.WriteFile(path, []byte("// x"), 0o644))
+		}
+		got, err := expandGlobs([]string{root}, nil)
+		require.NoError(t, err)
+		want := []string{
+			filepath.Join(root, "a.go"),
+			filepath.Join(root, "sub", "c.go"),
+			filepath.Join(root, "sub", "deep", "e.go"),
+		}
+		require.ElementsMatch(t, want, got)
+
+		// Unless the walk starts there.
+		got, err = expandGlobs([]string{filepath.Join(root, "vendor", "...")}, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "vendor", "v", "b.go")}, got)
+	})
+
+	t.Run("recursive_glob_directory_after", func(t *testing.T) {
+		t.Parallel()
+		root := setup(t)
+		got, err := expandGlobs([]string{root + string(filepath.Separator) + "**/deep/*.go"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "sub", "deep", "e.go")}, got)
+	})
+
+	t.Run("glob_in_directory", func(t *testing.T) {
+		t.Parallel()
+		root := setup(t)
+		got, err := expandGlobs([]string{root + string(filepath.Separator) + "s*/*.go"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "sub", "c.go")}, got)
+	})
+
+	t.Run("single_quoted_pattern", func(t *testing.T) {
+		t.Parallel()
+		root := setup(t)
+		got, err := expandGlobs([]string{"'" + filepath.Join(root, "*.go") + "'"}, nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{filepath.Join(root, "a.go")}, got)
+	})
+
 	t.Run("recursive_shorthand_no_recognized_files", func(t *testing.T) {
 		t.Parallel()
 		root := setup(t)
@@ -346,3 +439,54 @@ func TestExpandGlobs(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestSplitGlob(t *testing.T) {
+	tests := []struct {
+		arg         string
+		windows     bool
+		wantRoot    string
+		wantPattern string
+	}{
+		{"**/*.go", false, ".", "**/*.go"},
+		{"src/**/*.go", false, "src", "**/*.go"},
+		{"/src/*/x/*.go", false, "/src", "*/x/*.go"},
+		{"/*.go", false, "/", "*.go"},
+		{`src\*.go`, false, ".", `src\*.go`},
+		{`src\**\*.go`, true, "src", "**/*.go"},
+		{`C:\src\**\*.go`, true, `C:\src`, "**/*.go"},
+		{`C:\*.go`, true, `C:\`, "*.go"},
+		{`C:*.go`, true, "C:", "*.go"},
+		{`c:/src/*.go`, true, `c:\src`, "*.go"},
+		{`\\server\share\docs\**\*.md`, true, `\\server\share\docs`, "**/*.md"},
+		{`\\server\share\*.md`, true, `\\server\share\`, "*.md"},
+		{`\\?\C:\src\*.go`, true, `\\?\C:\src`, "*.go"},
+	}
+	for _, tt := range tests {
+		root, pattern := splitGlob(tt.arg, tt.windows)
+		assert.Equal(t, tt.wantRoot, root, tt.arg)
+		assert.Equal(t, tt.wantPattern, pattern, tt.arg)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		fold    bool
+		want    bool
+	}{
+		{"**/*.go", "a.go", false, true},
+		{"**/*.go", "sub/deep/e.go", false, true},
+		{"**", "sub/deep/e.go", false, true},
+		{"**/deep/*.go", "sub/deep/e.go", false, true},
+		{"**/deep/*.go", "sub/e.go", false, false},
+		{"*.go", "sub/c.go", false, false},
+		{"*.GO", "a.go", false, false},
+		{"*.GO", "a.go", true, true},
+		{"SUB/*.go", "sub/c.go", true, true},
+	}
+	for _, tt := range tests {
+		got := matchGlob(strings.Split(tt.pattern, "/"), strings.Split(tt.rel, "/"), tt.fold)
+		assert.Equal(t, tt.want, got, "matchGlob(%q, %q, %v)", tt.pattern, tt.rel, tt.fold)
+	}
+}
@@ -0,0 +1,826 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mfridman/rewrap/internal/diff"
+	"github.com/mfridman/rewrap/internal/lsp"
+	"github.com/mfridman/rewrap/wrap"
+	"github.com/pressly/cli"
+)
+
+func main() {
+	if err := cli.ParseAndRun(context.Background(), newRootCommand(), os.Args[1:], nil); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "rewrap",
+		Usage:   "rewrap [flags] [files...] | rewrap [flags] lsp | rewrap [flags] explain file:line",
+		Summary: "Rewrap comment blocks and text to a specified column width",
+		Description: `Rewrap comment blocks and text to a specified column width.
+
+Examples:
+  rewrap main.go                                 Rewrap a single file (default: 100 cols)
+  rewrap -c 80 main.go                           Override column width
+  rewrap -w main.go                              Write result back to file
+  rewrap 'wrap/*.go'                             Glob: all Go files in wrap/
+  rewrap '**/*.go'                               Recursive glob: all Go files
+  rewrap -w pkg/...                              Recursive: all known files in pkg/
+  rewrap -w .                                    Whole project, skipping what git ignores
+  rewrap -w '**/*.go' --exclude testdata,vendor  Skip directories
+  rewrap --check ./...                           CI: fail if any file needs rewrapping
+  rewrap --check --long-lines ./...              CI: also report code lines over the column
+  rewrap --diff main.go                          Show the changes as a unified diff
+  rewrap --format json main.go                   Report each comment block and why any were skipped
+  rewrap -l ./...                                List files that need rewrapping
+  git ls-files '*.go' | rewrap -w @-             Rewrap the files listed on stdin
+  rewrap -w --changed=main ./...                 Rewrap only comments changed since main
+  cat main.go | rewrap --lang go                 Pipe through stdin
+  pbpaste | rewrap --prefix '> ' -c 72           Rewrap quoted text
+  rewrap --lang go --lines 120:160 < main.go     Rewrap only the comments on lines 120-160
+  rewrap -c 80 lsp                               Run a language server for editors (stdio)
+  rewrap explain main.go:123                     Show how line 123 is treated, and why, as JSON
+
+Defaults for the column, tab width, excluded files and language overrides can be set in a
+.rewrap.toml file, found by walking up from each file's directory. Otherwise the column and tab
+width come from .editorconfig (max_line_length, tab_width, indent_size). Flags take precedence.`,
+		Flags: cli.FlagsFunc(func(f *flag.FlagSet) {
+			f.Int("column", 0, "wrapping column width (default 100; 79 for Python, 80 for Markdown, reStructuredText and R, 98 for Elixir)")
+			f.Bool("write", false, "write result to file instead of stdout")
+			f.String("output", "", "write the result to this file instead of stdout; requires a single input")
+			f.Bool("list", false, "list files whose formatting differs from rewrap's instead of printing them")
+			f.Bool("check", false, "list files that would be rewrapped and exit non-zero if any; write nothing")
+			f.Bool("diff", false, "print a unified diff of the changes instead of the rewrapped content")
+			f.String("format", "text", "output format: text, or json for a line per file with its comment blocks and why any were skipped")
+			f.Var(&rangeValue{}, "lines", "only rewrap comments overlapping lines start:end, counted from 1; can be repeated")
+			f.Var(&refValue{}, "changed", "only rewrap comments on lines changed since a git ref; --changed alone compares with HEAD")
+			f.Bool("long-lines", false, "with --check, also report each line longer than the column, code lines included")
+			f.Int("tab-width", 0, "tab display width for column calculations (default 4)")
+			f.String("lang", "", "override language detection")
+			f.String("prefix", "", "treat input as plain text with this prefix on each line, such as \"> \"")
+			f.Bool("break-long-words", false, "in plain text, rewrap lines that look like data, such as base64, and break words longer than the column")
+			f.Bool("extend-underlines", false, "lengthen the underlines of Markdown setext headings to the width of the heading")
+			f.Bool("format-tables", false, "align the columns of Markdown tables")
+			f.String("front-matter-fields", "", "comma-separated keys of Markdown YAML front matter whose values are rewrapped as prose")
+			f.Bool("verbose", false, "print each file path when writing")
+			f.Bool("verify", false, "rewrap each result a second time and fail if that changes it, which is a bug in rewrap")
+			f.Bool("stats", false, "print a summary of the files processed and the time taken to stderr")
+			f.String("exclude", "", "comma-separated directory names or paths to exclude")
+			f.Bool("normalize-bullets", false, "convert list bullets in comments and Markdown to --bullet")
+			f.String("bullet", "-", "bullet character used by --normalize-bullets")
+			f.Bool("expand-tabs", false, "convert tabs within comment text to spaces")
+			f.Bool("skip-tests", false, "skip Go test files (_test.go)")
+			f.Bool("preserve-sentence-starts", false, "keep line breaks that do not clearly continue a sentence")
+			f.Bool("keep-narrow", false, "keep comment blocks already wrapped at a narrower column at that column")
+			f.Bool("mixed-indent", false, "join comment lines whose indentation differs only in tabs and spaces into one block")
+			f.Bool("field-comments", false, "in Go, move long comments after struct fields and constants above them to rewrap them")
+			f.Int("min-lines", 0, "leave comment blocks with fewer lines than this alone unless a line is too long")
+			f.Int("jobs", 0, "number of files to process in parallel (default GOMAXPROCS)")
+		}),
+		FlagConfigs: []cli.FlagConfig{
+			{Name: "column", Short: "c"},
+			{Name: "write", Short: "w"},
+			{Name: "output", Short: "o"},
+			{Name: "list", Short: "l"},
+			{Name: "verbose", Short: "v"},
+			{Name: "jobs", Short: "j"},
+		},
+		Exec: execRoot,
+	}
+}
+
+func execRoot(ctx context.Context, s *cli.State) error {
+	write := cli.GetFlag[bool](s, "write")
+	check := cli.GetFlag[bool](s, "check")
+	showDiff := cli.GetFlag[bool](s, "diff")
+	list := cli.GetFlag[bool](s, "list")
+	output := cli.GetFlag[string](s, "output")
+	var jsonOut bool
+	switch format := cli.GetFlag[string](s, "format"); format {
+	case "text":
+	case "json":
+		jsonOut = true
+	default:
+		return fmt.Errorf("invalid format %q: must be text or json", format)
+	}
+	// Only print the rewrapped content when no other output was asked for.
+	printResult := !write && !check && !showDiff && !list && output == "" && !jsonOut
+	if write && (check || showDiff) {
+		return fmt.Errorf("--write cannot be used with --check or --diff")
+	}
+	if output != "" && (write || check || showDiff || list) {
+		return fmt.Errorf("--output cannot be used with --write, --check, --diff or --list")
+	}
+	longLines := cli.GetFlag[bool](s, "long-lines")
+	if longLines && !check {
+		return fmt.Errorf("--long-lines requires --check")
+	}
+	if jsonOut && (output != "" || longLines) {
+		return fmt.Errorf("--format json cannot be used with --output or --long-lines")
+	}
+	changedRef := cli.GetFlag[string](s, "changed")
+	verbose := cli.GetFlag[bool](s, "verbose")
+	showStats := cli.GetFlag[bool](s, "stats")
+	verifyResult := cli.GetFlag[bool](s, "verify")
+	start := time.Now()
+	skipTests := cli.GetFlag[bool](s, "skip-tests")
+	langOverride := cli.GetFlag[string](s, "lang")
+	// Column and tab width are left at zero when not set by flags, so that modelines,
+	// .rewrap.toml, .editorconfig and the defaults can fill them in; see wrap.ResolveOptions.
+	opts := wrap.Options{
+		Column:                 cli.GetFlag[int](s, "column"),
+		TabWidth:               cli.GetFlag[int](s, "tab-width"),
+		ExpandTabs:             cli.GetFlag[bool](s, "expand-tabs"),
+		KeepNarrow:             cli.GetFlag[bool](s, "keep-narrow"),
+		MinLines:               cli.GetFlag[int](s, "min-lines"),
+		MixedIndent:            cli.GetFlag[bool](s, "mixed-indent"),
+		FieldComments:          cli.GetFlag[bool](s, "field-comments"),
+		PreserveSentenceStarts: cli.GetFlag[bool](s, "preserve-sentence-starts"),
+		Lines:                  cli.GetFlag[[]wrap.LineRange](s, "lines"),
+		Prefix:                 cli.GetFlag[string](s, "prefix"),
+		BreakLongWords:         cli.GetFlag[bool](s, "break-long-words"),
+		FormatTables:           cli.GetFlag[bool](s, "format-tables"),
+		ExtendUnderlines:       cli.GetFlag[bool](s, "extend-underlines"),
+	}
+	for field := range strings.SplitSeq(cli.GetFlag[string](s, "front-matter-fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			opts.FrontMatterFields = append(opts.FrontMatterFields, field)
+		}
+	}
+	if opts.Prefix != "" {
+		// A prefix only applies to plain text.
+		if langOverride != "" && langOverride != "text" {
+			return fmt.Errorf("--prefix cannot be used with --lang %s", langOverride)
+		}
+		langOverride = "text"
+	}
+	if changedRef != "" && len(opts.Lines) > 0 {
+		return fmt.Errorf("--changed cannot be used with --lines")
+	}
+	if opts.Column < 0 || opts.TabWidth < 0 {
+		return fmt.Errorf("column and tab width must be positive")
+	}
+	if opts.MinLines < 0 {
+		return fmt.Errorf("--min-lines must not be negative")
+	}
+	if cli.GetFlag[bool](s, "normalize-bullets") {
+		bullet := cli.GetFlag[string](s, "bullet")
+		if utf8.RuneCountInString(bullet) != 1 || strings.TrimSpace(bullet) == "" {
+			return fmt.Errorf("invalid bullet %q: must be a single non-space character", bullet)
+		}
+		opts.Bullet = bullet
+	}
+
+	var excludeDirs []string
+	if e := cli.GetFlag[string](s, "exclude"); e != "" {
+		for d := range strings.SplitSeq(e, ",") {
+			// Either separator may be used, so that the same flags work on Windows and elsewhere.
+			if d = strings.Trim(toSlash(strings.TrimSpace(d)), "/"); d != "" {
+				excludeDirs = append(excludeDirs, d)
+			}
+		}
+	}
+
+	if len(s.Args) == 1 && s.Args[0] == "lsp" {
+		// "rewrap lsp" is handled here rather than as a subcommand, which would stop file names
+		// from being passed as arguments. Use "./lsp" for a file named lsp.
+		return lsp.Serve(ctx, s.Stdin, s.Stdout, opts)
+	}
+	if len(s.Args) == 2 && s.Args[0] == "explain" {
+		// Like lsp, and "./explain" is a file named explain.
+		return explain(s.Stdout, s.Args[1], langOverride, opts)
+	}
+
+	files, err := expandArgs(s.Args, excludeDirs, s.Stdin)
+	if err != nil {
+		return err
+	}
+	if output != "" && len(files) > 1 {
+		return fmt.Errorf("--output requires a single input, got %d files", len(files))
+	}
+
+	if len(files) == 0 {
+		if changedRef != "" {
+			return fmt.Errorf("--changed requires files")
+		}
+		cfg, err := wrap.FindConfig(".")
+		if err != nil {
+			return err
+		}
+		// Check if stdin is a pipe.
+		if f, ok := s.Stdin.(*os.File); ok {
+			stat, err := f.Stat()
+			if err != nil {
+				return fmt.Errorf("stat stdin: %w", err)
+			}
+			if (stat.Mode() & os.ModeCharDevice) != 0 {
+				return fmt.Errorf("usage: rewrap [flags] [files...]\n\nUse -help for more information")
+			}
+		}
+		src, err := io.ReadAll(s.Stdin)
+		if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		lang, err := resolveLanguage("", src, langOverride, cfg)
+		if err != nil {
+			return err
+		}
+		stdinOpts, err := wrap.ResolveOptions("stdin", src, lang, cfg, opts)
+		if err != nil {
+			return err
+		}
+		result := wrap.SourceWithOptions(src, lang, stdinOpts)
+		if verifyResult {
+			if err := verify("<stdin>", src, result, lang, stdinOpts); err != nil {
+				return err
+			}
+		}
+		if printResult {
+			_, err = s.Stdout.Write(result)
+			return err
+		}
+		if output != "" {
+			return writeOutput(output, "", result)
+		}
+		if jsonOut {
+			r := fileReport{File: "<stdin>", Changed: !bytes.Equal(src, result), Blocks: wrap.Blocks(src, lang, stdinOpts)}
+			if showDiff {
+				r.Diff = string(diff.Unified("<stdin>", "<stdin>", src, result))
+			}
+			if err := printReport(s.Stdout, r); err != nil {
+				return err
+			}
+			if check && r.Changed {
+				return errors.New("stdin would be rewrapped")
+			}
+			return nil
+		}
+		codeLines := 0
+		if longLines {
+			codeLines = printLongLines(s.Stdout, "<stdin>", wrap.LongLines(src, lang, stdinOpts))
+		}
+		if bytes.Equal(src, result) {
+			if codeLines > 0 {
+				return fmt.Errorf("%d code lines are too long", codeLines)
+			}
+			return nil
+		}
+		if list || (check && !showDiff) {
+			_, _ = fmt.Fprintln(s.Stdout, "<stdin>")
+		}
+		if showDiff {
+			if _, err := s.Stdout.Write(diff.Unified("<stdin>", "<stdin>", src, result)); err != nil {
+				return err
+			}
+		}
+		if check {
+			return errors.New("stdin would be rewrapped")
+		}
+		return nil
+	}
+
+	jobs := cli.GetFlag[int](s, "jobs")
+	if jobs < 0 {
+		return fmt.Errorf("jobs must not be negative")
+	}
+	if jobs == 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+
+	configs := make(map[string]*wrap.Config) // by directory
+	var tasks []*fileTask
+	processed := 0 // tasks that are not excluded
+	stats := runStats{scanned: len(files), skipped: make(map[string]int)}
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		cfg, ok := configs[dir]
+		if !ok {
+			if cfg, err = wrap.FindConfig(dir); err != nil {
+				return err
+			}
+			configs[dir] = cfg
+		}
+		t := &fileTask{file: file, cfg: cfg, done: make(chan struct{})}
+		switch {
+		case cfg.Excluded(file):
+			t.skipped = "excluded by config"
+		case skipTests && strings.HasSuffix(file, "_test.go"):
+			t.skipped = "test file"
+		}
+		if t.skipped != "" {
+			stats.skipped[t.skipped]++
+			if jsonOut {
+				// Reported with the other files, but not read.
+				t.excluded = true
+				close(t.done)
+				tasks = append(tasks, t)
+			}
+			continue
+		}
+		tasks = append(tasks, t)
+		processed++
+	}
+
+	// Files are read, rewrapped and written by a pool of workers. Output is printed here, in the
+	// order the files were given, as each file is done.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	queue := make(chan *fileTask)
+	go func() {
+		defer close(queue)
+		for _, t := range tasks {
+			if t.excluded {
+				continue
+			}
+			select {
+			case queue <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for range min(jobs, processed) {
+		go func() {
+			for t := range queue {
+				t.err = t.process(ctx, taskOptions{
+					lang:       langOverride,
+					opts:       opts,
+					write:      write,
+					longLines:  longLines,
+					changedRef: changedRef,
+					verify:     verifyResult,
+					blocks:     jsonOut,
+				})
+				close(t.done)
+			}
+		}()
+	}
+
+	changed, codeLines := 0, 0
+	for _, t := range tasks {
+		<-t.done
+		if t.err != nil {
+			return t.err
+		}
+		file := t.file
+		if t.excluded {
+			if err := printReport(s.Stdout, fileReport{File: file, Skipped: t.skipped}); err != nil {
+				return err
+			}
+			continue
+		}
+		if t.skipped != "" {
+			stats.skipped[t.skipped]++
+		}
+		codeLines += printLongLines(s.Stdout, file, t.long)
+		if !bytes.Equal(t.src, t.result) {
+			changed++
+			if showStats {
+				stats.blocks += diff.Changes(t.src, t.result)
+			}
+			if (list || (check && !showDiff)) && !jsonOut {
+				_, _ = fmt.Fprintln(s.Stdout, file)
+			}
+			if showDiff && !jsonOut {
+				if _, err := s.Stdout.Write(t.diff()); err != nil {
+					return err
+				}
+			}
+		}
+		if jsonOut {
+			if err := printReport(s.Stdout, t.report(showDiff)); err != nil {
+				return err
+			}
+		}
+		if write {
+			if verbose && !list && !jsonOut {
+				_, _ = fmt.Fprintln(s.Stdout, file)
+			}
+		} else if printResult {
+			if _, err := s.Stdout.Write(t.result); err != nil {
+				return err
+			}
+		} else if output != "" {
+			if err := writeOutput(output, file, t.result); err != nil {
+				return err
+			}
+		}
+	}
+	if showStats {
+		stats.changed = changed
+		stats.elapsed = time.Since(start)
+		stats.slowest = slices.SortedStableFunc(slices.Values(slices.DeleteFunc(slices.Clone(tasks), (*fileTask).isExcluded)), func(a, b *fileTask) int {
+			return cmp.Compare(b.elapsed, a.elapsed)
+		})
+		stats.print(s.Stderr)
+	}
+	switch {
+	case check && changed > 0 && codeLines > 0:
+		return fmt.Errorf("%d of %d files would be rewrapped, and %d code lines are too long", changed, processed, codeLines)
+	case check && changed > 0:
+		return fmt.Errorf("%d of %d files would be rewrapped", changed, processed)
+	case codeLines > 0:
+		return fmt.Errorf("%d code lines are too long", codeLines)
+	}
+	return nil
+}
+
+// printLongLines prints a line for each of long, saying whether rewrap can fix it, and returns the
+// number of code lines, which cannot be fixed by rewrap.
+func printLongLines(w io.Writer, name string, long []wrap.LongLine) int {
+	code := 0
+	for _, l := range long {
+		if l.Text {
+			_, _ = fmt.Fprintf(w, "%s:%d: text line is %d columns, longer than %d (fixable with rewrap)\n", name, l.Line, l.Width, l.Column)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s:%d: code line is %d columns, longer than %d (fix by hand)\n", name, l.Line, l.Width, l.Column)
+			code++
+		}
+	}
+	return code
+}
+
+// runStats is the summary of a run printed by --stats.
+type runStats struct {
+	scanned int            // files named by the arguments
+	changed int            // files rewrapped, or that would be
+	blocks  int            // separate runs of rewrapped lines, roughly comment blocks
+	skipped map[string]int // files not rewrapped, by reason
+	slowest []*fileTask    // processed files, slowest first
+	elapsed time.Duration  // wall time of the whole run
+}
+
+// slowestFiles is the number of files listed by runStats.print.
+const slowestFiles = 5
+
+func (st runStats) print(w io.Writer) {
+	skipped := 0
+	var reasons []string
+	for _, reason := range slices.Sorted(maps.Keys(st.skipped)) {
+		skipped += st.skipped[reason]
+		reasons = append(reasons, fmt.Sprintf("%d %s", st.skipped[reason], reason))
+	}
+	_, _ = fmt.Fprintf(w, "files:   %d scanned, %d changed, %d skipped", st.scanned, st.changed, skipped)
+	if len(reasons) > 0 {
+		_, _ = fmt.Fprintf(w, " (%s)", strings.Join(reasons, ", "))
+	}
+	_, _ = fmt.Fprintf(w, "\nblocks:  %d rewrapped\ntime:    %v\n", st.blocks, st.elapsed.Round(100*time.Microsecond))
+	if len(st.slowest) > 0 {
+		var slowest []string
+		for _, t := range st.slowest[:min(slowestFiles, len(st.slowest))] {
+			slowest = append(slowest, fmt.Sprintf("%s (%v)", t.file, t.elapsed.Round(100*time.Microsecond)))
+		}
+		_, _ = fmt.Fprintf(w, "slowest: %s\n", strings.Join(slowest, ", "))
+	}
+}
+
+// fileTask is a file to be processed by a worker. The other fields are set by process before
+// the worker closes done.
+type fileTask struct {
+	file string
+	cfg  *wrap.Config
+	done chan struct{}
+
+	src, result []byte
+	long        []wrap.LongLine // set if long lines are reported
+	blocks      []wrap.Block    // set for --format json
+	skipped     string          // why the file was not rewrapped, if it was skipped
+	excluded    bool            // the file is skipped without being read, for skipped
+	elapsed     time.Duration
+	err         error
+}
+
+// taskOptions are the settings shared by all fileTasks.
+type taskOptions struct {
+	lang       string       // --lang, if set
+	opts       wrap.Options // before applying .rewrap.toml and .editorconfig
+	write      bool         // write the result back to the file
+	longLines  bool         // find the long lines of the original
+	changedRef string       // if set, only rewrap lines changed since this git ref
+	verify     bool         // check that rewrapping the result changes nothing
+	blocks     bool         // report the comment blocks of the file
+}
+
+func (t *fileTask) isExcluded() bool { return t.excluded }
+
+// report returns the --format json output for t, with the diff of the changes if showDiff is set.
+func (t *fileTask) report(showDiff bool) fileReport {
+	r := fileReport{File: t.file, Changed: !bytes.Equal(t.src, t.result), Skipped: t.skipped, Blocks: t.blocks}
+	if showDiff && r.Changed {
+		r.Diff = string(t.diff())
+	}
+	return r
+}
+
+// diff returns the unified diff of the changes to t. It uses git's a/ and b/ prefixes, so that the
+// diff applies with "git apply" or "patch -p1"; absolute paths are left as they are.
+func (t *fileTask) diff() []byte {
+	oldName, newName := filepath.ToSlash(t.file), filepath.ToSlash(t.file)
+	if !filepath.IsAbs(t.file) {
+		oldName, newName = "a/"+oldName, "b/"+newName
+	}
+	return diff.Unified(oldName, newName, t.src, t.result)
+}
+
+// fileReport is the line printed for a file by --format json.
+type fileReport struct {
+	File    string       `json:"file"`
+	Changed bool         `json:"changed"`           // the file is rewrapped, or would be
+	Skipped string       `json:"skipped,omitempty"` // why the file was not rewrapped, if it was skipped
+	Blocks  []wrap.Block `json:"blocks,omitempty"`  // see wrap.Blocks
+	Diff    string       `json:"diff,omitempty"`    // with --diff
+}
+
+// printReport prints r to w as a line of JSON.
+func printReport(w io.Writer, r fileReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
+
+// process reads and rewraps the file as set by o.
+func (t *fileTask) process(ctx context.Context, o taskOptions) error {
+	start := time.Now()
+	defer func() { t.elapsed = time.Since(start) }()
+	src, err := os.ReadFile(t.file)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", t.file, err)
+	}
+	lang, err := resolveLanguage(t.file, src, o.lang, t.cfg)
+	if err != nil {
+		return err
+	}
+	opts, err := wrap.ResolveOptions(t.file, src, lang, t.cfg, o.opts)
+	if err != nil {
+		return err
+	}
+	if o.changedRef != "" {
+		if opts.Lines, err = changedLines(ctx, t.file, o.changedRef); err != nil {
+			return fmt.Errorf("%s: %w", t.file, err)
+		}
+		if len(opts.Lines) == 0 {
+			t.src, t.result = src, src
+			t.skipped = "no changed lines"
+			return nil
+		}
+	}
+	t.src, t.result = src, wrap.SourceWithOptions(src, lang, opts)
+	if o.blocks {
+		t.blocks = wrap.Blocks(src, lang, opts)
+	}
+	if o.verify {
+		if err := verify(t.file, t.src, t.result, lang, opts); err != nil {
+			return err
+		}
+	}
+	if o.longLines {
+		t.long = wrap.LongLines(src, lang, opts)
+	}
+	if o.write {
+		info, err := os.Stat(t.file)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", t.file, err)
+		}
+		if err := os.WriteFile(t.file, t.result, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("write %s: %w", t.file, err)
+		}
+	}
+	return nil
+}
+
+// writeOutput writes result, the rewrapped input, to the file name for --output. The file gets the
+// permissions of input, if it is a file, and may be input itself, which has already been read.
+func writeOutput(name, input string, result []byte) error {
+	perm := os.FileMode(0o644)
+	if input != "" {
+		info, err := os.Stat(input)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", input, err)
+		}
+		perm = info.Mode().Perm()
+	}
+	if err := os.WriteFile(name, result, perm); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+// verify rewraps result, the rewrapped src, again with opts, and returns an error if that changes
+// it. With opts.Lines, only the lines that were rewrapped the first time are rewrapped again, since
+// the others may never have been wrapped.
+func verify(name string, src, result []byte, lang *wrap.Language, opts wrap.Options) error {
+	if len(opts.Lines) > 0 {
+		opts.Lines = nil
+		for _, h := range diff.Hunks(strings.SplitAfter(string(src), "\n"), strings.SplitAfter(string(result), "\n")) {
+			if h.B1 > h.B0 {
+				opts.Lines = append(opts.Lines, wrap.LineRange{Start: h.B0 + 1, End: h.B1})
+			}
+		}
+		if len(opts.Lines) == 0 {
+			return nil
+		}
+	}
+	if edits := wrap.Diff(result, lang, opts); len(edits) > 0 {
+		return fmt.Errorf("verify %s: rewrapping the result again changes line %d; this is a bug in rewrap", name, edits[0].StartLine+1)
+	}
+	return nil
+}
+
+// expandArgs returns the files named by args, in order. An argument "@file" is replaced by the
+// paths listed in file, one per line, which are taken as they are, without glob expansion; "@-"
+// reads the list from stdin. Other arguments are expanded by expandGlobs. A file named more than
+// once is only returned the first time.
+func expandArgs(args []string, excludeDirs []string, stdin io.Reader) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		name, ok := strings.CutPrefix(arg, "@")
+		if !ok || name == "" {
+			matches, err := expandGlobs([]string{arg}, excludeDirs)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+			continue
+		}
+		var data []byte
+		var err error
+		if name == "-" {
+			data, err = io.ReadAll(stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read file list %s: %w", name, err)
+		}
+		for line := range strings.SplitSeq(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				files = append(files, line)
+			}
+		}
+	}
+	// Report files with the native separator, whichever one they were named with.
+	for i, file := range files {
+		files[i] = filepath.FromSlash(file)
+	}
+	return dedupe(files), nil
+}
+
+// defaultExcludeDirs are the directories that walkFiles never enters, unless one is the root: those
+// of version control systems, and dependencies.
+var defaultExcludeDirs = []string{".git", ".hg", ".svn", ".jj", "node_modules", "vendor"}
+
+// walkFiles returns the files below root in a language rewrap knows, including those defined by
+// the config for their directory, except those in defaultExcludeDirs and in excludeDirs. If root is
+// in a git work tree, files that git ignores (see gitignore) are skipped too.
+func walkFiles(root string, excludeDirs []string) ([]string, error) {
+	tracked, useGit := gitFiles(context.Background(), root)
+	configs := make(map[string]*wrap.Config) // by directory
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if isExcludedDir(path, excludeDirs) || (path != root && slices.Contains(defaultExcludeDirs, d.Name())) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if useGit && !tracked[filepath.Clean(path)] {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		cfg, ok := configs[dir]
+		if !ok {
+			if cfg, err = wrap.FindConfig(dir); err != nil {
+				return err
+			}
+			configs[dir] = cfg
+		}
+		if cfg.DetectLanguage(path, nil) != nil {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// dedupe removes the files that name the same path as an earlier one, such as those matched by
+// overlapping patterns, keeping the first spelling of each.
+func dedupe(files []string) []string {
+	seen := make(map[string]bool, len(files))
+	unique := files[:0]
+	for _, file := range files {
+		key := filepath.Clean(file)
+		if abs, err := filepath.Abs(file); err == nil {
+			key = abs
+		}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, file)
+		}
+	}
+	return unique
+}
+
+// isExcludedDir reports whether the directory path is excluded. An exclude entry is a directory
+// name, such as "vendor", or a slash-separated path, such as "internal/gen", that matches the last
+// elements of path.
+func isExcludedDir(path string, excludeDirs []string) bool {
+	path = "/" + filepath.ToSlash(filepath.Clean(path))
+	for _, d := range excludeDirs {
+		if strings.HasSuffix(path, "/"+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsExcludedDir reports whether path, or one of its parent directories, is excluded.
+func containsExcludedDir(path string, excludeDirs []string) bool {
+	path = filepath.ToSlash(path)
+	for i := range len(path) + 1 {
+		if (i == len(path) || path[i] == '/') && isExcludedDir(path[:i], excludeDirs) {
+			return true
+		}
+	}
+	return false
+}
+
+// toSlash is like filepath.ToSlash, but replaces backslashes on every platform, so that a path or
+// pattern written on Windows is understood everywhere.
+func toSlash(s string) string {
+	return strings.ReplaceAll(s, `\`, "/")
+}
+
+func resolveLanguage(filename string, src []byte, langOverride string, cfg *wrap.Config) (*wrap.Language, error) {
+	if langOverride == "text" {
+		return nil, nil
+	}
+	if langOverride != "" {
+		lang := cfg.LanguageFromName(langOverride)
+		if lang == nil {
+			return nil, fmt.Errorf("unknown language: %s", langOverride)
+		}
+		return lang, nil
+	}
+	if filename != "" {
+		if lang, ok := cfg.Language(filename); ok {
+			return lang, nil
+		}
+		return cfg.DetectLanguage(filename, src), nil
+	}
+	return nil, nil
+}
+
+// rangeValue is the value of the repeatable --lines flag.
+type rangeValue []wrap.LineRange
+
+func (v *rangeValue) Get() any { return []wrap.LineRange(*v) }
+
+func (v *rangeValue) String() string {
+	var b strings.Builder
+	for i, r := range *v {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%d:%d", r.Start, r.End)
+	}
+	return b.String()
+}
+
+func (v *rangeValue) Set(s string) error {
+	start, end, ok := strings.Cut(s, ":")
+	if !ok {
+		end = start
+	}
+	var r wrap.LineRange
+	var err1, err2 error
+	r.Start, err1 = strconv.Atoi(start)
+	r.End, err2 = strconv.Atoi(end)
+	if err1 != nil || err2 != nil || r.Start < 1 || r.End < r.Start {
+		return fmt.Errorf("invalid line range %q: want start:end, with 1 <= start <= end", s)
+	}
+	*v = append(*v, r)
+	return nil
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mfridman/rewrap/wrap"
+	"github.com/pressly/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// run runs the rewrap command with args and returns what it wrote to stdout.
+func run(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout bytes.Buffer
+	err := cli.ParseAndRun(context.Background(), newRootCommand(), args, &cli.RunOptions{
+		Stdin:  strings.NewReader(stdin),
+		Stdout: &stdout,
+		Stderr: &bytes.Buffer{},
+	})
+	return stdout.String(), err
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	wrapped := filepath.Join(dir, "wrapped.go")
+	long := filepath.Join(dir, "long.go")
+	require.NoError(t, os.WriteFile(wrapped, []byte("// Short comment.\npackage a\n"), 0o644))
+	longSrc := "// " + strings.Repeat("word ", 30) + "\npackage a\n"
+	require.NoError(t, os.WriteFile(long, []byte(longSrc), 0o644))
+
+	out, err := run(t, "", "--check", wrapped)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+
+	out, err = run(t, "", "--check", wrapped, long)
+	assert.EqualError(t, err, "1 of 2 files would be rewrapped")
+	assert.Equal(t, long+"\n", out)
+
+	// Nothing is written.
+	got, err := os.ReadFile(long)
+	require.NoError(t, err)
+	assert.Equal(t, longSrc, string(got))
+
+	_, err = run(t, "", "--check", "--write", long)
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "long.go")
+	src := "package a\n\n// one two three four five six\nfunc f() {}\n"
+	require.NoError(t, os.WriteFile(file, []byte(src), 0o644))
+
+	out, err := run(t, "", "--diff", "-c", "20", file)
+	require.NoError(t, err)
+	name := filepath.ToSlash(file)
+	want := "--- " + name + "\n+++ " + name + "\n" +
+		"@@ -1,4 +1,5 @@\n package a\n \n-// one two three four five six\n+// one two three\n+// four five six\n func f() {}\n"
+	assert.Equal(t, want, out)
+
+	// With --check, the diff is printed and the exit status reports the change.
+	out, err = run(t, "", "--diff", "--check", "-c", "20", file)
+	assert.EqualError(t, err, "1 of 1 files would be rewrapped")
+	assert.Equal(t, want, out)
+
+	out, err = run(t, "", "--diff", file)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	wrapped := filepath.Join(dir, "wrapped.go")
+	long := filepath.Join(dir, "long.go")
+	require.NoError(t, os.WriteFile(wrapped, []byte("// Short comment.\npackage a\n"), 0o644))
+	longSrc := "// " + strings.Repeat("word ", 30) + "\npackage a\n"
+	require.NoError(t, os.WriteFile(long, []byte(longSrc), 0o644))
+
+	out, err := run(t, "", "-l", wrapped, long)
+	require.NoError(t, err)
+	assert.Equal(t, long+"\n", out)
+	got, err := os.ReadFile(long)
+	require.NoError(t, err)
+	assert.Equal(t, longSrc, string(got))
+
+	// With -w, the listed files are rewritten, so a second run lists nothing.
+	out, err = run(t, "", "-l", "-w", wrapped, long)
+	require.NoError(t, err)
+	assert.Equal(t, long+"\n", out)
+	out, err = run(t, "", "-l", wrapped, long)
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
+
+func TestJobs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	var files []string
+	for i := range 40 {
+		file := filepath.Join(dir, fmt.Sprintf("f%02d.go", i))
+		src := fmt.Sprintf("// File %d: %s\npackage a\n", i, strings.Repeat("word ", i))
+		require.NoError(t, os.WriteFile(file, []byte(src), 0o644))
+		files = append(files, file)
+	}
+
+	// Output is in argument order whatever the number of workers.
+	serial, err := run(t, "", append([]string{"-j", "1", "-c", "40"}, files...)...)
+	require.NoError(t, err)
+	parallel, err := run(t, "", append([]string{"-j", "8", "-c", "40"}, files...)...)
+	require.NoError(t, err)
+	assert.Equal(t, serial, parallel)
+	assert.True(t, strings.HasPrefix(serial, "// File 0:\npackage a\n// File 1: word\n"), serial)
+
+	_, err = run(t, "", append([]string{"-j", "4", "-l"}, append(files[:3:3], filepath.Join(dir, "missing.go"))...)...)
+	assert.ErrorContains(t, err, "missing.go")
+}
+
+func TestSkipTests(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	longSrc := "// " + strings.Repeat("word ", 30) + "\npackage a\n"
+	for _, name := range []string{"a.go", "a_test.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(longSrc), 0o644))
+	}
+
+	out, err := run(t, "", "-l", filepath.Join(dir, "..."))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "a.go")+"\n"+filepath.Join(dir, "a_test.go")+"\n", out)
+	out, err = run(t, "", "-l", "--skip-tests", filepath.Join(dir, "..."))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "a.go")+"\n", out)
+}
+
+func TestLongLines(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.go")
+	src := "// one two three four five six\npackage a\n\nvar x = \"a long string literal\"\n"
+	require.NoError(t, os.WriteFile(file, []byte(src), 0o644))
+
+	out, err := run(t, "", "--check", "--long-lines", "-c", "25", file)
+	assert.EqualError(t, err, "1 of 1 files would be rewrapped, and 1 code lines are too long")
+	assert.Equal(t, file+":1: text line is 30 columns, longer than 25 (fixable with rewrap)\n"+
+		file+":4: code line is 31 columns, longer than 25 (fix by hand)\n"+
+		file+"\n", out)
+
+	// Code lines alone fail the check.
+	out, err = run(t, "", "--check", "--long-lines", "-c", "30", file)
+	assert.EqualError(t, err, "1 code lines are too long")
+	assert.Equal(t, file+":4: code line is 31 columns, longer than 30 (fix by hand)\n", out)
+
+	_, err = run(t, "", "--long-lines", file)
+	assert.EqualError(t, err, "--long-lines requires --check")
+}
+
+func TestLines(t *testing.T) {
+	t.Parallel()
+
+	long := "// one two three four five six seven\r\n"
+	src := long + "var a = 1\r\n\r\n" + long + "var b = 2\r\n"
+	out, err := run(t, src, "--lang", "go", "-c", "20", "--lines", "4:4")
+	require.NoError(t, err)
+	// Lines outside the range are unchanged, line endings included.
+	assert.Equal(t, long+"var a = 1\r\n\r\n// one two three\r\n// four five six\r\n// seven\r\nvar b = 2\r\n", out)
+
+	out, err = run(t, src, "--lang", "go", "-c", "20", "--lines", "2:3", "--lines", "5")
+	require.NoError(t, err)
+	assert.Equal(t, src, out)
+
+	for _, bad := range []string{"0:3", "5:4", "a:b", ""} {
+		_, err = run(t, src, "--lines", bad)
+		assert.ErrorContains(t, err, "invalid line range", bad)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	t.Parallel()
+
+	out, err := run(t, "> one two three four five six\n>\n> seven\n", "--prefix", "> ", "-c", "16")
+	require.NoError(t, err)
+	assert.Equal(t, "> one two three\n> four five six\n>\n> seven\n", out)
+
+	_, err = run(t, "x\n", "--prefix", "> ", "--lang", "go")
+	assert.EqualError(t, err, "--prefix cannot be used with --lang go")
+}
+
+func TestLSP(t *testing.T) {
+	t.Parallel()
+
+	var in strings.Builder
+	for _, m := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	out, err := run(t, in.String(), "lsp")
+	require.NoError(t, err)
+	assert.Contains(t, out, `"documentRangeFormattingProvider":true`)
+}
+
+func TestArgFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	longSrc := "// " + strings.Repeat("word ", 30) + "\npackage a\n"
+	dash := filepath.Join(dir, "-dash.go")
+	star := filepath.Join(dir, "star*.go")
+	for _, file := range []string{dash, star} {
+		require.NoError(t, os.WriteFile(file, []byte(longSrc), 0o644))
+	}
+	list := filepath.Join(dir, "files.txt")
+	require.NoError(t, os.WriteFile(list, []byte(star+"\n\n"+dash+"\r\n"), 0o644))
+
+	// Listed paths are taken literally, with no glob expansion.
+	out, err := run(t, "", "-l", "@"+list)
+	require.NoError(t, err)
+	assert.Equal(t, star+"\n"+dash+"\n", out)
+
+	out, err = run(t, dash+"\n", "-l", "@-")
+	require.NoError(t, err)
+	assert.Equal(t, dash+"\n", out)
+
+	_, err = run(t, "", "-l", "@"+filepath.Join(dir, "missing.txt"))
+	assert.ErrorContains(t, err, "read file list")
+}
+
+func TestEndOfFlags(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("-x.go", []byte("// "+strings.Repeat("word ", 30)+"\npackage a\n"), 0o644))
+
+	// "--" ends the flags, so that a file name can start with "-".
+	out, err := run(t, "", "-l", "--", "-x.go")
+	require.NoError(t, err)
+	assert.Equal(t, "-x.go\n", out)
+	_, err = run(t, "", "-l", "-x.go")
+	assert.Error(t, err)
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	long := "// " + strings.Repeat("word ", 30) + "\npackage a\n\n// " + strings.Repeat("word ", 30) + "\nvar x = 1\n"
+	for name, src := range map[string]string{
+		"a.go":      long,
+		"b.go":      "// Short.\npackage a\n",
+		"a_test.go": long,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644))
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := cli.ParseAndRun(context.Background(), newRootCommand(),
+		[]string{"-l", "--stats", "--skip-tests", filepath.Join(dir, "...")},
+		&cli.RunOptions{Stdin: strings.NewReader(""), Stdout: &stdout, Stderr: &stderr})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "a.go")+"\n", stdout.String())
+	lines := strings.Split(stderr.String(), "\n")
+	require.Len(t, lines, 5, stderr.String())
+	assert.Equal(t, "files:   3 scanned, 1 changed, 1 skipped (1 test file)", lines[0])
+	assert.Equal(t, "blocks:  2 rewrapped", lines[1])
+	assert.Regexp(t, `^time:    \S+s$`, lines[2])
+	assert.Regexp(t, `^slowest: \S+\.go \(\S+s\), \S+\.go \(\S+s\)$`, lines[3])
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	goLang := wrap.LanguageFromName("go")
+	opts := wrap.Options{Column: 20}
+	long := "// one two three four five six\n"
+	wrapped := "// one two three\n// four five six\n"
+
+	require.NoError(t, verify("a.go", []byte(long), []byte(wrapped), goLang, opts))
+	// A result that would change again, as if rewrap were not idempotent, is an error.
+	err := verify("a.go", []byte(long), []byte(wrapped+"var a = 1\n\n"+long), goLang, opts)
+	assert.EqualError(t, err, "verify a.go: rewrapping the result again changes line 5; this is a bug in rewrap")
+
+	// With Lines, only the lines changed by the first pass are checked.
+	opts.Lines = []wrap.LineRange{{Start: 1, End: 1}}
+	src := long + "var a = 1\n\n" + long
+	require.NoError(t, verify("a.go", []byte(src), []byte(wrapped+"var a = 1\n\n"+long), goLang, opts))
+	err = verify("a.go", []byte(src), []byte("// one two three four\n// five six\nvar a = 1\n\n"+long), goLang, opts)
+	assert.EqualError(t, err, "verify a.go: rewrapping the result again changes line 1; this is a bug in rewrap")
+
+	_, err = run(t, long, "--verify", "--lang", "go", "-c", "20")
+	require.NoError(t, err)
+}
+
+func TestConfigLanguages(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	config := "[language.semi-dsl]\nextensions = [\".sdsl\"]\nline-markers = [\";;\"]\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".rewrap.toml"), []byte(config), 0o644))
+	file := filepath.Join(dir, "a.sdsl")
+	require.NoError(t, os.WriteFile(file, []byte(";; one two three four\n(rule x)\n"), 0o644))
+
+	// The language is registered before "..." is expanded, so the file is found. The config
+	// itself is TOML, and is found too.
+	out, err := run(t, "", "-c", "14", filepath.Join(dir, "..."))
+	require.NoError(t, err)
+	assert.Equal(t, config+";; one two\n;; three four\n(rule x)\n", out)
+
+	// Another directory's config may define the same language differently.
+	other := t.TempDir()
+	otherConfig := "[language.semi-dsl]\nextensions = [\".sdsl\"]\nline-markers = [\"#\"]\n"
+	require.NoError(t, os.WriteFile(filepath.Join(other, ".rewrap.toml"), []byte(otherConfig), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(other, "b.sdsl"), []byte("# one two three four\n"), 0o644))
+	out, err = run(t, "", "-c", "14", file, filepath.Join(other, "b.sdsl"))
+	require.NoError(t, err)
+	assert.Equal(t, ";; one two\n;; three four\n(rule x)\n# one two\n# three four\n", out)
+}
+
+func TestOutput(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "a.go")
+	require.NoError(t, os.WriteFile(in, []byte("// one two three four\npackage a\n"), 0o600))
+	out := filepath.Join(dir, "b.go")
+
+	stdout, err := run(t, "", "-c", "14", "-o", out, in)
+	require.NoError(t, err)
+	assert.Empty(t, stdout)
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "// one two\n// three four\npackage a\n", string(got))
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// The output may be the input itself.
+	_, err = run(t, "", "-c", "14", "--output", in, in)
+	require.NoError(t, err)
+	got, err = os.ReadFile(in)
+	require.NoError(t, err)
+	assert.Equal(t, "// one two\n// three four\npackage a\n", string(got))
+
+	// Stdin works too.
+	_, err = run(t, "# one two three four\n", "-c", "14", "--lang", "python", "-o", out)
+	require.NoError(t, err)
+	got, err = os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "# one two\n# three four\n", string(got))
+
+	_, err = run(t, "", "-o", out, in, out)
+	assert.EqualError(t, err, "--output requires a single input, got 2 files")
+	_, err = run(t, "", "-o", out, "-w", in)
+	assert.EqualError(t, err, "--output cannot be used with --write, --check, --diff or --list")
+}
+
+func TestFormatJSON(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	require.NoError(t, os.WriteFile(a, []byte("// one two three four\npackage a\n\n//go:generate echo\n"), 0o644))
+	b := filepath.Join(dir, "b_test.go")
+	require.NoError(t, os.WriteFile(b, []byte("package a\n"), 0o644))
+
+	out, err := run(t, "", "--format", "json", "--skip-tests", "-c", "14", a, b)
+	require.NoError(t, err)
+	assert.Equal(t, `{"file":"`+a+`","changed":true,"blocks":[{"start":1,"end":1,"changed":true},{"start":4,"end":4,"changed":false,"skipped":"directive"}]}
+{"file":"`+b+`","changed":false,"skipped":"test file"}
+`, out)
+
+	out, err = run(t, "// one two three four\n", "--format", "json", "--diff", "--check", "-c", "14", "--lang", "go")
+	assert.EqualError(t, err, "stdin would be rewrapped")
+	assert.Equal(t, `{"file":"<stdin>","changed":true,"blocks":[{"start":1,"end":1,"changed":true}],"diff":"--- <stdin>\n+++ <stdin>\n@@ -1 +1,2 @@\n-// one two three four\n+// one two\n+// three four\n"}
+`, out)
+
+	_, err = run(t, "", "--format", "yaml", a)
+	assert.EqualError(t, err, `invalid format "yaml": must be text or json`)
+}
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".rewrap.toml"), []byte("[[paths]]\nmatch = \"*.go\"\ncolumn = 72\n"), 0o644))
+	file := filepath.Join(dir, "a.go")
+	require.NoError(t, os.WriteFile(file, []byte("package a\n\n// nolint:errcheck\n"), 0o644))
+
+	out, err := run(t, "", "explain", file+":3")
+	require.NoError(t, err)
+	assert.Equal(t, `{"file":"`+file+`","config":"`+filepath.Join(dir, ".rewrap.toml")+`","path_rule":"*.go","line":3,"language":"go","segment":"comment","start":3,"end":3,"marker":"//","indent":"","column":72,"tab_width":4,"changed":false,"protected":"anchored"}
+`, out)
+
+	_, err = run(t, "", "explain", file+":9")
+	assert.EqualError(t, err, file+" has no line 9")
+	_, err = run(t, "", "explain", file)
+	assert.EqualError(t, err, `invalid location "`+file+`": must be file:line`)
+}
@@ -0,0 +1,367 @@
+// Package diff compares lines of text, producing unified diffs or the runs of changed lines.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+// Unified returns a unified diff of old and new, with oldName and newName in the "---" and "+++"
+// headers. It returns nil if old and new are equal.
+func Unified(oldName, newName string, old, new []byte) []byte {
+	if string(old) == string(new) {
+		return nil
+	}
+	a, b := splitLines(string(old)), splitLines(string(new))
+	ops := edits(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(ops); {
+		// Find the next change, and extend the hunk while changes are close enough together that
+		// their context would overlap.
+		for i < len(ops) && ops[i].kind == equal {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+		start := max(i-context, 0)
+		for i < len(ops) {
+			if ops[i].kind != equal {
+				i++
+				continue
+			}
+			j := i
+			for j < len(ops) && ops[j].kind == equal {
+				j++
+			}
+			if j == len(ops) || j-i > 2*context {
+				break
+			}
+			i = j
+		}
+		end := min(i+context, len(ops))
+		writeHunk(&out, ops[start:end], a, b)
+		i = end
+	}
+	return []byte(out.String())
+}
+
+// Changes returns the number of separate runs of changed lines between old and new, such as the
+// number of comment blocks that were rewrapped.
+func Changes(old, new []byte) int {
+	if string(old) == string(new) {
+		return 0
+	}
+	return len(Hunks(splitLines(string(old)), splitLines(string(new))))
+}
+
+// Hunk is a run of changed lines: lines [A0, A1) of the old text are replaced by lines [B0, B1) of
+// the new text, counting from 0.
+type Hunk struct {
+	A0, A1, B0, B1 int
+}
+
+// Hunks returns the runs of changed lines that turn the lines a into the lines b, in order.
+func Hunks(a, b []string) []Hunk {
+	var hunks []Hunk
+	ops := edits(a, b)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == equal {
+			i++
+			continue
+		}
+		h := Hunk{A0: ops[i].a, B0: ops[i].b}
+		for i < len(ops) && ops[i].kind != equal {
+			i++
+		}
+		if i < len(ops) {
+			h.A1, h.B1 = ops[i].a, ops[i].b
+		} else {
+			h.A1, h.B1 = len(a), len(b)
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// writeHunk writes one hunk of ops to out.
+func writeHunk(out *strings.Builder, ops []op, a, b []string) {
+	aStart, bStart := ops[0].a, ops[0].b
+	aCount, bCount := 0, 0
+	for _, o := range ops {
+		if o.kind != insert {
+			aCount++
+		}
+		if o.kind != delete {
+			bCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, o := range ops {
+		var line string
+		switch o.kind {
+		case equal:
+			line = " " + a[o.a]
+		case delete:
+			line = "-" + a[o.a]
+		case insert:
+			line = "+" + b[o.b]
+		}
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the 0-based start and the count of a hunk's lines as "start,count", with the
+// conventions of diff -u: the count is omitted when it is 1, and an empty range starts at the line
+// before it.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits s into lines, each keeping its "\n" terminator.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+type opKind int
+
+const (
+	equal opKind = iota
+	delete
+	insert
+)
+
+// op is one line of an edit script. a and b are the indexes of the line in the old and new text;
+// for inserts and deletes, the index in the other text is where the line would be.
+type op struct {
+	kind opKind
+	a, b int
+}
+
+// maxTrace bounds the memory used by furthestEdits, counted in ints of the trace kept for
+// backtracking.
+const maxTrace = 1 << 22
+
+// edits returns an edit script turning a into b. Lines that occur exactly once in each of a and b
+// and keep their order anchor the script as equal; the segments between anchors are compared
+// separately by shortestEdits, so the cost follows the size of each change rather than the size of
+// the file.
+func edits(a, b []string) []op {
+	ops := make([]op, 0, len(a)+len(b))
+	a0, b0 := 0, 0
+	for _, anchor := range anchors(a, b) {
+		ops = appendSegment(ops, a, b, a0, anchor.a, b0, anchor.b)
+		ops = append(ops, anchor)
+		a0, b0 = anchor.a+1, anchor.b+1
+	}
+	return appendSegment(ops, a, b, a0, len(a), b0, len(b))
+}
+
+// appendSegment appends the edit script turning a[a0:a1] into b[b0:b1] to ops. Lines common to the
+// start and end of the segment are equal; the rest is compared by shortestEdits.
+func appendSegment(ops []op, a, b []string, a0, a1, b0, b1 int) []op {
+	for a0 < a1 && b0 < b1 && a[a0] == b[b0] {
+		ops = append(ops, op{equal, a0, b0})
+		a0++
+		b0++
+	}
+	suf := 0
+	for suf < a1-a0 && suf < b1-b0 && a[a1-1-suf] == b[b1-1-suf] {
+		suf++
+	}
+	for _, o := range shortestEdits(a[a0:a1-suf], b[b0:b1-suf]) {
+		ops = append(ops, op{o.kind, o.a + a0, o.b + b0})
+	}
+	for i := range suf {
+		ops = append(ops, op{equal, a1 - suf + i, b1 - suf + i})
+	}
+	return ops
+}
+
+// anchors returns the pairs of lines that occur exactly once in a and once in b, keeping the
+// longest run of them that is in the same order in both, as equal ops.
+func anchors(a, b []string) []op {
+	type count struct{ a, b, ai, bi int }
+	counts := make(map[string]*count)
+	for i, line := range a {
+		c := counts[line]
+		if c == nil {
+			c = &count{}
+			counts[line] = c
+		}
+		c.a++
+		c.ai = i
+	}
+	for j, line := range b {
+		if c := counts[line]; c != nil {
+			c.b++
+			c.bi = j
+		}
+	}
+	var pairs []op
+	for _, line := range a {
+		if c := counts[line]; c.a == 1 && c.b == 1 {
+			pairs = append(pairs, op{equal, c.ai, c.bi})
+		}
+	}
+
+	// Keep the longest subsequence of pairs increasing in b, by patience sorting: tails[i] is the
+	// index in pairs of the smallest b ending an increasing run of length i+1.
+	var tails []int
+	prev := make([]int, len(pairs))
+	for i, p := range pairs {
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if pairs[tails[mid]].b < p.b {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		prev[i] = -1
+		if lo > 0 {
+			prev[i] = tails[lo-1]
+		}
+		if lo == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[lo] = i
+		}
+	}
+	if len(tails) == 0 {
+		return nil
+	}
+	run := make([]op, len(tails))
+	for i, j := len(tails)-1, tails[len(tails)-1]; i >= 0; i, j = i-1, prev[j] {
+		run[i] = pairs[j]
+	}
+	return run
+}
+
+// shortestEdits returns an edit script turning a into b, using Myers' O(ND) algorithm. The script
+// is a shortest one unless that would take more than maxTrace memory, such as for large inputs with
+// many differences; then it is built in pieces, each a shortest script up to the furthest point
+// reachable within the limit, so changes far apart still get edits of their own.
+func shortestEdits(a, b []string) []op {
+	var ops []op
+	x0, y0 := 0, 0
+	for x0 < len(a) || y0 < len(b) {
+		script, x, y := furthestEdits(a[x0:], b[y0:])
+		for _, o := range script {
+			ops = append(ops, op{o.kind, o.a + x0, o.b + y0})
+		}
+		x0 += x
+		y0 += y
+	}
+	return ops
+}
+
+// furthestEdits returns a shortest edit script turning a into b, or, if that would take more than
+// maxTrace memory, one turning a[:x] into b[:y] for the furthest point (x, y) it could reach. For
+// backtracking it keeps, for each number of differences d, only the 2d-1 diagonals that step
+// reached, so memory grows with the square of the differences rather than with the length of the
+// input.
+func furthestEdits(a, b []string) ([]op, int, int) {
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		if d*d > maxTrace {
+			// Stop at the furthest point of step d-1 that is within a and b.
+			bestX, bestY := 0, 0
+			for k := -(d - 1); k <= d-1; k += 2 {
+				x := v[offset+k]
+				if y := x - k; x <= n && y <= m && x+y > bestX+bestY {
+					bestX, bestY = x, y
+				}
+			}
+			return backtrack(trace, bestX, bestY), bestX, bestY
+		}
+		// Step d-1 reached diagonals -(d-1) through d-1.
+		if d == 0 {
+			trace = append(trace, nil)
+		} else {
+			trace = append(trace, append([]int(nil), v[offset-d+1:offset+d]...))
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m), n, m
+			}
+		}
+	}
+	return nil, n, m
+}
+
+// backtrack walks the trace recorded by furthestEdits back from (n, m) to build the edit script.
+// trace[d] holds the furthest x reached on diagonals -(d-1) through d-1 after d-1 differences.
+func backtrack(trace [][]int, n, m int) []op {
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d-1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{equal, x, y})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, op{insert, x, y})
+		} else {
+			x--
+			ops = append(ops, op{delete, x, y})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, op{equal, x, y})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,182 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	want := `--- a/x.txt
++++ b/x.txt
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -11,3 +11,4 @@
+ k
+ l
+ m
++n
+`
+	assert.Equal(t, want, string(Unified("a/x.txt", "b/x.txt", []byte(old), []byte(new))))
+	assert.Nil(t, Unified("a", "b", []byte(old), []byte(old)))
+}
+
+func TestUnified_NoNewline(t *testing.T) {
+	t.Parallel()
+
+	want := `--- a
++++ b
+@@ -1 +1,2 @@
+-x
+\ No newline at end of file
++x
++y
+`
+	assert.Equal(t, want, string(Unified("a", "b", []byte("x"), []byte("x\ny\n"))))
+}
+
+func TestUnified_Empty(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n", string(Unified("a", "b", nil, []byte("x\n"))))
+	assert.Equal(t, "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n", string(Unified("a", "b", []byte("x\n"), nil)))
+}
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, Changes([]byte("a\nb\n"), []byte("a\nb\n")))
+	assert.Equal(t, 1, Changes([]byte("a\nb\nc\n"), []byte("a\nB\nC\nD\n")))
+	assert.Equal(t, 2, Changes([]byte("a\nb\nc\nd\n"), []byte("A\nb\nc\nD\n")))
+}
+
+func TestHunks(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, Hunks([]string{"a", "b"}, []string{"a", "b"}))
+	assert.Equal(t, []Hunk{{A0: 1, A1: 2, B0: 1, B1: 3}, {A0: 3, A1: 3, B0: 4, B1: 5}},
+		Hunks([]string{"a", "b", "c"}, []string{"a", "B", "B", "c", "d"}))
+	assert.Equal(t, []Hunk{{A0: 0, A1: 1, B0: 0, B1: 0}}, Hunks([]string{"a", "b"}, []string{"b"}))
+}
+
+func TestHunks_Large(t *testing.T) {
+	t.Parallel()
+
+	// Beyond maxTrace, the differing middle is still turned into b, in pieces.
+	var a, b []string
+	for i := range 3000 {
+		a = append(a, fmt.Sprintf("a%d\n", i))
+		b = append(b, fmt.Sprintf("b%d\n", i))
+	}
+	a = append([]string{"same\n"}, append(a, "end\n")...)
+	b = append([]string{"same\n"}, append(b, "end\n")...)
+	assert.Equal(t, []Hunk{{A0: 1, A1: 3001, B0: 1, B1: 3001}}, Hunks(a, b))
+}
+
+func TestHunks_ManySmallChanges(t *testing.T) {
+	t.Parallel()
+
+	// No line is unique, and there are more differences than maxTrace allows for one shortest edit
+	// script, yet each changed comment is still a change of its own.
+	var a, b []string
+	for range 1800 {
+		a = append(a, "// one two three four\n", "}\n", "\n")
+		b = append(b, "// one two\n", "// three four\n", "}\n", "\n")
+	}
+	hunks := Hunks(a, b)
+	require.Len(t, hunks, 1800)
+	for i, h := range hunks {
+		assert.Equal(t, Hunk{A0: 3 * i, A1: 3*i + 1, B0: 4 * i, B1: 4*i + 2}, h)
+	}
+}
+
+func TestEdits(t *testing.T) {
+	t.Parallel()
+
+	// Replaying the script of random inputs, with repeated and unique lines, rebuilds b from a.
+	rng := rand.New(rand.NewPCG(1, 2))
+	random := func() []string {
+		lines := make([]string, rng.IntN(40))
+		for i := range lines {
+			lines[i] = fmt.Sprint(rng.IntN(30))
+		}
+		return lines
+	}
+	for range 500 {
+		a, b := random(), random()
+		var got []string
+		ai, bi := 0, 0
+		for _, o := range edits(a, b) {
+			switch o.kind {
+			case equal:
+				require.Equal(t, a[o.a], b[o.b])
+				require.Equal(t, [2]int{ai, bi}, [2]int{o.a, o.b})
+				got = append(got, a[o.a])
+				ai++
+				bi++
+			case delete:
+				require.Equal(t, ai, o.a)
+				ai++
+			case insert:
+				require.Equal(t, bi, o.b)
+				got = append(got, b[o.b])
+				bi++
+			}
+		}
+		assert.Equal(t, [2]int{len(a), len(b)}, [2]int{ai, bi})
+		assert.Equal(t, strings.Join(b, " "), strings.Join(got, " "))
+	}
+}
+
+// TestUnified_Patch checks that patch(1) applies the diff of a larger edit.
+func TestUnified_Patch(t *testing.T) {
+	t.Parallel()
+	if _, err := exec.LookPath("patch"); err != nil {
+		t.Skip("patch not found")
+	}
+
+	var old, new []string
+	for i := range 200 {
+		line := strings.Repeat("x", i%7) + string(rune('a'+i%26))
+		old = append(old, line)
+		switch {
+		case i%17 == 0:
+			new = append(new, line+" changed")
+		case i%23 == 0:
+			// dropped
+		case i%31 == 0:
+			new = append(new, line, "inserted")
+		default:
+			new = append(new, line)
+		}
+	}
+	oldSrc := strings.Join(old, "\n") + "\n"
+	newSrc := strings.Join(new, "\n") + "\n"
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f.txt")
+	require.NoError(t, os.WriteFile(path, []byte(oldSrc), 0o644))
+	cmd := exec.Command("patch", "-s", path)
+	cmd.Stdin = strings.NewReader(string(Unified("f.txt", "f.txt", []byte(oldSrc), []byte(newSrc))))
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, newSrc, string(got))
+}
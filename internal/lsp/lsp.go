@@ -0,0 +1,305 @@
+// Package lsp implements a minimal Language Server Protocol server that rewraps comments with
+// textDocument/formatting and textDocument/rangeFormatting.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/mfridman/rewrap/wrap"
+)
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// maxMessageSize bounds the Content-Length of a message, so that a bad header cannot make the
+// server allocate without limit. It leaves room for a document of wrap.DefaultLimits.MaxInputSize
+// bytes escaped as JSON.
+const maxMessageSize = 64 << 20
+
+// Serve reads LSP messages from r and writes responses to w until the client sends "exit" or r is
+// closed. Each document is rewrapped with opts, completed by the .rewrap.toml and .editorconfig
+// files found from the document's path, as on the command line. If opts has no limits,
+// wrap.DefaultLimits are used, since a long-running server should survive any document.
+func Serve(ctx context.Context, r io.Reader, w io.Writer, opts wrap.Options) error {
+	if opts.Limits == (wrap.Limits{}) {
+		opts.Limits = wrap.DefaultLimits
+	}
+	s := &server{
+		in:   textproto.NewReader(bufio.NewReader(r)),
+		out:  w,
+		opts: opts,
+		docs: make(map[string]document),
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		body, err := s.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		msg := new(message)
+		if err := json.Unmarshal(body, msg); err != nil {
+			if err := s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// message is a JSON-RPC request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *responseError  `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type textEdit struct {
+	Range   textRange `json:"range"`
+	NewText string    `json:"newText"`
+}
+
+type textDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Text       string `json:"text"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type formattingParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Range        *textRange             `json:"range"` // rangeFormatting only
+}
+
+// document is an open text document.
+type document struct {
+	languageID string
+	text       string
+}
+
+type server struct {
+	in   *textproto.Reader
+	out  io.Writer
+	opts wrap.Options
+	docs map[string]document // by URI
+}
+
+// read reads the body of the next message, framed by a Content-Length header.
+func (s *server) read() ([]byte, error) {
+	header, err := s.in.ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	if n > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the maximum of %d", n, maxMessageSize)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(s.in.R, body); err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	return body, nil
+}
+
+// reply sends the response to the request with id. Notifications, which have no id, get none.
+func (s *server) reply(id json.RawMessage, result any, rerr *responseError) error {
+	if id == nil && rerr == nil {
+		return nil
+	}
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	resp := message{JSONRPC: "2.0", ID: id, Result: result, Error: rerr}
+	if result == nil && rerr == nil {
+		// A successful response needs a result, even if it is null.
+		resp.Result = json.RawMessage("null")
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *server) handle(msg *message) error {
+	switch msg.Method {
+	case "initialize":
+		return s.reply(msg.ID, map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":                1, // full
+				"documentFormattingProvider":      true,
+				"documentRangeFormattingProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "rewrap"},
+		}, nil)
+	case "shutdown":
+		return s.reply(msg.ID, nil, nil)
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument textDocumentItem `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil // a notification cannot be answered with an error
+		}
+		s.docs[params.TextDocument.URI] = document{languageID: params.TextDocument.LanguageID, text: params.TextDocument.Text}
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument   textDocumentIdentifier `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil || len(params.ContentChanges) == 0 {
+			return nil
+		}
+		doc := s.docs[params.TextDocument.URI]
+		doc.text = params.ContentChanges[len(params.ContentChanges)-1].Text
+		s.docs[params.TextDocument.URI] = doc
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument textDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			delete(s.docs, params.TextDocument.URI)
+		}
+	case "textDocument/formatting", "textDocument/rangeFormatting":
+		var params formattingParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return s.reply(msg.ID, nil, &responseError{Code: codeInvalidParams, Message: err.Error()})
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return s.reply(msg.ID, nil, &responseError{Code: codeInvalidParams, Message: "unknown document " + params.TextDocument.URI})
+		}
+		edits, err := s.format(params.TextDocument.URI, doc, params.Range)
+		if err != nil {
+			return s.reply(msg.ID, nil, &responseError{Code: codeInvalidParams, Message: err.Error()})
+		}
+		return s.reply(msg.ID, edits, nil)
+	default:
+		if msg.ID != nil {
+			return s.reply(msg.ID, nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method})
+		}
+	}
+	return nil
+}
+
+// format returns the edits that rewrap doc, or only the comments overlapping r if it is not nil.
+// Documents in a language rewrap does not know are left alone, rather than wrapped as plain text,
+// unless the client says they are plain text.
+func (s *server) format(uri string, doc document, r *textRange) ([]textEdit, error) {
+	filename := uriToPath(uri)
+	cfg, err := wrap.ConfigForFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	lang, ok := cfg.Language(filename)
+	if !ok {
+		lang = cfg.DetectLanguage(filename, []byte(doc.text))
+		if lang == nil {
+			lang = cfg.LanguageFromName(doc.languageID)
+		}
+		if lang == nil && doc.languageID != "plaintext" {
+			return []textEdit{}, nil
+		}
+	}
+	opts, err := wrap.ResolveOptions(filename, []byte(doc.text), lang, cfg, s.opts)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		end := r.End.Line
+		if r.End.Character == 0 && end > r.Start.Line {
+			end-- // the range ends at the start of the line after the selection
+		}
+		opts.Lines = []wrap.LineRange{{Start: r.Start.Line + 1, End: end + 1}}
+	}
+	return textEdits(doc.text, wrap.Diff([]byte(doc.text), lang, opts)), nil
+}
+
+// textEdits converts the edits of text to LSP edits, whose positions count UTF-16 code units.
+func textEdits(text string, edits []wrap.Edit) []textEdit {
+	out := []textEdit{}
+	for _, e := range edits {
+		end := position{Line: e.EndLine}
+		if e.End == len(text) && e.EndLine > e.StartLine && !strings.HasSuffix(text, "\n") {
+			// The last line has no newline, so the edit ends at its end.
+			last := text[strings.LastIndex(text, "\n")+1:]
+			end = position{Line: e.EndLine - 1, Character: len(utf16.Encode([]rune(last)))}
+		}
+		out = append(out, textEdit{
+			Range:   textRange{Start: position{Line: e.StartLine}, End: end},
+			NewText: e.Text,
+		})
+	}
+	return out
+}
+
+// uriToPath returns the file path of a file:// URI. Other URIs are returned as they are, which is
+// enough to detect the language from the extension.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		// file:///C:/dir/file.go
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
@@ -0,0 +1,116 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mfridman/rewrap/wrap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// session runs the server on messages and returns the responses, in order.
+func session(t *testing.T, opts wrap.Options, messages ...string) []map[string]any {
+	t.Helper()
+	var in strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	var out strings.Builder
+	require.NoError(t, Serve(context.Background(), strings.NewReader(in.String()), &out, opts))
+
+	var responses []map[string]any
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(out.String())))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			return responses
+		}
+		require.NoError(t, err)
+		n, err := strconv.Atoi(header.Get("Content-Length"))
+		require.NoError(t, err)
+		body := make([]byte, n)
+		_, err = io.ReadFull(r.R, body)
+		require.NoError(t, err)
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(body, &resp))
+		responses = append(responses, resp)
+	}
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	text := "package a\n\n// one two three four five six\nvar a = 1\n\n// one two three four five six\nvar b = 2"
+	open, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "textDocument/didOpen",
+		"params": map[string]any{
+			"textDocument": map[string]any{"uri": "file:///nonexistent/a.go", "languageId": "go", "version": 1, "text": text},
+		},
+	})
+	require.NoError(t, err)
+
+	responses := session(t, wrap.Options{Column: 20},
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		string(open),
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/formatting","params":{"textDocument":{"uri":"file:///nonexistent/a.go"},"options":{"tabSize":4,"insertSpaces":false}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"textDocument/rangeFormatting","params":{"textDocument":{"uri":"file:///nonexistent/a.go"},"range":{"start":{"line":5,"character":0},"end":{"line":6,"character":0}},"options":{"tabSize":4,"insertSpaces":false}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"textDocument/hover","params":{}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	require.Len(t, responses, 5)
+
+	caps := responses[0]["result"].(map[string]any)["capabilities"].(map[string]any)
+	assert.Equal(t, true, caps["documentFormattingProvider"])
+	assert.Equal(t, true, caps["documentRangeFormattingProvider"])
+
+	edit := func(startLine, endLine int, newText string) any {
+		return map[string]any{
+			"range": map[string]any{
+				"start": map[string]any{"line": float64(startLine), "character": float64(0)},
+				"end":   map[string]any{"line": float64(endLine), "character": float64(0)},
+			},
+			"newText": newText,
+		}
+	}
+	// Each rewrapped comment is a separate edit, leaving the code between them alone.
+	assert.Equal(t, []any{
+		edit(2, 3, "// one two three\n// four five six\n"),
+		edit(5, 6, "// one two three\n// four five six\n"),
+	}, responses[1]["result"])
+	assert.Equal(t, []any{edit(5, 6, "// one two three\n// four five six\n")}, responses[2]["result"])
+	assert.Equal(t, float64(codeMethodNotFound), responses[3]["error"].(map[string]any)["code"])
+	assert.Nil(t, responses[4]["result"])
+	assert.Contains(t, responses[4], "result")
+}
+
+func TestServe_MessageSize(t *testing.T) {
+	t.Parallel()
+
+	in := fmt.Sprintf("Content-Length: %d\r\n\r\n{}", maxMessageSize+1)
+	err := Serve(context.Background(), strings.NewReader(in), io.Discard, wrap.Options{})
+	assert.EqualError(t, err, fmt.Sprintf("message of %d bytes exceeds the maximum of %d", maxMessageSize+1, maxMessageSize))
+}
+
+func TestTextEdits(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []textEdit{}, textEdits("a\nb\n", nil))
+	// An edit at the end of a document without a final newline ends at the end of the last line,
+	// counted in UTF-16 code units.
+	text := "a\nb😀"
+	assert.Equal(t, []textEdit{{
+		Range:   textRange{Start: position{Line: 1}, End: position{Line: 1, Character: 3}},
+		NewText: "x\ny",
+	}}, textEdits(text, []wrap.Edit{{Start: 2, End: len(text), StartLine: 1, EndLine: 2, Text: "x\ny"}}))
+}
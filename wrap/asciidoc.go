@@ -0,0 +1,137 @@
+package wrap
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	// adocDelimiter matches the opening and closing line of a delimited block: listing (----),
+	// example (====), literal (....), sidebar (****), quote (____), passthrough (++++), comment
+	// (////) and open (--) blocks, tables (|===, and ,=== and :=== for CSV and DSV data) and fenced
+	// code (```).
+	adocDelimiter = regexp.MustCompile("^(-{4,}|={4,}|\\.{4,}|\\*{4,}|_{4,}|\\+{4,}|/{4,}|--|[|,:!]={3,}|```.*)$")
+
+	// adocStructure matches a line that stands on its own: a section title, an attribute entry, a
+	// block attribute list or anchor, a block title, a line comment, a block macro such as
+	// "image::diagram.png[]" or "ifdef::env[]", a list continuation (+), a thematic break (''') or a
+	// page break (<<<).
+	adocStructure = regexp.MustCompile(`^(={1,6}|#{1,6}) \S|^:!?\w[\w-]*!?:(\s|$)|^\[.*\]$|^\.[^\s.]|^//|^[\w-]+::\S*\[.*\]$|^\+$|^'''$|^<<<$`)
+
+	// adocListItem matches the start of a list item: an unordered (*, -) or ordered (., 1., a.)
+	// item, or a description list item ("term:: definition") whose term is the first word of the
+	// line, so that a paragraph mentioning "note:: text" later on is not one.
+	adocListItem = regexp.MustCompile(`^(\*{1,5}|\.{1,5}|-|\d+\.|[a-zA-Z]\.) +\S|^[^\s:;][^\s:;]*(:{2,4}|;;)(\s|$)`)
+)
+
+// adocVerbatimStyles are the block styles that make the paragraph after them, such as
+// "[source,go]", verbatim.
+var adocVerbatimStyles = []string{"source", "listing", "literal", "verse", "pass", "stem", "latexmath", "asciimath"}
+
+// processAsciiDoc rewraps the paragraphs of an AsciiDoc document, including list items and
+// admonition paragraphs such as "NOTE: text". The document header, section titles, attribute
+// entries, block attributes and titles, comments, block macros, delimited blocks (listings,
+// examples, tables and so on), literal paragraphs (indented ones) and paragraphs with hard line
+// breaks (" +") are kept as they are.
+func processAsciiDoc(lines []string, opts Options) []string {
+	opts.canStartLine = adocCanStartLine
+	var out []string
+	verbatim := false // the next paragraph is verbatim, after a block style such as [source]
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimRight(line, " \t")
+		switch {
+		case trimmed == "":
+			out = append(out, line)
+			i++
+			verbatim = false
+			continue
+		case adocDelimiter.MatchString(trimmed):
+			// The block runs to its closing delimiter, or the end of the document.
+			end := len(lines)
+			closing := trimmed
+			if strings.HasPrefix(closing, "```") {
+				closing = "```"
+			}
+			for j := i + 1; j < len(lines); j++ {
+				if strings.TrimRight(lines[j], " \t") == closing {
+					end = j + 1
+					break
+				}
+			}
+			out = append(out, lines[i:end]...)
+			i = end
+			verbatim = false
+			continue
+		case strings.HasPrefix(trimmed, "= ") && !slices.ContainsFunc(lines[:i], func(s string) bool { return strings.TrimSpace(s) != "" && !strings.HasPrefix(s, "//") }):
+			// The document header runs to the first blank line.
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+				out = append(out, lines[i])
+				i++
+			}
+			continue
+		case adocStructure.MatchString(trimmed):
+			out = append(out, line)
+			i++
+			if strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "[[") {
+				style, _, _ := strings.Cut(strings.Trim(trimmed, "[]"), ",")
+				verbatim = verbatim || slices.Contains(adocVerbatimStyles, style) || strings.Contains(style, "%hardbreaks")
+			}
+			continue
+		}
+		start := i
+		for i++; i < len(lines); i++ {
+			next := strings.TrimRight(lines[i], " \t")
+			if next == "" || adocDelimiter.MatchString(next) || adocStructure.MatchString(next) {
+				break
+			}
+		}
+		para := lines[start:i]
+		switch {
+		case verbatim || !opts.selected(start, i) || !opts.withinLimits(para):
+			out = append(out, para...)
+		case line != strings.TrimLeft(line, " \t"):
+			out = append(out, para...) // a literal paragraph
+		case slices.ContainsFunc(para, func(s string) bool { return strings.HasSuffix(strings.TrimRight(s, " \t"), " +") }):
+			out = append(out, para...)
+		case adocListItem.MatchString(trimmed):
+			out = append(out, adocItems(para, opts)...)
+		default:
+			out = append(out, wrapText(strings.Join(para, "\n"), "", "", opts)...)
+		}
+		verbatim = false
+	}
+	return out
+}
+
+// adocCanStartLine reports whether a wrapped line may start with word after prefix: not if the
+// line would become a comment, block title, delimiter, list item or other structure.
+func adocCanStartLine(prefix, word string) bool {
+	line := prefix + word + " x"
+	return !adocStructure.MatchString(line) && !adocDelimiter.MatchString(prefix+word) &&
+		!adocListItem.MatchString(strings.TrimLeft(line, " \t"))
+}
+
+// adocItems rewraps a paragraph of list items. Each item starts on its own line, and its
+// continuation lines keep their indent, or are aligned with the text after the marker.
+func adocItems(para []string, opts Options) []string {
+	var out []string
+	for i := 0; i < len(para); {
+		start := i
+		for i++; i < len(para) && !adocListItem.MatchString(strings.TrimSpace(para[i])); i++ {
+		}
+		item := strings.TrimLeft(para[start], " \t")
+		indent := para[start][:len(para[start])-len(item)]
+		marker, _, _ := strings.Cut(item, " ")
+		hang := indent + strings.Repeat(" ", displayWidth(marker, opts.TabWidth)+1)
+		if !strings.HasSuffix(marker, ".") && marker != "-" && strings.Trim(marker, "*") != "" {
+			hang = indent + "  " // a description list item
+		}
+		if i > start+1 && leadingWidth(para[start+1]) > 0 {
+			hang = para[start+1][:leadingWidth(para[start+1])]
+		}
+		out = append(out, wrapParagraph(strings.Join(para[start:i], "\n"), indent, hang, opts, true)...)
+	}
+	return out
+}
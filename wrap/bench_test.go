@@ -0,0 +1,129 @@
+package wrap
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The benchmarks cover typical input, large files made by repeating the golden test inputs, and
+// pathological input. Compare runs with benchstat:
+//
+//	go test ./wrap -run '^$' -bench . -count 10 > old.txt
+//	(make the change)
+//	go test ./wrap -run '^$' -bench . -count 10 > new.txt
+//	benchstat old.txt new.txt
+//
+// See the README for a baseline.
+
+// repeatInputs returns the golden test inputs matching pattern, joined and repeated until the
+// result is at least size bytes.
+func repeatInputs(tb testing.TB, pattern string, size int) []byte {
+	tb.Helper()
+	inputs, err := filepath.Glob(filepath.Join("testdata", pattern))
+	require.NoError(tb, err)
+	var one bytes.Buffer
+	for _, f := range inputs {
+		if isGoldenFile(f) {
+			continue
+		}
+		src, err := os.ReadFile(f)
+		require.NoError(tb, err)
+		one.Write(src)
+		one.WriteByte('\n')
+	}
+	require.NotZero(tb, one.Len(), "no inputs match %s", pattern)
+	return bytes.Repeat(one.Bytes(), size/one.Len()+1)
+}
+
+// manyComments returns Go source with n short comments, each followed by a declaration.
+func manyComments(n int) []byte {
+	var b strings.Builder
+	b.WriteString("package a\n\n")
+	for range n {
+		b.WriteString("// x is a variable.\nvar x = 1\n\n")
+	}
+	return []byte(b.String())
+}
+
+// longParagraph returns a single Go comment paragraph of n words.
+func longParagraph(n int) []byte {
+	return []byte("// " + strings.TrimSpace(strings.Repeat("word ", n)) + "\npackage a\n")
+}
+
+func benchmarkSource(b *testing.B, src []byte, lang *Language, column int) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	for b.Loop() {
+		Source(src, lang, column, 4)
+	}
+}
+
+func BenchmarkGoFile(b *testing.B) {
+	benchmarkSource(b, repeatInputs(b, "go_*_c*.go", 1<<20), LanguageFromName("go"), 80)
+}
+
+func BenchmarkMarkdown(b *testing.B) {
+	benchmarkSource(b, repeatInputs(b, "markdown_*_c*.md", 1<<20), LanguageFromName("markdown"), 80)
+}
+
+func BenchmarkPlainText(b *testing.B) {
+	benchmarkSource(b, repeatInputs(b, "plain_text_c*.txt", 1<<20), nil, 80)
+}
+
+func BenchmarkLongParagraph(b *testing.B) {
+	benchmarkSource(b, longParagraph(100_000), LanguageFromName("go"), 80)
+}
+
+func BenchmarkManyComments(b *testing.B) {
+	benchmarkSource(b, manyComments(10_000), LanguageFromName("go"), 80)
+}
+
+// TestLinearTime guards against work that grows faster than the input, which the benchmarks only
+// show when someone runs them and compares. Four times the input must take well under sixteen times
+// as long, as it would if the work grew with the square of the input. Each size takes the fastest of
+// a few runs, which is the least affected by other work on the machine.
+func TestLinearTime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("slow")
+	}
+	goLang, markdown := LanguageFromName("go"), LanguageFromName("markdown")
+	tests := []struct {
+		name string
+		gen  func(n int) []byte
+		lang *Language
+		n    int
+	}{
+		{"go file", func(n int) []byte { return repeatInputs(t, "go_*_c*.go", n) }, goLang, 64 << 10},
+		{"markdown", func(n int) []byte { return repeatInputs(t, "markdown_*_c*.md", n) }, markdown, 64 << 10},
+		{"long paragraph", longParagraph, goLang, 32_000},
+		{"many comments", manyComments, goLang, 1_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fastest := func(n int) time.Duration {
+				src := tt.gen(n)
+				var best time.Duration
+				for i := range 5 {
+					runtime.GC()
+					start := time.Now()
+					Source(src, tt.lang, 80, 4)
+					if d := time.Since(start); i == 0 || d < best {
+						best = d
+					}
+				}
+				return best
+			}
+			small, large := fastest(tt.n), fastest(4*tt.n)
+			if large > 12*small {
+				t.Errorf("took %v for input of size %d, but %v for size %d; want at most 12 times as long", large, 4*tt.n, small, tt.n)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package wrap
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Reasons for leaving a Block unchanged.
+const (
+	SkipOff         = "rewrap:off"     // between "rewrap:off" and "rewrap:on" comments
+	SkipIgnore      = "rewrap:ignore"  // the comment after a "rewrap:ignore" comment
+	SkipDirective   = "directive"      // a directive such as "//go:generate", or a comment continuing one
+	SkipDecoration  = "decoration"     // a line of repeated punctuation, such as "// ======"
+	SkipAnchored    = "anchored"       // a line for other tools, such as "// nolint:"; see Options.Anchored
+	SkipTable       = "table"          // a table row, such as "// | a | b |"; see Rules
+	SkipCode        = "commented-code" // a line that looks like code, such as "// x := f(y)"; see Rules
+	SkipLicense     = "license-header" // a copyright or license comment at the top of a file; see Rules
+	SkipString      = "string"         // looks like a comment, but is inside a string literal or heredoc
+	SkipNested      = "nested"         // a block comment with nested block comments
+	SkipNotSelected = "not selected"   // outside Options.Lines
+	SkipLimits      = "limits"         // beyond Options.Limits
+	SkipMinLines    = "min-lines"      // shorter than Options.MinLines
+)
+
+// Block describes what rewrap did with a comment block, or with lines that look like a comment but
+// were left alone.
+type Block struct {
+	StartLine int    `json:"start"`             // first line, counted from 1
+	EndLine   int    `json:"end"`               // last line, included
+	Changed   bool   `json:"changed"`           // the block is rewrapped, and that changes it
+	Skipped   string `json:"skipped,omitempty"` // why the block was left as it is (a Skip constant), or ""
+}
+
+// Blocks returns the comment blocks of src, in order, as SourceWithOptions sees them: those it
+// rewraps, and those it leaves alone and why. Decoration and anchored lines inside a comment are
+// reported as blocks of their own, after the comment. Blocks returns nil for plain text and
+// markup such as Markdown, which have no comments.
+func Blocks(src []byte, lang *Language, opts Options) []Block {
+	if lang.prose() {
+		return nil
+	}
+	if opts.Limits.MaxInputSize > 0 && len(src) > opts.Limits.MaxInputSize {
+		return nil
+	}
+	opts.Column = cmp.Or(opts.Column, lang.column(), DefaultColumn)
+	opts.TabWidth = cmp.Or(opts.TabWidth, DefaultTabWidth)
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	lines := strings.Split(strings.ReplaceAll(text, "\r", "\n"), "\n")
+	inString := stringMask(lines, lang)
+
+	var blocks []Block
+	add := func(start, end int, changed bool, skipped string) {
+		if n := len(blocks); n > 0 && skipped != "" && blocks[n-1].Skipped == skipped && blocks[n-1].EndLine == start {
+			blocks[n-1].EndLine = end // extend a run of skipped lines
+			return
+		}
+		blocks = append(blocks, Block{StartLine: start + 1, EndLine: end, Changed: changed, Skipped: skipped})
+	}
+	n := 0
+	seenComment := false
+	segs := parseSegments(lines, lang)
+	if opts.MixedIndent {
+		segs = mergeMixedIndents(segs, opts.TabWidth)
+	}
+	for _, seg := range segs {
+		start := n
+		n += len(seg.lines)
+		if seg.typ == segmentCode {
+			if d := rewrapDirective(seg.lines[0], lang); d == "off" || d == "ignore" {
+				add(start, n, false, "rewrap:"+d)
+				continue
+			}
+			for i, line := range seg.lines {
+				if reason := codeLineReason(line, inString != nil && inString[start+i], lang); reason != "" {
+					add(start+i, start+i+1, false, reason)
+				}
+			}
+			continue
+		}
+		top := !seenComment && startsFile(lines[:start])
+		seenComment = true
+		if reason := skipReason(seg, start, n, top, opts); reason != "" {
+			add(start, n, false, reason)
+			continue
+		}
+		add(start, n, !slices.Equal(rewrapSegment(seg, lang, opts), seg.lines), "")
+		for i, line := range seg.lines {
+			if reason := commentLineReason(seg, line, lang, opts); reason != "" {
+				add(start+i, start+i+1, false, reason)
+			}
+		}
+	}
+	return blocks
+}
+
+// codeLineReason returns why line, in a code segment, is left alone although it looks like a
+// comment, or "" if it does not look like one. inString reports whether the line is inside a string
+// literal.
+func codeLineReason(line string, inString bool, lang *Language) string {
+	_, _, comment := matchLineComment(line, lang)
+	switch {
+	case !comment && !isDirectiveLine(line, lang, lang.Directives):
+		return ""
+	case inString:
+		return SkipString
+	}
+	return SkipDirective
+}
+
+// commentLineReason returns why line, in the line comment segment seg, is kept verbatim, or "" if it
+// is rewrapped with the lines around it.
+func commentLineReason(seg segment, line string, lang *Language, opts Options) string {
+	if seg.typ != segmentComment {
+		return ""
+	}
+	content := strings.TrimLeft(line, " \t")
+	if len(seg.marker) > len(content) {
+		return "" // a bare marker
+	}
+	return protectedLine(content[len(seg.marker):], lang, opts)
+}
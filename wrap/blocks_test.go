@@ -0,0 +1,45 @@
+package wrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocks(t *testing.T) {
+	t.Parallel()
+
+	src := `package a
+
+// rewrap:off
+// keep  this   as it is
+// rewrap:on
+
+//go:generate stringer -type=X
+
+// A long comment that has to be wrapped at the column.
+// ==========
+// nolint:errcheck
+var s = ` + "`" + `
+// not a comment
+` + "`" + `
+
+// Short.
+`
+	got := Blocks([]byte(src), LanguageFromName("go"), Options{Column: 40})
+	assert.Equal(t, []Block{
+		{StartLine: 3, EndLine: 5, Skipped: SkipOff},
+		{StartLine: 7, EndLine: 7, Skipped: SkipDirective},
+		{StartLine: 9, EndLine: 11, Changed: true},
+		{StartLine: 10, EndLine: 10, Skipped: SkipDecoration},
+		{StartLine: 11, EndLine: 11, Skipped: SkipAnchored},
+		{StartLine: 13, EndLine: 13, Skipped: SkipString},
+		{StartLine: 16, EndLine: 16},
+	}, got)
+
+	got = Blocks([]byte(src), LanguageFromName("go"), Options{Column: 40, MinLines: 2, Lines: []LineRange{{Start: 12, End: 20}}})
+	assert.Equal(t, Block{StartLine: 9, EndLine: 11, Skipped: SkipNotSelected}, got[2])
+	assert.Equal(t, Block{StartLine: 16, EndLine: 16, Skipped: SkipMinLines}, got[len(got)-1])
+
+	assert.Nil(t, Blocks([]byte("Some text.\n"), nil, Options{}))
+}
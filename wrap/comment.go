@@ -1,6 +1,7 @@
 package wrap
 
 import (
+	"slices"
 	"strings"
 )
 
@@ -8,9 +9,11 @@ import (
 type segmentType int
 
 const (
-	segmentCode    segmentType = iota
-	segmentComment             // line comment block
-	segmentBlock               // block comment (/* ... */)
+	segmentCode      segmentType = iota
+	segmentComment               // line comment block
+	segmentBlock                 // block comment (/* ... */)
+	segmentDocstring             // triple-quoted docstring
+	segmentPod                   // POD documentation, from "=head1" or the like through "=cut"
 )
 
 // segment represents a contiguous block of either code or comments in source text.
@@ -18,14 +21,61 @@ type segment struct {
 	typ    segmentType
 	lines  []string
 	indent string // leading whitespace of the comment block
-	marker string // comment marker including trailing space, e.g., "// "
+	marker string // comment marker including trailing space, e.g., "// "; block start marker for blocks
+	end    string // block comment end marker, e.g., "*/"
+	nested bool   // block comment contains nested block comments
 }
 
-// parseSegments splits source lines into code and comment segments for the given language.
+// parseSegments splits source lines into code and comment segments for the given language. Lines
+// inside multi-line string literals are always code; see stringMask.
 func parseSegments(lines []string, lang *Language) []segment {
 	var segments []segment
+	inString := stringMask(lines, lang)
+	masked := func(i int) bool { return inString != nil && inString[i] }
 	i := 0
 	for i < len(lines) {
+		if masked(i) {
+			start := i
+			for i < len(lines) && masked(i) {
+				i++
+			}
+			segments = append(segments, segment{typ: segmentCode, lines: lines[start:i]})
+			continue
+		}
+		if i == 0 && strings.HasPrefix(lines[0], "#!") {
+			// An interpreter line is code, even in a language with "#" comments.
+			segments = append(segments, segment{typ: segmentCode, lines: lines[:1]})
+			i++
+			continue
+		}
+		// Lines from "rewrap:off" through "rewrap:on", and a "rewrap:ignore" line with the comment
+		// that follows it, are left alone.
+		if d := rewrapDirective(lines[i], lang); d != "" {
+			start := i
+			i++
+			switch d {
+			case "off":
+				for i < len(lines) {
+					i++
+					if !masked(i-1) && rewrapDirective(lines[i-1], lang) == "on" {
+						break
+					}
+				}
+			case "ignore":
+				if i < len(lines) && !masked(i) {
+					i = commentEnd(lines, i, lang)
+				}
+			}
+			segments = append(segments, segment{typ: segmentCode, lines: lines[start:i]})
+			continue
+		}
+		if lang != nil && lang.Pod {
+			if seg, end := tryPod(lines, i); end > i {
+				segments = append(segments, seg)
+				i = end
+				continue
+			}
+		}
 		// Try block comment first.
 		if lang != nil && len(lang.BlockStart) > 0 {
 			if seg, end := tryBlockComment(lines, i, lang); end > i {
@@ -34,9 +84,22 @@ func parseSegments(lines []string, lang *Language) []segment {
 				continue
 			}
 		}
+		// Try docstring.
+		if lang != nil && len(lang.Docstrings) > 0 {
+			if seg, end := tryDocstring(lines, i, lang); end > i {
+				segments = append(segments, seg)
+				i = end
+				continue
+			}
+		}
 		// Try line comment.
 		if lang != nil && len(lang.LineMarkers) > 0 {
 			if seg, end := tryLineCommentBlock(lines, i, lang); end > i {
+				if i > 0 && !masked(i-1) && isDirectiveLine(lines[i-1], lang, lang.Continued) {
+					// Comments attached to a directive, such as the continuation of a
+					// //go:generate command, belong to it.
+					seg = segment{typ: segmentCode, lines: seg.lines}
+				}
 				segments = append(segments, seg)
 				i = end
 				continue
@@ -45,15 +108,28 @@ func parseSegments(lines []string, lang *Language) []segment {
 		// Code line - accumulate consecutive code lines.
 		start := i
 		for i < len(lines) {
+			if masked(i) || rewrapDirective(lines[i], lang) != "" {
+				break
+			}
 			if lang != nil {
 				if _, end := tryLineCommentBlock(lines, i, lang); end > i {
 					break
 				}
+				if lang.Pod {
+					if _, end := tryPod(lines, i); end > i {
+						break
+					}
+				}
 				if len(lang.BlockStart) > 0 {
 					if _, end := tryBlockComment(lines, i, lang); end > i {
 						break
 					}
 				}
+				if len(lang.Docstrings) > 0 {
+					if _, end := tryDocstring(lines, i, lang); end > i {
+						break
+					}
+				}
 			}
 			i++
 		}
@@ -65,6 +141,29 @@ func parseSegments(lines []string, lang *Language) []segment {
 	return segments
 }
 
+// mergeMixedIndents returns segs with each line comment segment joined to the one before it when
+// only their indentation splits them, and it has the same display width with tabWidth, such as a
+// tab and four spaces. The joined segment keeps the indentation of its first line. See
+// Options.MixedIndent.
+func mergeMixedIndents(segs []segment, tabWidth int) []segment {
+	var out []segment
+	for _, seg := range segs {
+		if n := len(out); n > 0 && seg.typ == segmentComment && out[n-1].typ == segmentComment {
+			prev := &out[n-1]
+			if seg.indent != prev.indent && displayWidth(seg.indent, tabWidth) == displayWidth(prev.indent, tabWidth) &&
+				strings.TrimRight(seg.marker, " ") == strings.TrimRight(prev.marker, " ") {
+				prev.lines = slices.Concat(prev.lines, seg.lines)
+				if len(seg.marker) > len(prev.marker) {
+					prev.marker = seg.marker
+				}
+				continue
+			}
+		}
+		out = append(out, seg)
+	}
+	return out
+}
+
 // tryLineCommentBlock tries to parse a block of consecutive line comments starting at line index i.
 // Returns the segment and the index after the last comment line.
 func tryLineCommentBlock(lines []string, i int, lang *Language) (segment, int) {
@@ -73,14 +172,22 @@ func tryLineCommentBlock(lines []string, i int, lang *Language) (segment, int) {
 		return segment{}, i
 	}
 
-	// Use the base marker token (without trailing space) for grouping, so that bare "//"-only lines
-	// stay grouped with "// " content lines in the same comment block.
-	baseMarker := strings.TrimRight(marker, " ")
+	// Lines are grouped by their level, the marker without its trailing space, so that bare "//"
+	// lines stay grouped with "// " content lines, but "//" and "///" lines are separate blocks.
+	level := strings.TrimRight(marker, " ")
 
 	start := i
 	for i < len(lines) {
 		ind, mk, ok := matchLineComment(lines[i], lang)
-		if !ok || ind != indent || strings.TrimRight(mk, " ") != baseMarker {
+		if !ok || ind != indent {
+			break
+		}
+		// A bare "///" line has level "//", but belongs to a "///" block and not to a "//" one.
+		bare := strings.TrimRight(lines[i][len(ind):], " \t")
+		if strings.TrimRight(mk, " ") != level && bare != level {
+			break
+		}
+		if len(bare) > len(level) && strings.Trim(bare, level[len(level)-1:]) == "" {
 			break
 		}
 		// Prefer the longer marker (with space) for the segment, since that's the content marker.
@@ -100,27 +207,83 @@ func tryLineCommentBlock(lines []string, i int, lang *Language) (segment, int) {
 	}, i
 }
 
-// matchLineComment checks if a line is a line comment and returns the indent and marker.
+// commentEnd returns the index after the comment that starts at line index i, or i if there is
+// none.
+func commentEnd(lines []string, i int, lang *Language) int {
+	if lang.Pod {
+		if _, end := tryPod(lines, i); end > i {
+			return end
+		}
+	}
+	if len(lang.BlockStart) > 0 {
+		if _, end := tryBlockComment(lines, i, lang); end > i {
+			return end
+		}
+	}
+	if len(lang.Docstrings) > 0 {
+		if _, end := tryDocstring(lines, i, lang); end > i {
+			return end
+		}
+	}
+	_, end := tryLineCommentBlock(lines, i, lang)
+	return end
+}
+
+// rewrapDirective returns "off", "on" or "ignore" if line is a comment consisting only of
+// "rewrap:off", "rewrap:on" or "rewrap:ignore", such as "// rewrap:off" or "/* rewrap:ignore */",
+// and "" otherwise.
+func rewrapDirective(line string, lang *Language) string {
+	if lang == nil {
+		return ""
+	}
+	trimmed := strings.TrimSpace(line)
+	text := ""
+	if m := longestPrefix(trimmed, lang.LineMarkers); m != "" {
+		text = trimmed[len(m):]
+	} else if j := prefixIndex(trimmed, lang.BlockStart); j >= 0 {
+		end := lang.BlockEnd[min(j, len(lang.BlockEnd)-1)]
+		text = strings.TrimPrefix(trimmed, lang.BlockStart[j])
+		if !strings.HasSuffix(text, end) {
+			return ""
+		}
+		text = strings.TrimSuffix(text, end)
+	}
+	switch d, _ := strings.CutPrefix(strings.TrimSpace(text), "rewrap:"); d {
+	case "off", "on", "ignore":
+		return d
+	}
+	return ""
+}
+
+// isDirectiveLine reports whether line is a line comment that starts with one of directives, such
+// as "//go:generate".
+func isDirectiveLine(line string, lang *Language, directives []string) bool {
+	trimmed := strings.TrimLeft(line, " \t")
+	for _, m := range lang.LineMarkers {
+		if strings.HasPrefix(trimmed, m) {
+			return hasAnyPrefix(trimmed[len(m):], directives)
+		}
+	}
+	return false
+}
+
+// matchLineComment checks if a line is a line comment and returns the indent and marker. The marker
+// is the comment's level (see commentLevel) plus one trailing space if present. Directive lines,
+// including rewrap's own (see rewrapDirective), are not comments.
 func matchLineComment(line string, lang *Language) (indent, marker string, ok bool) {
 	trimmed := strings.TrimLeft(line, " \t")
-	if trimmed == "" {
+	if trimmed == "" || rewrapDirective(line, lang) != "" {
 		return "", "", false
 	}
 	indent = line[:len(line)-len(trimmed)]
+	if isDirectiveLine(line, lang, lang.Directives) {
+		return "", "", false
+	}
 	for _, m := range lang.LineMarkers {
 		if strings.HasPrefix(trimmed, m) {
-			rest := trimmed[len(m):]
-			// Check if the remaining text is a directive -- if so, treat the line as code.
-			for _, d := range lang.Directives {
-				if strings.HasPrefix(rest, d) {
-					return "", "", false
-				}
-			}
-			// The marker is the comment token plus one trailing space if present.
-			if len(rest) > 0 && rest[0] == ' ' {
-				marker = m + " "
-			} else {
-				marker = m
+			marker = commentLevel(trimmed, m, lang)
+			if rest := trimmed[len(marker):]; len(rest) > 0 && rest[0] == ' ' {
+				marker += " "
 			}
 			return indent, marker, true
 		}
@@ -128,16 +291,40 @@ func matchLineComment(line string, lang *Language) (indent, marker string, ok bo
 	return "", "", false
 }
 
+// commentLevel returns the level of the line comment trimmed, which starts with the line marker m.
+// Comments of different levels are never merged. The level is the longest of lang.Levels that
+// trimmed starts with, if any, or else m followed by any repeats of its last character before a
+// space, so that "///" and "##" comments are separate from "//" and "#" ones in every language.
+// A line of only marker characters, such as "///" or "########", has level m.
+func commentLevel(trimmed, m string, lang *Language) string {
+	if level := longestPrefix(trimmed, lang.Levels); len(level) > len(m) {
+		return level
+	}
+	n := len(m)
+	for n < len(trimmed) && trimmed[n] == m[len(m)-1] {
+		n++
+	}
+	if n > len(m) && n < len(trimmed) && (trimmed[n] == ' ' || trimmed[n] == '\t') {
+		return trimmed[:n]
+	}
+	return m
+}
+
 // tryBlockComment tries to parse a block comment (/* ... */) starting at line index i.
 func tryBlockComment(lines []string, i int, lang *Language) (segment, int) {
 	trimmed := strings.TrimLeft(lines[i], " \t")
 	indent := lines[i][:len(lines[i])-len(trimmed)]
 
 	// Check if line starts with a block start marker.
-	startMarker := ""
-	for _, bs := range lang.BlockStart {
+	startMarker, endMarker := "", ""
+	for j, bs := range lang.BlockStart {
 		if strings.HasPrefix(trimmed, bs) {
 			startMarker = bs
+			// Start and end markers pair up by index; a single end marker serves all starts.
+			endMarker = lang.BlockEnd[0]
+			if j < len(lang.BlockEnd) {
+				endMarker = lang.BlockEnd[j]
+			}
 			break
 		}
 	}
@@ -145,16 +332,36 @@ func tryBlockComment(lines []string, i int, lang *Language) (segment, int) {
 		return segment{}, i
 	}
 
+	// For languages with nestable block comments, count the shortest start marker (e.g., "/*" for
+	// "/**") to find the end marker that closes the outermost comment.
+	nestOpen := ""
+	if lang.BlockNested {
+		nestOpen = startMarker
+		for _, bs := range lang.BlockStart {
+			if len(bs) < len(nestOpen) && strings.HasPrefix(startMarker, bs) {
+				nestOpen = bs
+			}
+		}
+	}
+
 	// Find the matching block end.
-	endMarker := lang.BlockEnd[0] // use first block end marker
 	start := i
+	depth := 0
 	for i < len(lines) {
-		if strings.Contains(lines[i], endMarker) {
+		closed := strings.Contains(lines[i], endMarker)
+		if nestOpen != "" {
+			depth += strings.Count(lines[i], nestOpen) - strings.Count(lines[i], endMarker)
+			closed = depth <= 0
+		}
+		if closed {
 			i++ // include the line with the end marker
 			return segment{
 				typ:    segmentBlock,
 				lines:  lines[start:i],
 				indent: indent,
+				marker: startMarker,
+				end:    endMarker,
+				nested: nestOpen != "" && hasNestedStart(append([]string{trimmed[len(startMarker):]}, lines[start+1:i]...), nestOpen),
 			}, i
 		}
 		i++
@@ -166,6 +373,16 @@ func tryBlockComment(lines []string, i int, lang *Language) (segment, int) {
 	}, i
 }
 
+// hasNestedStart reports whether any of lines contains the block start marker.
+func hasNestedStart(lines []string, marker string) bool {
+	for _, line := range lines {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // isDecorationLine returns true if the comment content (after stripping the marker) consists
 // entirely of repeated punctuation/symbols (e.g., "//========" or "//------").
 func isDecorationLine(content string) bool {
@@ -175,7 +392,7 @@ func isDecorationLine(content string) bool {
 	}
 	for _, r := range trimmed {
 		switch r {
-		case '=', '-', '*', '#', '~', '+', '_', '.':
+		case '=', '-', '*', '#', '~', '+', '_', '.', ';':
 			// decoration characters
 		default:
 			return false
@@ -35,6 +35,18 @@ func TestParseSegments(t *testing.T) {
 		assert.Equal(t, "\t", segs[0].indent)
 	})
 
+	t.Run("rewrap directives", func(t *testing.T) {
+		input := strings.Split("x := 1\n\t/* rewrap:off */\n\t// a\n//rewrap:on\n// b\n// rewrap:ignore\n/*\n c\n*/\n// d", "\n")
+		segs := parseSegments(input, goLang)
+		require.Len(t, segs, 5)
+		assert.Equal(t, segmentCode, segs[1].typ)
+		assert.Equal(t, input[1:4], segs[1].lines)
+		assert.Equal(t, segmentComment, segs[2].typ)
+		assert.Equal(t, segmentCode, segs[3].typ)
+		assert.Equal(t, input[5:9], segs[3].lines)
+		assert.Equal(t, []string{"// d"}, segs[4].lines)
+	})
+
 	t.Run("mixed code and comments", func(t *testing.T) {
 		input := strings.Split("package main\n\n// Comment\nfunc foo() {}\n\n// Another\nfunc bar() {}", "\n")
 		segs := parseSegments(input, goLang)
@@ -63,3 +75,22 @@ func TestIsDecorationLine(t *testing.T) {
 		assert.Equal(t, tt.want, isDecorationLine(tt.input), "isDecorationLine(%q)", tt.input)
 	}
 }
+
+func TestCommentLevel(t *testing.T) {
+	c := LanguageFromName("c")
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"// text", "//"},
+		{"/// text", "///"},
+		{"//! text", "//!"},
+		{"///", "//"},
+		{"//////////", "//"},
+		{"//// text", "////"},
+		{"//=====", "//"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, commentLevel(tt.line, "//", c), "commentLevel(%q)", tt.line)
+	}
+}
@@ -0,0 +1,823 @@
+package wrap
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ConfigFileName is the name of the per-project config file. See FindConfig for how it is
+// discovered.
+const ConfigFileName = ".rewrap.toml"
+
+// Config holds per-project settings loaded from a config file, such as:
+//
+//	column = 80
+//	tab-width = 4
+//	min-lines = 2
+//	exclude = ["vendor", "testdata", "*.pb.go"]
+//
+//	[languages]
+//	"*.tpl" = "gotemplate"
+//	"scripts/*" = "shell"
+//
+//	[columns]
+//	python = 88
+//
+//	[anchored]
+//	go = ["+operator-sdk:"]
+//
+//	[rules]
+//	table = true
+//	decoration = false
+//	table-min-columns = 3
+//
+//	[[paths]]
+//	match = "docs/**"
+//	column = 100
+//	preserve-sentence-starts = true
+//
+//	[[paths]]
+//	match = "third_party"
+//	skip = true
+//
+//	[language.dsl]
+//	extensions = [".dsl"]
+//	line-markers = [";;"]
+//
+// Patterns are matched against paths relative to the directory of the config file. A pattern
+// without a slash matches any path element (a file or a directory), and "**" matches any number of
+// directories. A pattern that matches a directory matches everything below it. Backslashes are
+// path separators, as on Windows, not escapes, so that the same patterns work on every platform.
+type Config struct {
+	Path     string // path of the config file
+	Column   int    // wrapping column width, 0 if not set
+	TabWidth int    // tab display width, 0 if not set
+	MinLines int    // see Options.MinLines, 0 if not set
+	Exclude  []string
+
+	// Languages maps file patterns to language names, as accepted by LanguageFromName, or "text"
+	// for plain text. Patterns are tried in the order they appear in the file.
+	Languages []LanguageOverride
+
+	// Columns maps language names (Language.Name, or "text" for plain text) to the column for that
+	// language, which takes precedence over Column.
+	Columns map[string]int
+
+	// Anchored maps language names to anchored markers (see Options.Anchored) for that language, in
+	// addition to those built in.
+	Anchored map[string][]string
+
+	// Rules turns rules on and off and sets their thresholds; see Options.Rules.
+	Rules Rules
+
+	// Paths are the [[paths]] entries, each with settings for the files matching its pattern. Only
+	// the first entry that matches a file applies to it; see PathRule.
+	Paths []PathRule
+
+	// CustomLanguages are the languages defined by [language.NAME] tables. They are found by the
+	// config's LanguageFromName and DetectLanguage, before the registered languages, and are never
+	// registered themselves, so that configs may define a language differently.
+	CustomLanguages []Language
+}
+
+// languageKeys maps the keys of a [language.NAME] table to the Language fields they set.
+var languageKeys = map[string]func(*Language, any) bool{
+	"extensions":   func(l *Language, v any) bool { return setList(&l.Extensions, v) },
+	"filenames":    func(l *Language, v any) bool { return setList(&l.Filenames, v) },
+	"line-markers": func(l *Language, v any) bool { return setList(&l.LineMarkers, v) },
+	"levels":       func(l *Language, v any) bool { return setList(&l.Levels, v) },
+	"block-start":  func(l *Language, v any) bool { return setList(&l.BlockStart, v) },
+	"block-end":    func(l *Language, v any) bool { return setList(&l.BlockEnd, v) },
+	"directives":   func(l *Language, v any) bool { return setList(&l.Directives, v) },
+	"anchored":     func(l *Language, v any) bool { return setList(&l.Anchored, v) },
+	"block-prefix": func(l *Language, v any) bool {
+		s, ok := v.(string)
+		l.BlockPrefix = s
+		return ok
+	},
+	"column": func(l *Language, v any) bool {
+		n, ok := v.(int)
+		l.Column = n
+		return ok && n > 0
+	},
+}
+
+// setList sets *dst to v if it is a list of strings.
+func setList(dst *[]string, v any) bool {
+	list, ok := v.([]string)
+	*dst = list
+	return ok
+}
+
+// PathRule holds the settings of a [[paths]] entry for the files matching Match. Its Column and
+// TabWidth take precedence over the rest of the config; see ResolveOptions.
+type PathRule struct {
+	Match    string // pattern of the files the entry is for
+	Column   int    // 0 if not set
+	TabWidth int    // 0 if not set
+	Language string // language name, as in [languages], or "" if not set
+	Skip     bool   // the files are excluded, as if by exclude
+
+	PreserveSentenceStarts bool // see Options.PreserveSentenceStarts
+}
+
+// LanguageOverride maps files matching Pattern to the named language.
+type LanguageOverride struct {
+	Pattern  string
+	Language string
+}
+
+// LoadConfig reads and parses the config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Path = path
+	return cfg, nil
+}
+
+// FindConfig looks for ConfigFileName in dir and each of its parent directories, and loads the
+// first one found. It returns nil and no error if there is none.
+func FindConfig(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, ConfigFileName)
+		cfg, err := LoadConfig(path)
+		if err == nil {
+			return cfg, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// ConfigForFile returns the config that applies to filename, found by walking up from the file's
+// directory. It returns nil and no error if there is none.
+func ConfigForFile(filename string) (*Config, error) {
+	return FindConfig(filepath.Dir(filename))
+}
+
+// ParseConfig parses the contents of a config file. Only the subset of TOML needed by the config is
+// supported: integers, booleans, strings, arrays of strings, the [languages], [columns],
+// [anchored], [rules] and [language.NAME] tables, and [[paths]] entries.
+func ParseConfig(data []byte) (*Config, error) {
+	pairs, err := parseTOML(string(data))
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	custom := make(map[string]int) // index in cfg.CustomLanguages by name
+	var names []tomlKeyValue       // language names used in [languages] and [columns], checked last
+	for _, kv := range pairs {
+		if (kv.entry > 0) != (kv.table == "paths") {
+			if kv.entry > 0 {
+				return nil, fmt.Errorf("line %d: unknown table [[%s]]", kv.line, kv.table)
+			}
+			return nil, fmt.Errorf("line %d: paths must be an array of tables, [[paths]]", kv.line)
+		}
+		if name, ok := strings.CutPrefix(kv.table, "language."); ok {
+			i, ok := custom[name]
+			if !ok {
+				i = len(cfg.CustomLanguages)
+				custom[name] = i
+				cfg.CustomLanguages = append(cfg.CustomLanguages, Language{Name: name})
+			}
+			set, ok := languageKeys[kv.key]
+			if !ok {
+				return nil, fmt.Errorf("line %d: unknown language key %q", kv.line, kv.key)
+			}
+			if !set(&cfg.CustomLanguages[i], kv.value) {
+				return nil, fmt.Errorf("line %d: invalid value for %s", kv.line, kv.key)
+			}
+			continue
+		}
+		switch kv.table {
+		case "":
+			switch kv.key {
+			case "column", "tab-width", "min-lines":
+				n, ok := kv.value.(int)
+				if !ok || n <= 0 {
+					return nil, fmt.Errorf("line %d: %s must be a positive integer", kv.line, kv.key)
+				}
+				switch kv.key {
+				case "column":
+					cfg.Column = n
+				case "tab-width":
+					cfg.TabWidth = n
+				default:
+					cfg.MinLines = n
+				}
+			case "exclude":
+				list, ok := kv.value.([]string)
+				if !ok {
+					return nil, fmt.Errorf("line %d: exclude must be an array of strings", kv.line)
+				}
+				for _, p := range list {
+					if err := checkPattern(p); err != nil {
+						return nil, fmt.Errorf("line %d: %w", kv.line, err)
+					}
+				}
+				cfg.Exclude = append(cfg.Exclude, list...)
+			default:
+				return nil, fmt.Errorf("line %d: unknown key %q", kv.line, kv.key)
+			}
+		case "languages":
+			name, ok := kv.value.(string)
+			if !ok {
+				return nil, fmt.Errorf("line %d: language for %q must be a string", kv.line, kv.key)
+			}
+			names = append(names, tomlKeyValue{key: name, line: kv.line})
+			if err := checkPattern(kv.key); err != nil {
+				return nil, fmt.Errorf("line %d: %w", kv.line, err)
+			}
+			cfg.Languages = append(cfg.Languages, LanguageOverride{Pattern: kv.key, Language: name})
+		case "columns":
+			n, ok := kv.value.(int)
+			if !ok || n <= 0 {
+				return nil, fmt.Errorf("line %d: column for %q must be a positive integer", kv.line, kv.key)
+			}
+			names = append(names, tomlKeyValue{key: kv.key, line: kv.line})
+			if cfg.Columns == nil {
+				cfg.Columns = make(map[string]int)
+			}
+			cfg.Columns[kv.key] = n
+		case "rules":
+			switch kv.key {
+			case "decoration-min-length", "table-min-columns":
+				n, ok := kv.value.(int)
+				if !ok || n <= 0 {
+					return nil, fmt.Errorf("line %d: %s must be a positive integer", kv.line, kv.key)
+				}
+				if kv.key == "decoration-min-length" {
+					cfg.Rules.DecorationMinLength = n
+				} else {
+					cfg.Rules.TableMinColumns = n
+				}
+				continue
+			}
+			if err := CheckRule(kv.key); err != nil {
+				return nil, fmt.Errorf("line %d: %w", kv.line, err)
+			}
+			on, ok := kv.value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("line %d: rule %s must be true or false", kv.line, kv.key)
+			}
+			if on {
+				cfg.Rules.On = append(cfg.Rules.On, kv.key)
+			} else {
+				cfg.Rules.Off = append(cfg.Rules.Off, kv.key)
+			}
+		case "paths":
+			for len(cfg.Paths) < kv.entry {
+				cfg.Paths = append(cfg.Paths, PathRule{})
+			}
+			rule := &cfg.Paths[kv.entry-1]
+			var ok bool
+			switch kv.key {
+			case "match":
+				rule.Match, ok = kv.value.(string)
+				if ok {
+					if err := checkPattern(rule.Match); err != nil {
+						return nil, fmt.Errorf("line %d: %w", kv.line, err)
+					}
+				}
+			case "column", "tab-width":
+				var n int
+				n, ok = kv.value.(int)
+				ok = ok && n > 0
+				if kv.key == "column" {
+					rule.Column = n
+				} else {
+					rule.TabWidth = n
+				}
+			case "language":
+				rule.Language, ok = kv.value.(string)
+				names = append(names, tomlKeyValue{key: rule.Language, line: kv.line})
+			case "skip":
+				rule.Skip, ok = kv.value.(bool)
+			case "preserve-sentence-starts":
+				rule.PreserveSentenceStarts, ok = kv.value.(bool)
+			default:
+				return nil, fmt.Errorf("line %d: unknown paths key %q", kv.line, kv.key)
+			}
+			if !ok {
+				return nil, fmt.Errorf("line %d: invalid value for %s", kv.line, kv.key)
+			}
+		case "anchored":
+			list, ok := kv.value.([]string)
+			if !ok {
+				return nil, fmt.Errorf("line %d: anchored markers for %q must be an array of strings", kv.line, kv.key)
+			}
+			names = append(names, tomlKeyValue{key: kv.key, line: kv.line})
+			if cfg.Anchored == nil {
+				cfg.Anchored = make(map[string][]string)
+			}
+			cfg.Anchored[kv.key] = list
+		default:
+			return nil, fmt.Errorf("line %d: unknown table [%s]", kv.line, kv.table)
+		}
+	}
+	for i, rule := range cfg.Paths {
+		if rule.Match == "" {
+			return nil, fmt.Errorf("[[paths]] entry %d has no match", i+1)
+		}
+	}
+	for _, lang := range cfg.CustomLanguages {
+		if err := checkLanguage(lang); err != nil {
+			return nil, err
+		}
+		if len(lang.LineMarkers) == 0 && len(lang.BlockStart) == 0 {
+			return nil, fmt.Errorf("language %s has no line-markers or block-start", lang.Name)
+		}
+	}
+	// Languages may be referred to by an alias, such as "py", or before they are defined.
+	for _, kv := range names {
+		if _, ok := custom[kv.key]; ok || kv.key == "text" {
+			continue
+		}
+		lang := LanguageFromName(kv.key)
+		if lang == nil {
+			return nil, fmt.Errorf("line %d: unknown language %q", kv.line, kv.key)
+		}
+		if kv.key == lang.Name {
+			continue
+		}
+		if n, ok := cfg.Columns[kv.key]; ok {
+			delete(cfg.Columns, kv.key)
+			cfg.Columns[lang.Name] = n
+		}
+		if list, ok := cfg.Anchored[kv.key]; ok {
+			delete(cfg.Anchored, kv.key)
+			cfg.Anchored[lang.Name] = list
+		}
+	}
+	return cfg, nil
+}
+
+// LanguageFromName is like the package's LanguageFromName, but finds the config's CustomLanguages
+// first. A nil config has none.
+func (c *Config) LanguageFromName(name string) *Language {
+	if c != nil {
+		lower := strings.ToLower(name)
+		for i := range c.CustomLanguages {
+			if c.CustomLanguages[i].matchesName(lower) {
+				return &c.CustomLanguages[i]
+			}
+		}
+	}
+	return LanguageFromName(name)
+}
+
+// DetectLanguage is like the package's DetectLanguage, but a file with a file name or extension of
+// one of the config's CustomLanguages is in that language. A nil config has none.
+func (c *Config) DetectLanguage(filename string, src []byte) *Language {
+	if c != nil {
+		elems := strings.Split(filepath.ToSlash(filename), "/")
+		ext := strings.ToLower(filepath.Ext(filename))
+		for i := range c.CustomLanguages {
+			if c.CustomLanguages[i].matchesFilename(elems) {
+				return &c.CustomLanguages[i]
+			}
+		}
+		for i := range c.CustomLanguages {
+			if ext != "" && slices.Contains(c.CustomLanguages[i].Extensions, ext) {
+				return &c.CustomLanguages[i]
+			}
+		}
+	}
+	return DetectLanguage(filename, src)
+}
+
+// Apply returns opts with an unset (zero) Column, TabWidth, MinLines or rule threshold taken from
+// the config, and the config's rules turned on and off before those of opts. Values already set in
+// opts, such as from command-line flags, take precedence. A nil config returns opts unchanged.
+func (c *Config) Apply(opts Options) Options {
+	if c != nil {
+		opts.Column = cmp.Or(opts.Column, c.Column)
+		opts.TabWidth = cmp.Or(opts.TabWidth, c.TabWidth)
+		opts.MinLines = cmp.Or(opts.MinLines, c.MinLines)
+		opts.Rules.On = append(slices.Clip(c.Rules.On), opts.Rules.On...)
+		opts.Rules.Off = append(slices.Clip(c.Rules.Off), opts.Rules.Off...)
+		opts.Rules.DecorationMinLength = cmp.Or(opts.Rules.DecorationMinLength, c.Rules.DecorationMinLength)
+		opts.Rules.TableMinColumns = cmp.Or(opts.Rules.TableMinColumns, c.Rules.TableMinColumns)
+	}
+	return opts
+}
+
+// ApplyLanguage is like Apply, but an unset Column is taken from the [columns] entry for lang
+// first, if there is one, and the [anchored] markers for lang are added to opts.Anchored. A nil
+// lang means plain text.
+func (c *Config) ApplyLanguage(opts Options, lang *Language) Options {
+	if c != nil {
+		name := "text"
+		if lang != nil {
+			name = lang.Name
+		}
+		opts.Column = cmp.Or(opts.Column, c.Columns[name])
+		if list := c.Anchored[name]; len(list) > 0 {
+			opts.Anchored = append(slices.Clip(opts.Anchored), list...)
+		}
+	}
+	return c.Apply(opts)
+}
+
+// Excluded reports whether filename matches one of the exclude patterns, or its [[paths]] entry
+// skips it. A nil config excludes nothing.
+func (c *Config) Excluded(filename string) bool {
+	if c == nil {
+		return false
+	}
+	rel, ok := c.relPath(filename)
+	if !ok {
+		return false
+	}
+	if rule := c.pathRule(rel); rule != nil && rule.Skip {
+		return true
+	}
+	for _, p := range c.Exclude {
+		if matchPattern(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Language returns the language configured for filename, by its [[paths]] entry or else by
+// [languages]. The bool result is false if no override matches; otherwise a nil language means
+// plain text. A nil config has no overrides.
+func (c *Config) Language(filename string) (*Language, bool) {
+	if c == nil {
+		return nil, false
+	}
+	rel, ok := c.relPath(filename)
+	if !ok {
+		return nil, false
+	}
+	name := ""
+	if rule := c.pathRule(rel); rule != nil {
+		name = rule.Language
+	}
+	if name == "" {
+		for _, o := range c.Languages {
+			if matchPattern(o.Pattern, rel) {
+				name = o.Language
+				break
+			}
+		}
+	}
+	switch name {
+	case "":
+		return nil, false
+	case "text":
+		return nil, true
+	}
+	return c.LanguageFromName(name), true
+}
+
+// PathRule returns the first [[paths]] entry whose pattern matches filename, or nil if there is
+// none. A nil config has none.
+func (c *Config) PathRule(filename string) *PathRule {
+	if c == nil {
+		return nil
+	}
+	rel, ok := c.relPath(filename)
+	if !ok {
+		return nil
+	}
+	return c.pathRule(rel)
+}
+
+// pathRule is like PathRule for the path rel relative to the directory of the config file.
+func (c *Config) pathRule(rel string) *PathRule {
+	for i, rule := range c.Paths {
+		if matchPattern(rule.Match, rel) {
+			return &c.Paths[i]
+		}
+	}
+	return nil
+}
+
+// relPath returns filename relative to the directory of the config file, with forward slashes. It
+// returns false if the file is outside that directory.
+func (c *Config) relPath(filename string) (string, bool) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(filepath.Dir(c.Path), abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// checkPattern reports an error if p is not a valid pattern.
+func checkPattern(p string) error {
+	if p == "" {
+		return errors.New("empty pattern")
+	}
+	for elem := range strings.SplitSeq(slashPattern(p), "/") {
+		if _, err := path.Match(elem, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// matchPattern reports whether the slash-separated relative path rel, or one of its parent
+// directories, matches pattern. See Config for the pattern syntax.
+func matchPattern(pattern, rel string) bool {
+	pattern = strings.Trim(slashPattern(pattern), "/")
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	pelems := strings.Split(pattern, "/")
+	elems := strings.Split(rel, "/")
+	for n := len(elems); n > 0; n-- {
+		if matchElems(pelems, elems[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// slashPattern returns pattern with backslashes replaced by forward slashes.
+func slashPattern(pattern string) string {
+	return strings.ReplaceAll(pattern, `\`, "/")
+}
+
+// matchElems matches path elements against pattern elements, where a "**" pattern element matches
+// zero or more path elements.
+func matchElems(pattern, elems []string) bool {
+	if len(pattern) == 0 {
+		return len(elems) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elems); i++ {
+			if matchElems(pattern[1:], elems[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+		return false
+	}
+	return matchElems(pattern[1:], elems[1:])
+}
+
+// tomlKeyValue is a key/value pair parsed from a TOML document. Values are int, bool, string or
+// []string.
+type tomlKeyValue struct {
+	table string
+	entry int // the number, from 1, of the [[table]] entry the pair is in, or 0 in a [table]
+	key   string
+	value any
+	line  int
+}
+
+// parseTOML parses the subset of TOML used by config files: [table] and [[table]] headers, bare or
+// quoted keys, and integer, boolean, string and string array values. Arrays may span several lines.
+func parseTOML(src string) ([]tomlKeyValue, error) {
+	var out []tomlKeyValue
+	table, entry := "", 0
+	entries := make(map[string]int) // number of entries of each [[table]]
+	seen := make(map[string]bool)
+	tables := make(map[string]bool) // the [table] headers so far
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			header, end := line[1:], "]"
+			if strings.HasPrefix(header, "[") {
+				header, end = header[1:], "]]"
+			}
+			name, rest, ok := strings.Cut(header, end)
+			if !ok || strings.ContainsAny(name, "[]") || !isTOMLComment(rest) {
+				return nil, fmt.Errorf("line %d: invalid table header", lineNum)
+			}
+			table, entry = strings.TrimSpace(name), 0
+			if tables[table] || (end == "]" && entries[table] > 0) {
+				return nil, fmt.Errorf("line %d: duplicate table [%s]", lineNum, table)
+			}
+			if end == "]]" {
+				entries[table]++
+				entry = entries[table]
+			} else {
+				tables[table] = true
+			}
+			continue
+		}
+		key, rest, err := parseTOMLKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "=") {
+			return nil, fmt.Errorf("line %d: expected '=' after key", lineNum)
+		}
+		rest = strings.TrimSpace(rest[1:])
+		// Arrays may continue onto the following lines until the closing bracket.
+		for strings.HasPrefix(rest, "[") && !tomlArrayClosed(rest) && i+1 < len(lines) {
+			i++
+			rest += "\n" + lines[i]
+		}
+		value, rest, err := parseTOMLValue(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		if !isTOMLComment(rest) {
+			return nil, fmt.Errorf("line %d: unexpected text after value", lineNum)
+		}
+		id := fmt.Sprintf("%s\x00%d\x00%s", table, entry, key)
+		if seen[id] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNum, key)
+		}
+		seen[id] = true
+		out = append(out, tomlKeyValue{table: table, entry: entry, key: key, value: value, line: lineNum})
+	}
+	return out, nil
+}
+
+// parseTOMLKey parses a bare or quoted key at the start of s and returns the rest of s.
+func parseTOMLKey(s string) (key, rest string, err error) {
+	if s[0] == '"' || s[0] == '\'' {
+		return parseTOMLString(s)
+	}
+	end := 0
+	for end < len(s) && isTOMLBareKeyChar(s[end]) {
+		end++
+	}
+	if end == 0 {
+		return "", "", errors.New("invalid key")
+	}
+	return s[:end], s[end:], nil
+}
+
+// isTOMLBareKeyChar reports whether c may appear in a bare (unquoted) key.
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// parseTOMLValue parses the value at the start of s and returns the rest of s.
+func parseTOMLValue(s string) (any, string, error) {
+	switch {
+	case s == "":
+		return nil, "", errors.New("missing value")
+	case s[0] == '"' || s[0] == '\'':
+		return parseTOMLString(s)
+	case s[0] == '[':
+		var list []string
+		rest := s[1:]
+		for {
+			rest = trimTOMLSpace(rest)
+			if strings.HasPrefix(rest, "]") {
+				return list, rest[1:], nil
+			}
+			if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+				return nil, "", errors.New("arrays may only contain strings")
+			}
+			v, r, err := parseTOMLString(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			list = append(list, v)
+			rest = trimTOMLSpace(r)
+			if strings.HasPrefix(rest, ",") {
+				rest = rest[1:]
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", errors.New("expected ',' or ']' in array")
+			}
+		}
+	}
+	end := strings.IndexAny(s, " \t#")
+	if end < 0 {
+		end = len(s)
+	}
+	word, rest := s[:end], s[end:]
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(word, "_", ""))
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported value %q", word)
+	}
+	return n, rest, nil
+}
+
+// parseTOMLString parses a basic ("...") or literal ('...') string at the start of s and returns
+// the rest of s. Basic strings support the common escapes.
+func parseTOMLString(s string) (string, string, error) {
+	quote := s[0]
+	if quote == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 || strings.Contains(s[1:end+1], "\n") {
+			return "", "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\n':
+			return "", "", errors.New("unterminated string")
+		case '\\':
+			if i+1 >= len(s) {
+				return "", "", errors.New("unterminated string")
+			}
+			i++
+			switch s[i] {
+			case '"', '\\':
+				b.WriteByte(s[i])
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				return "", "", fmt.Errorf("unsupported escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+// trimTOMLSpace trims leading whitespace, newlines and comments.
+func trimTOMLSpace(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\n")
+		if !strings.HasPrefix(s, "#") {
+			return s
+		}
+		_, s, _ = strings.Cut(s, "\n")
+	}
+}
+
+// tomlArrayClosed reports whether s, which starts with "[", contains the closing bracket of the
+// array outside of strings and comments.
+func tomlArrayClosed(s string) bool {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote || c == '\n' {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			nl := strings.IndexByte(s[i:], '\n')
+			if nl < 0 {
+				return false
+			}
+			i += nl
+		case c == ']':
+			return true
+		}
+	}
+	return false
+}
+
+// isTOMLComment reports whether s is empty or only a comment.
+func isTOMLComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
@@ -0,0 +1,282 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	src := `# Project settings.
+column = 80
+tab-width = 8 # tabs are wide here
+min-lines = 2
+exclude = [
+  "vendor",
+  'testdata/**', # golden files
+  "*.pb.go",
+]
+
+[languages]
+"*.tpl" = "gotemplate"
+"notes/*" = "text"
+
+[columns]
+py = 88
+text = 72
+`
+	cfg, err := ParseConfig([]byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, 80, cfg.Column)
+	assert.Equal(t, 8, cfg.TabWidth)
+	assert.Equal(t, 2, cfg.MinLines)
+	assert.Equal(t, []string{"vendor", "testdata/**", "*.pb.go"}, cfg.Exclude)
+	assert.Equal(t, []LanguageOverride{
+		{Pattern: "*.tpl", Language: "gotemplate"},
+		{Pattern: "notes/*", Language: "text"},
+	}, cfg.Languages)
+	assert.Equal(t, map[string]int{"python": 88, "text": 72}, cfg.Columns)
+}
+
+func TestConfigApplyLanguage(t *testing.T) {
+	cfg := &Config{Column: 90, Columns: map[string]int{"python": 88, "text": 72}}
+	python, goLang := LanguageFromName("python"), LanguageFromName("go")
+	assert.Equal(t, 88, cfg.ApplyLanguage(Options{}, python).Column)
+	assert.Equal(t, 72, cfg.ApplyLanguage(Options{}, nil).Column)
+	assert.Equal(t, 90, cfg.ApplyLanguage(Options{}, goLang).Column)
+	// A column set by a flag takes precedence.
+	assert.Equal(t, 60, cfg.ApplyLanguage(Options{Column: 60}, python).Column)
+	var none *Config
+	assert.Equal(t, 0, none.ApplyLanguage(Options{}, python).Column)
+
+	cfg, err := ParseConfig([]byte("[anchored]\npy = [\"mypy:\"]\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x:", "mypy:"}, cfg.ApplyLanguage(Options{Anchored: []string{"x:"}}, python).Anchored)
+	assert.Empty(t, cfg.ApplyLanguage(Options{}, goLang).Anchored)
+}
+
+func TestParseConfig_CustomLanguages(t *testing.T) {
+	src := `[languages]
+"*.rules" = "lisp-dsl"
+
+[columns]
+lisp-dsl = 72
+
+[language.lisp-dsl]
+extensions = [".ldsl"]
+line-markers = [";;", ";"]
+block-start = ["#|"]
+block-end = ["|#"]
+directives = ["lint:"]
+column = 90
+`
+	cfg, err := ParseConfig([]byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, []Language{{
+		Name:        "lisp-dsl",
+		Extensions:  []string{".ldsl"},
+		LineMarkers: []string{";;", ";"},
+		BlockStart:  []string{"#|"},
+		BlockEnd:    []string{"|#"},
+		Directives:  []string{"lint:"},
+		Column:      90,
+	}}, cfg.CustomLanguages)
+	assert.Equal(t, map[string]int{"lisp-dsl": 72}, cfg.Columns)
+	assert.Nil(t, LanguageFromName("lisp-dsl"))
+
+	// The config finds its languages before the registered ones, without registering them.
+	assert.Equal(t, "lisp-dsl", cfg.DetectLanguage("a.ldsl", nil).Name)
+	assert.Equal(t, "lisp-dsl", cfg.LanguageFromName("LDSL").Name)
+	assert.Equal(t, "go", cfg.LanguageFromName("go").Name)
+	cfg.Path = filepath.Join(t.TempDir(), ConfigFileName)
+	lang, ok := cfg.Language(filepath.Join(filepath.Dir(cfg.Path), "a.rules"))
+	assert.True(t, ok)
+	assert.Equal(t, "lisp-dsl", lang.Name)
+	got := Source([]byte(";; one two three four\n(define x 1)\n"), cfg.LanguageFromName("lisp-dsl"), 14, 0)
+	assert.Equal(t, ";; one two\n;; three four\n(define x 1)\n", string(got))
+	assert.Nil(t, LanguageFromName("lisp-dsl"))
+
+	// Another config may define the same language differently, or take over a built-in extension.
+	other, err := ParseConfig([]byte("[language.lisp-dsl]\nextensions = [\".ldsl\", \".go\"]\nline-markers = [\"#\"]\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"#"}, other.DetectLanguage("a.ldsl", nil).LineMarkers)
+	assert.Equal(t, "lisp-dsl", other.DetectLanguage("a.go", nil).Name)
+	assert.Equal(t, []string{";;", ";"}, cfg.DetectLanguage("a.ldsl", nil).LineMarkers)
+	assert.Equal(t, "go", cfg.DetectLanguage("a.go", nil).Name)
+
+	var none *Config
+	assert.Equal(t, "go", none.DetectLanguage("a.go", nil).Name)
+	assert.Nil(t, none.LanguageFromName("lisp-dsl"))
+}
+
+func TestParseConfig_Rules(t *testing.T) {
+	cfg, err := ParseConfig([]byte("[rules]\ntable = true\ndecoration = false\ntable-min-columns = 3\n"))
+	require.NoError(t, err)
+	assert.Equal(t, Rules{On: []string{"table"}, Off: []string{"decoration"}, TableMinColumns: 3}, cfg.Rules)
+
+	opts := cfg.Apply(Options{Rules: Rules{Off: []string{"table"}}})
+	assert.Equal(t, Rules{On: []string{"table"}, Off: []string{"decoration", "table"}, TableMinColumns: 3}, opts.Rules)
+	assert.False(t, opts.Rules.on(SkipTable))
+}
+
+func TestParseConfig_Paths(t *testing.T) {
+	src := `column = 100
+
+[languages]
+"scripts/*" = "python"
+
+[[paths]]
+match = "docs/**"
+column = 80
+preserve-sentence-starts = true
+
+[[paths]]
+match = "third_party"
+skip = true
+
+[[paths]]
+match = "scripts/*"
+language = "shell"
+tab-width = 2
+
+[[paths]]
+match = "docs/legacy"
+column = 120 # never used: docs/** matches first
+`
+	cfg, err := ParseConfig([]byte(src))
+	require.NoError(t, err)
+	require.Len(t, cfg.Paths, 4)
+	assert.Equal(t, PathRule{Match: "docs/**", Column: 80, PreserveSentenceStarts: true}, cfg.Paths[0])
+	dir := t.TempDir()
+	cfg.Path = filepath.Join(dir, ConfigFileName)
+
+	assert.Equal(t, &cfg.Paths[0], cfg.PathRule(filepath.Join(dir, "docs", "legacy", "a.md")))
+	assert.Nil(t, cfg.PathRule(filepath.Join(dir, "main.go")))
+
+	assert.True(t, cfg.Excluded(filepath.Join(dir, "third_party", "x", "a.go")))
+	assert.False(t, cfg.Excluded(filepath.Join(dir, "docs", "a.md")))
+
+	lang, ok := cfg.Language(filepath.Join(dir, "scripts", "build"))
+	assert.True(t, ok)
+	assert.Equal(t, "shell", lang.Name)
+
+	opts, err := ResolveOptions(filepath.Join(dir, "docs", "a.md"), nil, LanguageFromName("markdown"), cfg, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 80, opts.Column)
+	assert.True(t, opts.PreserveSentenceStarts)
+	opts, err = ResolveOptions(filepath.Join(dir, "scripts", "build"), nil, lang, cfg, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, opts.Column)
+	assert.Equal(t, 2, opts.TabWidth)
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"column = 0", "line 1: column must be a positive integer"},
+		{"column = \"80\"", "line 1: column must be a positive integer"},
+		{"\ncolumns = 80", "line 2: unknown key \"columns\""},
+		{"exclude = \"vendor\"", "line 1: exclude must be an array of strings"},
+		{"[languages]\n\"*.x\" = \"klingon\"", "line 2: unknown language \"klingon\""},
+		{"[format]\nx = 1", "line 2: unknown table [format]"},
+		{"[columns]\npython = \"88\"", "line 2: column for \"python\" must be a positive integer"},
+		{"[columns]\nklingon = 80", "line 2: unknown language \"klingon\""},
+		{"[anchored]\ngo = \"+k8s:\"", "line 2: anchored markers for \"go\" must be an array of strings"},
+		{"[anchored]\nklingon = [\"x\"]", "line 2: unknown language \"klingon\""},
+		{"[rules]\nascii-art = true", "line 2: unknown rule \"ascii-art\""},
+		{"[rules]\ntable = 1", "line 2: rule table must be true or false"},
+		{"[rules]\ntable-min-columns = 0", "line 2: table-min-columns must be a positive integer"},
+		{"[language.x]\nmarkers = [\"#\"]", "line 2: unknown language key \"markers\""},
+		{"[language.x]\nline-markers = \"#\"", "line 2: invalid value for line-markers"},
+		{"[language.x]\nextensions = [\"x\"]", "language x: extension \"x\" must be lower case and start with a dot"},
+		{"[language.x]\nextensions = [\".x\"]", "language x has no line-markers or block-start"},
+		{"column = 80\ncolumn = 90", "line 2: duplicate key \"column\""},
+		{"[columns]\ngo = 80\n\n[columns]\npython = 79", "line 4: duplicate table [columns]"},
+		{"[language.x]\nline-markers = [\"#\"]\n[language.x]\ncolumn = 80", "line 3: duplicate table [language.x]"},
+		{"[[paths]]\nmatch = \"a\"\n[paths]\nmatch = \"b\"", "line 3: duplicate table [paths]"},
+		{"column 80", "line 1: expected '=' after key"},
+		{"exclude = [\"a\" \"b\"]", "line 1: expected ',' or ']' in array"},
+		{"exclude = [\"[\"]", "line 1: invalid pattern"},
+		{"[[paths]]\ncolumn = 80", "[[paths]] entry 1 has no match"},
+		{"[[paths]]\nmatch = \"a\"\nskip = 1", "line 3: invalid value for skip"},
+		{"[[paths]]\nmatch = \"a\"\nlanguage = \"klingon\"", "line 3: unknown language \"klingon\""},
+		{"[[paths]]\nmatch = \"a\"\nexclude = true", "line 3: unknown paths key \"exclude\""},
+		{"[paths]\nmatch = \"a\"", "line 2: paths must be an array of tables, [[paths]]"},
+		{"[[columns]]\ngo = 80", "line 2: unknown table [[columns]]"},
+	}
+	for _, tt := range tests {
+		_, err := ParseConfig([]byte(tt.src))
+		if assert.Error(t, err, tt.src) {
+			assert.Contains(t, err.Error(), tt.want, tt.src)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"vendor", "vendor/x/y.go", true},
+		{"vendor", "pkg/vendor/y.go", true},
+		{"vendor", "vendors/y.go", false},
+		{"*.pb.go", "api/v1/service.pb.go", true},
+		{"*.pb.go", "api/v1/service.go", false},
+		{"testdata/**", "testdata/a/b.go", true},
+		{"testdata/**", "wrap/testdata/b.go", false},
+		{"**/testdata", "wrap/testdata/b.go", true},
+		{"internal/gen", "internal/gen/x.go", true},
+		{"internal/gen", "x/internal/gen/x.go", false},
+		{"scripts/*.sh", "scripts/build.sh", true},
+		{"scripts/*.sh", "scripts/ci/build.sh", false},
+		{`internal\gen`, "internal/gen/x.go", true},
+		{`scripts\*.sh`, "scripts/build.sh", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchPattern(tt.pattern, tt.rel), "matchPattern(%q, %q)", tt.pattern, tt.rel)
+	}
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+
+	cfg, err := FindConfig(sub)
+	require.NoError(t, err)
+	// A config in a parent of the temp dir is possible in principle, but it can't be ours.
+	if cfg != nil {
+		assert.NotEqual(t, filepath.Join(root, ConfigFileName), cfg.Path)
+	}
+
+	path := filepath.Join(root, ConfigFileName)
+	require.NoError(t, os.WriteFile(path, []byte("column = 72\nexclude = [\"gen\"]\n\n[languages]\n\"*.tpl\" = \"gotemplate\"\n"), 0o644))
+	cfg, err = ConfigForFile(filepath.Join(sub, "main.go"))
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+	assert.Equal(t, path, cfg.Path)
+
+	assert.True(t, cfg.Excluded(filepath.Join(root, "gen", "x.go")))
+	assert.False(t, cfg.Excluded(filepath.Join(sub, "main.go")))
+	lang, ok := cfg.Language(filepath.Join(sub, "page.tpl"))
+	assert.True(t, ok)
+	assert.Equal(t, "gotemplate", lang.Name)
+	_, ok = cfg.Language(filepath.Join(sub, "main.go"))
+	assert.False(t, ok)
+
+	// Flags win over the config.
+	assert.Equal(t, Options{Column: 72}, cfg.Apply(Options{}))
+	assert.Equal(t, Options{Column: 90, TabWidth: 2}, cfg.Apply(Options{Column: 90, TabWidth: 2}))
+	var none *Config
+	assert.Equal(t, Options{TabWidth: 2}, none.Apply(Options{TabWidth: 2}))
+
+	require.NoError(t, os.WriteFile(path, []byte("column = -1\n"), 0o644))
+	_, err = FindConfig(sub)
+	assert.ErrorContains(t, err, ConfigFileName+": line 1: column must be a positive integer")
+}
@@ -0,0 +1,35 @@
+package wrap
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDependencies checks that the package only imports the standard library, goldmark and this
+// module's internal packages, so that programs using it do not build the command's dependencies.
+func TestDependencies(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+		require.NoError(t, err)
+		for _, imp := range f.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			require.NoError(t, err)
+			first, _, _ := strings.Cut(path, "/")
+			std := !strings.Contains(first, ".")
+			allowed := strings.HasPrefix(path, "github.com/yuin/goldmark") || strings.HasPrefix(path, "github.com/mfridman/rewrap/internal/")
+			assert.True(t, std || allowed, "%s imports %s", file, path)
+		}
+	}
+}
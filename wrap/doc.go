@@ -0,0 +1,31 @@
+// Package wrap rewraps comment blocks in source code, and markup such as Markdown and plain text,
+// to a column. It is the engine of the rewrap command, which lives in cmd/rewrap, and depends only
+// on the standard library and goldmark, never on the command's own dependencies.
+//
+// # Rewrapping
+//
+// [SourceWithOptions] rewraps a whole file in a [Language], or plain text for a nil language, as
+// set by [Options]. A [Wrapper], made with [New], holds a language and options for repeated use.
+// [ResolveOptions] fills in the column and tab width for a file as the command does, from
+// modelines, a [Config] (.rewrap.toml) and .editorconfig files.
+//
+// # Languages
+//
+// The built-in languages are found with [DetectLanguage], [LanguageFromFilename] and
+// [LanguageFromName], and [RegisterLanguage] adds more. [DefaultProfile] gives the settings that
+// tools writing comments themselves need to produce comments that rewrap leaves alone.
+//
+// # Reports
+//
+// Instead of the rewrapped source, [Diff] returns the changes as [Edit] values, [Blocks] describes
+// each comment block as a [Block], saying why any were left alone, [LongLines] lists the lines
+// wider than the column as [LongLine] values, and [Explain] returns an [Explanation] of a single
+// line.
+//
+// # Compatibility
+//
+// The exported API of this package follows semantic versioning: within a major version of the
+// module, it is only added to. New fields of Options, Language and the report types have zero
+// values that keep the behavior as it was, and new Skip constants may be reported. What rewrapping
+// does to a given input is not part of the API, and may improve in any release.
+package wrap
@@ -0,0 +1,337 @@
+package wrap
+
+import (
+	"regexp"
+	"strings"
+)
+
+// defPattern matches the first line of a Python/Starlark function or class definition.
+var defPattern = regexp.MustCompile(`^\s*(async\s+def|def|class)\s`)
+
+// sectionHeaderPattern matches a Google-style docstring section header whose body is a list of
+// entries, such as "Args:" or "Returns:". Sections like "Example:" usually hold code and are not
+// included.
+var sectionHeaderPattern = regexp.MustCompile(`^(Args|Arguments|Attributes|Keyword Args|Keyword Arguments|Parameters|Params|Returns|Return|Yields|Raises|Outputs|Note|Notes|Todo|Warning|Warnings|Deprecated|See Also):$`)
+
+// sectionEntryPattern matches the start of a named docstring section entry such as "name: text" or
+// "name (str): text".
+var sectionEntryPattern = regexp.MustCompile(`^\*{0,2}[A-Za-z_][\w.]*( \([^)]*\))?:(\s|$)`)
+
+// tryDocstring tries to parse a docstring starting at line index i. A docstring is a triple-quoted
+// string that is the first statement of the file or of a def/class body. The string may have a raw
+// ("r") or unicode ("u") prefix; byte strings and f-strings are not docstrings, and other
+// triple-quoted strings are data that is never rewrapped. In a language with DocAttrs, a docstring
+// is instead the value of one of them, such as Elixir's `@doc """`, which may be a "~S" or "~s"
+// sigil.
+func tryDocstring(lines []string, i int, lang *Language) (segment, int) {
+	trimmed := strings.TrimLeft(lines[i], " \t")
+	indent := lines[i][:len(lines[i])-len(trimmed)]
+	body := trimmed
+	if len(lang.DocAttrs) > 0 {
+		attr := longestPrefix(body, lang.DocAttrs)
+		value := strings.TrimLeft(body[len(attr):], " \t")
+		if attr == "" || len(value) == len(body)-len(attr) {
+			return segment{}, i
+		}
+		body = strings.TrimPrefix(strings.TrimPrefix(value, "~S"), "~s")
+	} else if body != "" && strings.ContainsRune("rRuU", rune(body[0])) {
+		body = body[1:]
+	}
+	quote := ""
+	for _, q := range lang.Docstrings {
+		if strings.HasPrefix(body, q) {
+			quote = q
+			break
+		}
+	}
+	if quote == "" || (len(lang.DocAttrs) == 0 && !isDocstringPosition(lines, i, lang)) {
+		return segment{}, i
+	}
+
+	// Find the closing quote, which must end its line.
+	rest := body[len(quote):]
+	if idx := strings.Index(rest, quote); idx >= 0 {
+		if strings.TrimSpace(rest[idx+len(quote):]) != "" {
+			return segment{}, i
+		}
+		return segment{typ: segmentDocstring, lines: lines[i : i+1], indent: indent, marker: quote}, i + 1
+	}
+	for j := i + 1; j < len(lines); j++ {
+		idx := strings.Index(lines[j], quote)
+		if idx < 0 {
+			continue
+		}
+		if strings.TrimSpace(lines[j][idx+len(quote):]) != "" {
+			return segment{}, i
+		}
+		return segment{typ: segmentDocstring, lines: lines[i : j+1], indent: indent, marker: quote}, j + 1
+	}
+	return segment{}, i
+}
+
+// isDocstringPosition reports whether line i is the first statement of the file or of a def/class
+// body. Blank lines and comments before it are ignored.
+func isDocstringPosition(lines []string, i int, lang *Language) bool {
+	indent := leadingWidth(lines[i])
+	prev := -1
+	for j := i - 1; j >= 0; j-- {
+		if t := strings.TrimSpace(lines[j]); t != "" && !isCommentLine(t, lang) {
+			prev = j
+			break
+		}
+	}
+	if prev < 0 {
+		return true // module docstring
+	}
+	if !strings.HasSuffix(stripTrailingComment(lines[prev]), ":") {
+		return false
+	}
+	// Walk back to the line that opened the block (the signature may span several lines) and
+	// check that it is a def or class.
+	for j := prev; j >= 0; j-- {
+		t := strings.TrimSpace(lines[j])
+		if t == "" || isCommentLine(t, lang) {
+			continue
+		}
+		if leadingWidth(lines[j]) < indent {
+			if strings.HasPrefix(t, ")") || strings.HasPrefix(t, "]") {
+				continue // the end of a signature that spans several lines, such as ") -> int:"
+			}
+			return defPattern.MatchString(lines[j])
+		}
+	}
+	return false
+}
+
+// isCommentLine reports whether the trimmed line starts with one of the language's line markers.
+func isCommentLine(trimmed string, lang *Language) bool {
+	for _, m := range lang.LineMarkers {
+		if strings.HasPrefix(trimmed, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripTrailingComment removes a trailing "#" comment and whitespace from a line of code. Quoted
+// strings are skipped so that a "#" inside a string is not mistaken for a comment.
+func stripTrailingComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// leadingWidth returns the number of leading space and tab bytes in s.
+func leadingWidth(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " \t"))
+}
+
+// rewrapDocstring rewraps the prose in a triple-quoted docstring. Paragraphs are rewrapped at the
+// indentation of the docstring body, section entries such as "name: description" under a header
+// like "Args:" keep a hanging indent, and anything more deeply indented (code examples, nested
+// structure) or doctest blocks (">>>") are kept verbatim. The position of the opening and closing
+// quotes is preserved, except that a one-line docstring that runs past the column is expanded into
+// the multi-line form, with the closing quotes on a line of their own.
+func rewrapDocstring(seg segment, opts Options) []string {
+	quote := seg.marker
+	if len(seg.lines) == 1 {
+		line := strings.TrimRight(seg.lines[0], " \t")
+		end := strings.LastIndex(line, quote)
+		if displayWidth(line, opts.TabWidth) <= opts.Column || end+len(quote) != len(line) || end <= strings.Index(line, quote) {
+			return seg.lines
+		}
+		seg.lines = []string{line[:end], seg.indent + quote}
+	}
+	first := strings.TrimLeft(seg.lines[0], " \t")
+	open := strings.Index(first, quote) + len(quote) // after any string prefix and the quotes
+	head := seg.indent + first[:open]
+	firstText := strings.TrimSpace(first[open:])
+
+	last := seg.lines[len(seg.lines)-1]
+	lastText := strings.TrimRight(last[:strings.LastIndex(last, quote)], " \t")
+	closerOwnLine := strings.TrimSpace(lastText) == ""
+
+	// Collect the body lines (everything after the first line), and their common indentation.
+	body := append([]string{}, seg.lines[1:len(seg.lines)-1]...)
+	if !closerOwnLine {
+		body = append(body, lastText)
+	}
+	bodyIndent := ""
+	minWidth := -1
+	for _, line := range body {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if w := leadingWidth(line); minWidth < 0 || w < minWidth {
+			minWidth = w
+			bodyIndent = line[:w]
+		}
+	}
+	if minWidth < 0 {
+		bodyIndent = seg.indent
+	}
+
+	var content []string
+	if firstText != "" {
+		content = append(content, firstText)
+	}
+	for _, line := range body {
+		if strings.TrimSpace(line) == "" {
+			content = append(content, "")
+			continue
+		}
+		if !strings.HasPrefix(line, bodyIndent) {
+			return seg.lines
+		}
+		content = append(content, strings.TrimRight(line[len(bodyIndent):], " \t"))
+	}
+
+	// Split into blocks of non-blank lines; a nil block stands for a blank line.
+	var blocks [][]string
+	var current []string
+	for _, line := range content {
+		if line == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, current)
+				current = nil
+			}
+			blocks = append(blocks, nil)
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, current)
+	}
+
+	var out []string
+	if firstText == "" {
+		out = append(out, seg.lines[0])
+	}
+	for bi, b := range blocks {
+		if len(b) == 0 {
+			out = append(out, "")
+			continue
+		}
+		firstPrefix := bodyIndent
+		if bi == 0 && firstText != "" {
+			firstPrefix = head
+		}
+		suffix := ""
+		if bi == len(blocks)-1 && !closerOwnLine {
+			suffix = quote
+		}
+		out = append(out, rewrapDocBlock(b, firstPrefix, bodyIndent, suffix, opts)...)
+	}
+	if closerOwnLine {
+		out = append(out, last)
+	}
+	return out
+}
+
+// rewrapDocBlock rewraps one block of docstring lines. The first output line starts with
+// firstPrefix (which may include the opening quotes), all others with bodyIndent plus the line's own
+// relative indentation. If suffix is non-empty, it is appended to the last line.
+func rewrapDocBlock(lines []string, firstPrefix, bodyIndent, suffix string, opts Options) []string {
+	verbatim := func() []string {
+		out := make([]string, len(lines))
+		for i, line := range lines {
+			if i == 0 {
+				out[i] = firstPrefix + line
+			} else {
+				out[i] = bodyIndent + line
+			}
+		}
+		out[len(out)-1] += suffix
+		return out
+	}
+
+	if len(lines) > 1 && sectionHeaderPattern.MatchString(lines[0]) {
+		return rewrapDocSection(lines, firstPrefix, bodyIndent, suffix, opts, verbatim)
+	}
+	for _, line := range lines {
+		if leadingWidth(line) > 0 || strings.HasPrefix(line, ">>>") {
+			return verbatim()
+		}
+	}
+	return wrapItems(strings.Join(lines, "\n")+suffix, firstPrefix, bodyIndent, nil, opts)
+}
+
+// rewrapDocSection rewraps a section such as "Args:" followed by indented entries. Entries that
+// start with "name:" are wrapped with a hanging indent; other entries are wrapped as paragraphs.
+func rewrapDocSection(lines []string, firstPrefix, bodyIndent, suffix string, opts Options, verbatim func() []string) []string {
+	entryWidth := -1
+	for _, line := range lines[1:] {
+		if w := leadingWidth(line); entryWidth < 0 || w < entryWidth {
+			entryWidth = w
+		}
+	}
+	if entryWidth == 0 {
+		return verbatim()
+	}
+	entryIndent := lines[1][:entryWidth]
+
+	type entry struct {
+		text    []string
+		named   bool
+		hangStr string
+	}
+	var entries []entry
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, entryIndent) {
+			return verbatim()
+		}
+		rel := line[entryWidth:]
+		relWidth := leadingWidth(rel)
+		if relWidth == 0 && (len(entries) == 0 || sectionEntryPattern.MatchString(rel)) {
+			entries = append(entries, entry{text: []string{rel}, named: sectionEntryPattern.MatchString(rel)})
+			continue
+		}
+		if len(entries) == 0 {
+			return verbatim()
+		}
+		e := &entries[len(entries)-1]
+		if relWidth > 0 {
+			if e.hangStr == "" {
+				e.hangStr = rel[:relWidth]
+			} else if rel[:relWidth] != e.hangStr {
+				return verbatim()
+			}
+		}
+		e.text = append(e.text, strings.TrimSpace(rel))
+	}
+
+	out := []string{firstPrefix + lines[0]}
+	for i, e := range entries {
+		hang := ""
+		if e.named {
+			hang = e.hangStr
+			if hang == "" {
+				hang = "    "
+			}
+		} else if e.hangStr != "" {
+			return verbatim()
+		}
+		text := strings.Join(e.text, " ")
+		if i == len(entries)-1 {
+			text += suffix
+		}
+		prefix := bodyIndent + entryIndent
+		out = append(out, wrapText(text, prefix, prefix+hang, opts)...)
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDocstringPosition(t *testing.T) {
+	lang := LanguageFromName("starlark")
+	tests := []struct {
+		name  string
+		input string
+		line  int
+		want  bool
+	}{
+		{"module", "# comment\n\n\"\"\"Doc.\"\"\"", 2, true},
+		{"function", "def f():\n    \"\"\"Doc.\"\"\"", 1, true},
+		{"multi-line signature", "def f(\n        a,\n        b):  # comment\n    \"\"\"Doc.\"\"\"", 3, true},
+		{"signature ending on its own line", "def f(\n    a,\n) -> int:\n    \"\"\"Doc.\"\"\"", 3, true},
+		{"subscripted return type", "def f(\n    a,\n) -> dict[\n    str, int\n]:\n    \"\"\"Doc.\"\"\"", 5, true},
+		{"class", "class C(object):\n    \"\"\"Doc.\"\"\"", 1, true},
+		{"assignment", "x = 1\ny = \"\"\"not doc\"\"\"", 1, false},
+		{"second statement", "def f():\n    x = 1\n    \"\"\"not doc\"\"\"", 2, false},
+		{"if block", "if x:\n    \"\"\"not doc\"\"\"", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(tt.input, "\n")
+			assert.Equal(t, tt.want, isDocstringPosition(lines, tt.line, lang))
+		})
+	}
+}
+
+func TestTryDocstring_DocAttrs(t *testing.T) {
+	lang := LanguageFromName("elixir")
+	tests := []struct {
+		name  string
+		input string
+		want  int // index after the docstring, or 0 if there is none
+	}{
+		{"doc", "@doc \"\"\"\nText.\n\"\"\"", 3},
+		{"moduledoc sigil", "  @moduledoc ~S\"\"\"\n  Text.\n  \"\"\"", 3},
+		{"doc false", "@doc false", 0},
+		{"other attribute", "@doctest \"\"\"\nText.\n\"\"\"", 0},
+		{"assignment", "query = \"\"\"\nText.\n\"\"\"", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, end := tryDocstring(strings.Split(tt.input, "\n"), 0, lang)
+			assert.Equal(t, tt.want, end)
+		})
+	}
+}
+
+func TestRewrapDocstring_OneLine(t *testing.T) {
+	python := LanguageFromName("python")
+	src := "def f():\n    \"\"\"Return the answer, which is computed the slow way.\"\"\"\n    return 42\n"
+	want := "def f():\n    \"\"\"Return the answer, which is computed\n    the slow way.\n    \"\"\"\n    return 42\n"
+	got := Source([]byte(src), python, 44, 4)
+	assert.Equal(t, want, string(got))
+	assert.Equal(t, want, string(Source(got, python, 44, 4)))
+
+	// A one-line docstring that fits, or that has code after its closing quotes, is left alone.
+	for _, src := range []string{
+		"def f():\n    \"\"\"Return the answer.\"\"\"\n",
+		"def f():\n    \"\"\"Return the answer, which is computed the slow way.\"\"\"  # noqa\n",
+	} {
+		assert.Equal(t, src, string(Source([]byte(src), python, 44, 4)))
+	}
+}
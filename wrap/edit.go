@@ -0,0 +1,62 @@
+package wrap
+
+import (
+	"strings"
+
+	"github.com/mfridman/rewrap/internal/diff"
+)
+
+// Edit replaces the bytes src[Start:End] with Text. The replaced bytes are whole lines of src,
+// StartLine up to but not including EndLine, counting from 0, so an insertion has StartLine equal
+// to EndLine.
+type Edit struct {
+	Start, End         int
+	StartLine, EndLine int
+	Text               string
+}
+
+// Diff returns the edits that turn src into the result of SourceWithOptions, in order and not
+// overlapping, or none if src is already wrapped. Lines that do not change are not part of any
+// edit, so that editors can keep the cursor, folds and undo history around them.
+func Diff(src []byte, lang *Language, opts Options) []Edit {
+	return edits(string(src), string(SourceWithOptions(src, lang, opts)))
+}
+
+// Diff returns the edits that turn src into the result of Source.
+func (w *Wrapper) Diff(src []byte) []Edit {
+	return Diff(src, w.lang, w.opts)
+}
+
+// edits returns the line edits that turn old into new.
+func edits(old, new string) []Edit {
+	if old == new {
+		return nil
+	}
+	a, b := splitAfterLines(old), splitAfterLines(new)
+	// offsets[i] is the byte offset of line i of old.
+	offsets := make([]int, len(a)+1)
+	for i, line := range a {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+	var out []Edit
+	for _, h := range diff.Hunks(a, b) {
+		out = append(out, Edit{
+			Start:     offsets[h.A0],
+			End:       offsets[h.A1],
+			StartLine: h.A0,
+			EndLine:   h.A1,
+			Text:      strings.Join(b[h.B0:h.B1], ""),
+		})
+	}
+	return out
+}
+
+// splitAfterLines splits s into lines, each keeping its "\n" terminator. A final line without one
+// is included, but there is no empty line after a final "\n".
+func splitAfterLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,60 @@
+package wrap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	goLang := LanguageFromName("go")
+	src := "package a\n\n// one two three four five six\nvar a = 1\n\n// one two\n// three\nvar b = 2\n"
+	got := Diff([]byte(src), goLang, Options{Column: 20})
+	assert.Equal(t, []Edit{
+		{Start: 11, End: 42, StartLine: 2, EndLine: 3, Text: "// one two three\n// four five six\n"},
+		{Start: 53, End: 73, StartLine: 5, EndLine: 7, Text: "// one two three\n"},
+	}, got)
+	// Applying the edits from the last gives the rewrapped source.
+	result := src
+	for i := len(got) - 1; i >= 0; i-- {
+		result = result[:got[i].Start] + got[i].Text + result[got[i].End:]
+	}
+	assert.Equal(t, string(Source([]byte(src), goLang, 20, 0)), result)
+
+	assert.Empty(t, Diff([]byte(result), goLang, Options{Column: 20}))
+	assert.Equal(t, Diff([]byte(src), goLang, Options{Column: 20}), New(WithLanguage(goLang), WithColumn(20)).Diff([]byte(src)))
+}
+
+func TestDiff_LargeFile(t *testing.T) {
+	t.Parallel()
+
+	// Each of 600 rewrapped comments in a large file is an edit of its own.
+	var src strings.Builder
+	src.WriteString("package a\n\n")
+	for i := range 600 {
+		fmt.Fprintf(&src, "// one two three four five six\nvar v%d = %d\n\n", i, i)
+	}
+	got := Diff([]byte(src.String()), LanguageFromName("go"), Options{Column: 20})
+	require.Len(t, got, 600)
+	for i, e := range got {
+		assert.Equal(t, Edit{
+			Start:     e.Start,
+			End:       e.Start + len("// one two three four five six\n"),
+			StartLine: 2 + 3*i,
+			EndLine:   3 + 3*i,
+			Text:      "// one two three\n// four five six\n",
+		}, e)
+	}
+}
+
+func TestEdits_NoFinalNewline(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []Edit{{Start: 2, End: 3, StartLine: 1, EndLine: 2, Text: "x\ny"}}, edits("a\nb", "a\nx\ny"))
+	assert.Equal(t, []Edit{{Start: 0, End: 0, StartLine: 0, EndLine: 0, Text: "a\n"}}, edits("", "a\n"))
+}
@@ -0,0 +1,244 @@
+package wrap
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EditorConfig holds the EditorConfig (https://editorconfig.org) properties that affect wrapping,
+// resolved for a single file.
+type EditorConfig struct {
+	MaxLineLength int    // max_line_length, 0 if unset or "off"
+	IndentStyle   string // indent_style: "tab", "space" or ""
+	IndentSize    int    // indent_size, 0 if unset or "tab"
+	TabWidth      int    // tab_width, 0 if unset
+}
+
+// EditorConfigForFile resolves the EditorConfig properties for filename by reading the
+// .editorconfig files in the file's directory and its parents, up to and including the first one
+// that sets "root = true". Properties from files closer to filename take precedence.
+func EditorConfigForFile(filename string) (*EditorConfig, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	var files []editorConfigFile
+	for dir := filepath.Dir(abs); ; {
+		path := filepath.Join(dir, ".editorconfig")
+		data, err := os.ReadFile(path)
+		if err == nil {
+			f := parseEditorConfig(string(data))
+			f.dir = dir
+			files = append(files, f)
+			if f.root {
+				break
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := make(map[string]string)
+	for i := len(files) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(files[i].dir, abs)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, s := range files[i].sections {
+			if s.match(rel) {
+				for k, v := range s.props {
+					props[k] = v
+				}
+			}
+		}
+	}
+
+	ec := &EditorConfig{IndentStyle: props["indent_style"]}
+	ec.MaxLineLength, _ = strconv.Atoi(props["max_line_length"])
+	ec.IndentSize, _ = strconv.Atoi(props["indent_size"])
+	ec.TabWidth, _ = strconv.Atoi(props["tab_width"])
+	return ec, nil
+}
+
+// Apply returns opts with an unset (zero) Column taken from max_line_length and an unset TabWidth
+// taken from tab_width, or from indent_size as EditorConfig specifies. indent_style is not applied:
+// it describes indentation, not tabs inside comment text, which are expanded only when
+// Options.ExpandTabs asks for it. A nil EditorConfig returns opts unchanged.
+func (e *EditorConfig) Apply(opts Options) Options {
+	if e == nil {
+		return opts
+	}
+	opts.Column = cmp.Or(opts.Column, e.MaxLineLength)
+	opts.TabWidth = cmp.Or(opts.TabWidth, e.TabWidth, e.IndentSize)
+	return opts
+}
+
+// editorConfigFile is a parsed .editorconfig file.
+type editorConfigFile struct {
+	dir      string
+	root     bool
+	sections []editorConfigSection
+}
+
+// editorConfigSection is a [glob] section and its properties. Keys and values are lowercased.
+type editorConfigSection struct {
+	pattern *regexp.Regexp
+	ranges  [][2]int // bounds of the {n1..n2} ranges in the glob, one per capture group
+	props   map[string]string
+}
+
+// match reports whether the slash-separated path rel, relative to the .editorconfig file, matches
+// the section's glob.
+func (s editorConfigSection) match(rel string) bool {
+	m := s.pattern.FindStringSubmatch(rel)
+	if m == nil {
+		return false
+	}
+	for i, r := range s.ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseEditorConfig parses the contents of an .editorconfig file.
+func parseEditorConfig(src string) editorConfigFile {
+	var f editorConfigFile
+	var current *editorConfigSection
+	for line := range strings.SplitSeq(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			pattern, ranges, err := editorConfigGlob(line[1 : len(line)-1])
+			if err != nil {
+				// Skip sections we cannot parse rather than failing the whole file.
+				current = &editorConfigSection{props: map[string]string{}}
+				continue
+			}
+			f.sections = append(f.sections, editorConfigSection{pattern: pattern, ranges: ranges, props: map[string]string{}})
+			current = &f.sections[len(f.sections)-1]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if current == nil {
+			if key == "root" {
+				f.root = value == "true"
+			}
+			continue
+		}
+		current.props[key] = value
+	}
+	return f
+}
+
+// editorConfigGlob converts an EditorConfig section glob to a regular expression matching paths
+// relative to the .editorconfig file. A glob without a slash matches a file name in any directory.
+// Numeric ranges ({n1..n2}) become capture groups, whose bounds are returned so the caller can check
+// them.
+func editorConfigGlob(glob string) (*regexp.Regexp, [][2]int, error) {
+	var ranges [][2]int
+	var b strings.Builder
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimPrefix(glob, "/")
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	depth := 0 // nesting of {...} alternations
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 || strings.Contains(glob[i+1:i+1+end], "/") {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '{':
+			end := strings.IndexByte(glob[i+1:], '}')
+			if end >= 0 {
+				inner := glob[i+1 : i+1+end]
+				if lo, hi, ok := strings.Cut(inner, ".."); ok {
+					n1, err1 := strconv.Atoi(lo)
+					n2, err2 := strconv.Atoi(hi)
+					if err1 == nil && err2 == nil {
+						ranges = append(ranges, [2]int{min(n1, n2), max(n1, n2)})
+						b.WriteString(`([+-]?\d+)`)
+						i += end + 1
+						continue
+					}
+				}
+				if !strings.ContainsAny(inner, ",{") {
+					// A single choice is matched literally, braces included.
+					b.WriteString(regexp.QuoteMeta("{" + inner + "}"))
+					i += end + 1
+					continue
+				}
+			}
+			b.WriteString("(?:")
+			depth++
+		case '}':
+			if depth == 0 {
+				b.WriteString(`\}`)
+				continue
+			}
+			b.WriteString(")")
+			depth--
+		case ',':
+			if depth == 0 {
+				b.WriteString(",")
+				continue
+			}
+			b.WriteString("|")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	if depth != 0 {
+		return nil, nil, errors.New("unbalanced braces")
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	return re, ranges, err
+}
@@ -0,0 +1,89 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditorConfigGlob(t *testing.T) {
+	tests := []struct {
+		glob string
+		rel  string
+		want bool
+	}{
+		{"*", "main.go", true},
+		{"*", "pkg/main.go", true},
+		{"*.go", "pkg/main.go", true},
+		{"*.go", "pkg/main.py", false},
+		{"*.{js,ts}", "web/app.ts", true},
+		{"*.{js,ts}", "web/app.tsx", false},
+		{"Makefile", "sub/Makefile", true},
+		{"/Makefile", "sub/Makefile", false},
+		{"lib/*.go", "lib/a.go", true},
+		{"lib/*.go", "lib/x/a.go", false},
+		{"lib/**.go", "lib/x/a.go", true},
+		{"file[0-9].txt", "file3.txt", true},
+		{"file[!0-9].txt", "file3.txt", false},
+		{"v{1..10}.txt", "v7.txt", true},
+		{"v{1..10}.txt", "v11.txt", false},
+		{"{single}.txt", "{single}.txt", true},
+	}
+	for _, tt := range tests {
+		re, ranges, err := editorConfigGlob(tt.glob)
+		require.NoError(t, err, tt.glob)
+		s := editorConfigSection{pattern: re, ranges: ranges}
+		assert.Equal(t, tt.want, s.match(tt.rel), "glob %q, path %q", tt.glob, tt.rel)
+	}
+}
+
+func TestEditorConfigForFile(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".editorconfig"), []byte(`root = true
+
+[*]
+indent_style = tab
+indent_size = 8
+max_line_length = 120
+
+[*.py]
+indent_style = space
+indent_size = 4
+
+[*.md]
+max_line_length = off
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, ".editorconfig"), []byte(`
+[*.go]
+max_line_length = 100
+tab_width = 2
+`), 0o644))
+
+	tests := []struct {
+		file string
+		want EditorConfig
+	}{
+		{"main.go", EditorConfig{MaxLineLength: 120, IndentStyle: "tab", IndentSize: 8}},
+		{"sub/main.go", EditorConfig{MaxLineLength: 100, IndentStyle: "tab", IndentSize: 8, TabWidth: 2}},
+		{"sub/app.py", EditorConfig{MaxLineLength: 120, IndentStyle: "space", IndentSize: 4}},
+		{"README.md", EditorConfig{IndentStyle: "tab", IndentSize: 8}},
+	}
+	for _, tt := range tests {
+		ec, err := EditorConfigForFile(filepath.Join(root, tt.file))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, *ec, tt.file)
+	}
+
+	ec, err := EditorConfigForFile(filepath.Join(sub, "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, Options{Column: 100, TabWidth: 2}, ec.Apply(Options{}))
+	assert.Equal(t, Options{Column: 80, TabWidth: 2}, ec.Apply(Options{Column: 80}))
+	ec, err = EditorConfigForFile(filepath.Join(sub, "app.py"))
+	require.NoError(t, err)
+	assert.Equal(t, Options{Column: 120, TabWidth: 4}, ec.Apply(Options{}))
+}
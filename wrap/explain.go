@@ -0,0 +1,100 @@
+package wrap
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// segmentNames are the names of segment types, as reported by Explain.
+var segmentNames = [...]string{
+	segmentCode:      "code",
+	segmentComment:   "comment",
+	segmentBlock:     "block-comment",
+	segmentDocstring: "docstring",
+	segmentPod:       "pod",
+}
+
+// Explanation describes what SourceWithOptions does with one line of a file, and why.
+type Explanation struct {
+	Line      int    `json:"line"`                // the line explained, counted from 1
+	Language  string `json:"language"`            // Language.Name, or "text" for plain text
+	Segment   string `json:"segment"`             // code, comment, block-comment, docstring, pod, or the language of a markup file, or text
+	StartLine int    `json:"start"`               // first line of the segment, counted from 1
+	EndLine   int    `json:"end"`                 // last line of the segment, included
+	Marker    string `json:"marker,omitempty"`    // comment marker, or the opening of a block comment or docstring
+	Indent    string `json:"indent"`              // indentation of the comment, or of the line in code
+	Column    int    `json:"column"`              // wrapping column
+	TabWidth  int    `json:"tab_width"`           // tab display width
+	Changed   bool   `json:"changed"`             // rewrapping changes the segment
+	Skipped   string `json:"skipped,omitempty"`   // why the segment is left as it is (a Skip constant), or ""
+	Protected string `json:"protected,omitempty"` // why the line itself is left as it is (a Skip constant), or ""
+}
+
+// Explain returns what SourceWithOptions does with line (counted from 1) of src: the segment it
+// falls in, the column it is wrapped at, and the rule, if any, that keeps it as it is. It answers
+// "why didn't rewrap touch this?". The second result is false if src has no such line.
+func Explain(src []byte, lang *Language, opts Options, line int) (Explanation, bool) {
+	opts.Column = cmp.Or(opts.Column, lang.column(), DefaultColumn)
+	opts.TabWidth = cmp.Or(opts.TabWidth, DefaultTabWidth)
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	lines := strings.Split(strings.ReplaceAll(text, "\r", "\n"), "\n")
+	if line < 1 || line > len(lines) {
+		return Explanation{}, false
+	}
+	e := Explanation{Line: line, Language: "text", Segment: "text", Column: opts.Column, TabWidth: opts.TabWidth}
+	if lang != nil {
+		e.Language = lang.Name
+	}
+	if opts.Limits.MaxInputSize > 0 && len(src) > opts.Limits.MaxInputSize {
+		e.StartLine, e.EndLine = 1, len(lines)
+		e.Skipped = SkipLimits
+		return e, true
+	}
+	if lang.prose() {
+		// Plain text and markup are rewrapped as a whole.
+		e.Segment = e.Language
+		e.StartLine, e.EndLine = 1, len(lines)
+		e.Changed = string(SourceWithOptions(src, lang, opts)) != string(src)
+		return e, true
+	}
+	inString := stringMask(lines, lang)
+	segs := parseSegments(lines, lang)
+	if opts.MixedIndent {
+		segs = mergeMixedIndents(segs, opts.TabWidth)
+	}
+	n := 0
+	seenComment := false
+	for _, seg := range segs {
+		start := n
+		n += len(seg.lines)
+		var top bool
+		if seg.typ != segmentCode {
+			top = !seenComment && startsFile(lines[:start])
+			seenComment = true
+		}
+		if line > n {
+			continue
+		}
+		e.Segment = segmentNames[seg.typ]
+		e.StartLine, e.EndLine = start+1, n
+		e.Marker = strings.TrimSpace(seg.marker)
+		e.Indent = seg.indent
+		i := line - 1 - start
+		if seg.typ == segmentCode {
+			e.Indent = seg.lines[i][:len(seg.lines[i])-len(strings.TrimLeft(seg.lines[i], " \t"))]
+			if d := rewrapDirective(seg.lines[0], lang); d == "off" || d == "ignore" {
+				e.Skipped = "rewrap:" + d
+			} else {
+				e.Protected = codeLineReason(seg.lines[i], inString != nil && inString[line-1], lang)
+			}
+			return e, true
+		}
+		if e.Skipped = skipReason(seg, start, n, top, opts); e.Skipped == "" {
+			e.Changed = !slices.Equal(rewrapSegment(seg, lang, opts), seg.lines)
+			e.Protected = commentLineReason(seg, seg.lines[i], lang, opts)
+		}
+		return e, true
+	}
+	return e, true
+}
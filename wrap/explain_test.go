@@ -0,0 +1,53 @@
+package wrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	src := []byte(`// Copyright 2026 The Authors.
+
+package a
+
+// f does one two three four five six.
+// ====
+func f() {
+	//go:generate echo
+	// rewrap:ignore
+	// keep   this
+	s := "// not a comment"
+}
+`)
+	goLang := LanguageFromName("go")
+	opts := Options{Column: 20, Rules: Rules{On: []string{SkipLicense}}}
+	tests := []struct {
+		name string
+		line int
+		want Explanation
+	}{
+		{"license", 1, Explanation{Segment: "comment", StartLine: 1, EndLine: 1, Marker: "//", Skipped: SkipLicense}},
+		{"blank", 2, Explanation{Segment: "code", StartLine: 2, EndLine: 4}},
+		{"comment", 5, Explanation{Segment: "comment", StartLine: 5, EndLine: 6, Marker: "//", Changed: true}},
+		{"decoration", 6, Explanation{Segment: "comment", StartLine: 5, EndLine: 6, Marker: "//", Changed: true, Protected: SkipDecoration}},
+		{"directive", 8, Explanation{Segment: "code", StartLine: 7, EndLine: 8, Indent: "\t", Protected: SkipDirective}},
+		{"ignored", 10, Explanation{Segment: "code", StartLine: 9, EndLine: 10, Indent: "\t", Skipped: SkipIgnore}},
+		{"string", 11, Explanation{Segment: "code", StartLine: 11, EndLine: 13, Indent: "\t"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Explain(src, goLang, opts, tt.line)
+			assert.True(t, ok)
+			tt.want.Line, tt.want.Language, tt.want.Column, tt.want.TabWidth = tt.line, "go", 20, DefaultTabWidth
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	got, ok := Explain([]byte("Some text.\n"), nil, Options{}, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Explanation{Line: 1, Language: "text", Segment: "text", StartLine: 1, EndLine: 2, Column: DefaultColumn, TabWidth: DefaultTabWidth}, got)
+
+	_, ok = Explain(src, goLang, Options{}, 14)
+	assert.False(t, ok)
+}
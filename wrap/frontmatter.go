@@ -0,0 +1,73 @@
+package wrap
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// frontMatterField matches a top-level field of YAML front matter, capturing its key and value.
+var frontMatterField = regexp.MustCompile(`^([\w-]+):[ \t]+(\S.*)$`)
+
+// frontMatterEnd returns the number of lines of the YAML ("---") or TOML ("+++") front matter at
+// the start of a Markdown document, including its closing line, or 0 if there is none.
+func frontMatterEnd(lines []string) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	open := strings.TrimRight(lines[0], " \t")
+	if open != "---" && open != "+++" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		if line == open || open == "---" && line == "..." {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// processFrontMatter rewraps a Markdown document that starts with n lines of front matter. The
+// front matter is kept as it is, except for the values of opts.FrontMatterFields, and the rest of
+// the document is rewrapped as Markdown.
+func processFrontMatter(lines []string, n int, opts Options) []byte {
+	// Blank lines in place of the front matter keep the line numbers of opts.Lines.
+	body := processMarkdown([]byte(strings.Repeat("\n", n)+strings.Join(lines[n:], "\n")), opts)
+	out := lines[:n]
+	if lines[0] == "---" && len(opts.FrontMatterFields) > 0 {
+		out = wrapFrontMatter(lines[:n], opts)
+	}
+	return []byte(strings.Join(out, "\n") + string(body[n-1:]))
+}
+
+// wrapFrontMatter rewraps the values of the fields of YAML front matter listed in
+// opts.FrontMatterFields, with their continuation lines indented by two spaces. Only values that
+// are plain or quoted strings are rewrapped; block scalars, lists and mappings are left as they are.
+func wrapFrontMatter(lines []string, opts Options) []string {
+	var out []string
+	for i := 0; i < len(lines); {
+		m := frontMatterField.FindStringSubmatch(lines[i])
+		start := i
+		for i++; i < len(lines)-1 && strings.TrimSpace(lines[i]) != "" && leadingWidth(lines[i]) > 0; i++ {
+		}
+		if m == nil || !slices.Contains(opts.FrontMatterFields, m[1]) || !frontMatterProse(m[2]) ||
+			!opts.selected(start, i) || !opts.withinLimits(lines[start:i]) {
+			out = append(out, lines[start:i]...)
+			continue
+		}
+		text := strings.Join(append([]string{m[2]}, lines[start+1:i]...), "\n")
+		out = append(out, wrapParagraph(text, m[1]+": ", "  ", opts, true)...)
+	}
+	return out
+}
+
+// frontMatterProse reports whether value, the start of a YAML value, is a string that can be
+// rewrapped: a quoted string, or a plain one without the characters that would make it something
+// else when it spans several lines.
+func frontMatterProse(value string) bool {
+	if value[0] == '"' || value[0] == '\'' {
+		return true
+	}
+	return !strings.ContainsAny(value[:1], "|>[{&*!%@`#-?:,]}") && !strings.Contains(value, ": ") && !strings.Contains(value, " #")
+}
@@ -0,0 +1,98 @@
+package wrap
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// moveFieldComments moves each line comment that trails a struct field, or a constant or variable
+// declaration, onto its own line above it when the line is wider than opts.Column, so that it is
+// rewrapped as the declaration's doc comment, at the declaration's indentation. Declarations that
+// already have a doc comment, and trailing directives such as "//nolint", are left alone, so a
+// comment is never merged with another. If src was formatted with gofmt, so is the result, which
+// realigns the trailing comments that are left. It returns src and opts.Lines unchanged if src is
+// not valid Go, and otherwise opts.Lines adjusted for the lines it inserted. See
+// Options.FieldComments.
+func moveFieldComments(src string, opts Options) (string, []LineRange) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return src, opts.Lines
+	}
+	// trailing holds the comments to move: each is the only comment after its declaration, and the
+	// declaration has no doc comment.
+	var trailing []*ast.Comment
+	add := func(doc, comment *ast.CommentGroup) {
+		if doc == nil && comment != nil && len(comment.List) == 1 && strings.HasPrefix(comment.List[0].Text, "//") {
+			trailing = append(trailing, comment.List[0])
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.StructType:
+			for _, field := range n.Fields.List {
+				add(field.Doc, field.Comment)
+			}
+		case *ast.GenDecl:
+			if n.Tok != token.CONST && n.Tok != token.VAR {
+				break
+			}
+			for _, spec := range n.Specs {
+				spec := spec.(*ast.ValueSpec)
+				doc := spec.Doc
+				if !n.Lparen.IsValid() {
+					doc = n.Doc
+				}
+				add(doc, spec.Comment)
+			}
+		}
+		return true
+	})
+
+	lang := LanguageFromName("go")
+	lines := strings.Split(src, "\n")
+	var moved []int // lines, counted from 0, whose comment is moved
+	for _, c := range trailing {
+		pos := fset.Position(c.Pos())
+		n := pos.Line - 1
+		line := lines[n]
+		code := strings.TrimRight(line[:pos.Column-1], " \t")
+		if strings.TrimSpace(code) == "" || displayWidth(line, opts.TabWidth) <= opts.Column ||
+			!opts.selected(n, n+1) || isDirectiveLine(c.Text, lang, lang.Directives) ||
+			isAnchored(strings.TrimPrefix(c.Text, "//"), lang, opts) {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		lines[n] = indent + c.Text + "\n" + code
+		moved = append(moved, n)
+	}
+	if len(moved) == 0 {
+		return src, opts.Lines
+	}
+	result := strings.Join(lines, "\n")
+	if formatted, err := format.Source([]byte(src)); err == nil && bytes.Equal(formatted, []byte(src)) {
+		if formatted, err := format.Source([]byte(result)); err == nil {
+			result = string(formatted)
+		}
+	}
+
+	// Each range grows by the comments moved within it and moves down by those moved above it.
+	ranges := slices.Clone(opts.Lines)
+	slices.Sort(moved)
+	for i, r := range ranges {
+		for _, n := range moved {
+			if n < r.Start-1 {
+				ranges[i].Start++
+			}
+			if n < r.End {
+				ranges[i].End++
+			}
+		}
+	}
+	return result, ranges
+}
@@ -0,0 +1,61 @@
+package wrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceWithOptions_FieldComments(t *testing.T) {
+	goLang := LanguageFromName("go")
+	src := `package a
+
+type T struct {
+	// Name is the name.
+	Name string // a long comment after a field with a doc comment
+	Size int    // Size is the size of the thing, in bytes.
+	N    int    // short
+	X    int    //nolint:unused // a directive stays where it is
+}
+
+const (
+	A = 1 // A is the first constant, with a long comment.
+	B = 2 // B is short.
+)
+`
+	want := `package a
+
+type T struct {
+	// Name is the name.
+	Name string // a long comment after a field with a doc comment
+	// Size is the size of the thing, in
+	// bytes.
+	Size int
+	N    int // short
+	X    int //nolint:unused // a directive stays where it is
+}
+
+const (
+	// A is the first constant, with a
+	// long comment.
+	A = 1
+	B = 2 // B is short.
+)
+`
+	got := SourceWithOptions([]byte(src), goLang, Options{Column: 40, FieldComments: true})
+	assert.Equal(t, want, string(got))
+	got = SourceWithOptions([]byte(src), goLang, Options{Column: 40})
+	assert.Equal(t, src, string(got))
+
+	// Lines after a moved comment are selected where they have moved to.
+	src = "package a\n\nvar a = 1 // one two three four five six\n\n// one two three four five six\nvar b = 2\n"
+	got = SourceWithOptions([]byte(src), goLang, Options{Column: 20, FieldComments: true, Lines: []LineRange{{Start: 5, End: 5}}})
+	assert.Equal(t, "package a\n\nvar a = 1 // one two three four five six\n\n// one two three\n// four five six\nvar b = 2\n", string(got))
+	got = SourceWithOptions([]byte(src), goLang, Options{Column: 20, FieldComments: true, Lines: []LineRange{{Start: 3, End: 5}}})
+	assert.Equal(t, "package a\n\n// one two three\n// four five six\nvar a = 1\n\n// one two three\n// four five six\nvar b = 2\n", string(got))
+
+	// Input that is not valid Go is rewrapped as usual.
+	src = "type T struct {\n\tA int // one two three four five six\n"
+	got = SourceWithOptions([]byte(src), goLang, Options{Column: 20, FieldComments: true})
+	assert.Equal(t, src, string(got))
+}
@@ -43,12 +43,8 @@ func TestGolden(t *testing.T) {
 			src, err := os.ReadFile(inputPath)
 			require.NoError(t, err)
 
-			// Determine language from extension.
-			ext := filepath.Ext(inputPath)
-			var lang *Language
-			if ext != ".txt" {
-				lang = LanguageFromExtension(ext)
-			}
+			// Determine language from the filename and contents.
+			lang := DetectLanguage(inputPath, src)
 
 			got := Source(src, lang, column, 4)
 
@@ -88,11 +84,7 @@ func TestIdempotent(t *testing.T) {
 			src, err := os.ReadFile(inputPath)
 			require.NoError(t, err)
 
-			ext := filepath.Ext(inputPath)
-			var lang *Language
-			if ext != ".txt" {
-				lang = LanguageFromExtension(ext)
-			}
+			lang := DetectLanguage(inputPath, src)
 
 			pass1 := Source(src, lang, column, 4)
 			pass2 := Source(pass1, lang, column, 4)
@@ -1,29 +1,59 @@
 package wrap
 
 import (
+	"errors"
+	"fmt"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 )
 
 // Language defines comment syntax for a programming language.
 type Language struct {
 	Name        string
 	Extensions  []string
-	LineMarkers []string // e.g., "//", "#"
-	BlockStart  []string // e.g., "/*"
-	BlockEnd    []string // e.g., "*/"
-	BlockPrefix string   // e.g., " * " for JavaDoc-style
-	Directives  []string // prefixes (after line marker) that indicate a directive, not a comment
+	Filenames   []string       // file names or patterns for files without a useful extension, e.g., ".htaccess"
+	ExactNames  bool           // Filenames match case-sensitively, e.g., Bazel's "BUILD" but not a script named "build"
+	LineMarkers []string       // e.g., "//", "#"
+	Levels      []string       // longer forms of LineMarkers whose comments are never merged with others, e.g., "//!"; see commentLevel
+	BlockStart  []string       // e.g., "/*"
+	BlockEnd    []string       // e.g., "*/"
+	BlockPrefix string         // e.g., " * " for JavaDoc-style
+	BlockBare   bool           // block comment lines have no prefix and the end marker is not indented, e.g., Ruby's =begin/=end
+	BlockNested bool           // block comments nest, e.g., Elm's {- {- -} -}
+	Pod         bool           // lines starting with "=" and a word start POD documentation, through "=cut", as in Perl
+	Directives  []string       // prefixes (after line marker) that indicate a directive, not a comment
+	Continued   []string       // directives continued by the line comments that follow them, e.g., "go:generate"
+	Anchored    []string       // line comment text that tools look for at the start of a line, e.g., "+kubebuilder:"; see Options.Anchored
+	Docstrings  []string       // docstring quotes, e.g., `"""`; see tryDocstring for where they are recognized
+	DocAttrs    []string       // attributes whose Docstrings value is a docstring wherever it is, e.g., Elixir's "@doc"
+	Strings     []string       // multi-line string delimiters, e.g., `"""`; lines inside them are never comments
+	RawStrings  []string       // like Strings, but without backslash escapes, e.g., Go's "`"
+	RawEnd      []string       // closing delimiters for RawStrings, one for each, if they differ, e.g., Lua's "]]"
+	Heredoc     *regexp.Regexp // matches the start of a heredoc, whose body is never comments; see stringMask
+	DocTags     []string       // prefixes that start a doc tag paragraph, e.g., "@" for "@param"
+	DocMarkers  []string       // markers of the doc comments DocTags apply in, e.g., "///" and "/**"; all comments if empty
+	CodeTags    []string       // doc tags followed by code, kept as it is up to the next tag, e.g., roxygen2's "@examples"
+	Markdown    []string       // comment markers whose body is Markdown, e.g., Elm's "{-|"
+	Column      int            // conventional column for the language, used when none is set; 0 means DefaultColumn
 }
 
-var languages = []Language{
+// languages holds the built-in languages, followed by those added with RegisterLanguage. It and
+// extensionMap are guarded by languagesMu.
+var languages = []*Language{
 	{
 		Name:        "go",
 		Extensions:  []string{".go"},
 		LineMarkers: []string{"//"},
 		BlockStart:  []string{"/*"},
 		BlockEnd:    []string{"*/"},
+		RawStrings:  []string{"`"},
 		Directives:  []string{"go:", "line ", "export ", "nolint"},
+		Continued:   []string{"go:generate"},
+		Anchored:    []string{"+build ", "+genclient", "+k8s:", "+kubebuilder:", "+optional", "nolint:", "lint:ignore "},
 	},
 	{
 		Name:        "c",
@@ -31,6 +61,9 @@ var languages = []Language{
 		LineMarkers: []string{"//"},
 		BlockStart:  []string{"/*"},
 		BlockEnd:    []string{"*/"},
+		Levels:      []string{"//!"}, // Doxygen's inner doc comments
+		DocTags:     []string{"\\", "@"},
+		DocMarkers:  []string{"///", "//!", "/**", "/*!"},
 	},
 	{
 		Name:        "cpp",
@@ -38,6 +71,9 @@ var languages = []Language{
 		LineMarkers: []string{"//"},
 		BlockStart:  []string{"/*"},
 		BlockEnd:    []string{"*/"},
+		Levels:      []string{"//!"}, // Doxygen's inner doc comments
+		DocTags:     []string{"\\", "@"},
+		DocMarkers:  []string{"///", "//!", "/**", "/*!"},
 	},
 	{
 		Name:        "java",
@@ -46,6 +82,8 @@ var languages = []Language{
 		BlockStart:  []string{"/*"},
 		BlockEnd:    []string{"*/"},
 		BlockPrefix: " * ",
+		DocTags:     []string{"@"},
+		DocMarkers:  []string{"/**"},
 	},
 	{
 		Name:        "javascript",
@@ -53,6 +91,10 @@ var languages = []Language{
 		LineMarkers: []string{"//"},
 		BlockStart:  []string{"/*"},
 		BlockEnd:    []string{"*/"},
+		Strings:     []string{"`"},
+		Anchored:    []string{"eslint-", "@ts-", "prettier-ignore", "istanbul ignore", "c8 ignore"},
+		DocTags:     []string{"@"},
+		DocMarkers:  []string{"/**"},
 	},
 	{
 		Name:        "typescript",
@@ -60,71 +102,554 @@ var languages = []Language{
 		LineMarkers: []string{"//"},
 		BlockStart:  []string{"/*"},
 		BlockEnd:    []string{"*/"},
+		Strings:     []string{"`"},
+		Anchored:    []string{"eslint-", "@ts-", "prettier-ignore", "istanbul ignore", "c8 ignore"},
+		DocTags:     []string{"@"},
+		DocMarkers:  []string{"/**"},
+	},
+	{
+		Name:        "solidity",
+		Extensions:  []string{".sol"},
+		LineMarkers: []string{"///", "//"},
+		BlockStart:  []string{"/**", "/*"},
+		BlockEnd:    []string{"*/", "*/"},
+		Directives:  []string{" SPDX-License-Identifier:"},
+		DocTags:     []string{"@"},
+		DocMarkers:  []string{"///", "/**"},
+	},
+	{
+		Name:        "protobuf",
+		Extensions:  []string{".proto"},
+		LineMarkers: []string{"//"},
+		BlockStart:  []string{"/*"},
+		BlockEnd:    []string{"*/"},
+		Anchored:    []string{"buf:lint:ignore", "protolint:", "clang-format "},
+	},
+	{
+		// Both "#" and "//" comments are common, sometimes in the same file; a change of marker
+		// starts a new comment block.
+		Name:        "hcl",
+		Extensions:  []string{".tf", ".hcl", ".tfvars"},
+		LineMarkers: []string{"#", "//"},
+		BlockStart:  []string{"/*"},
+		BlockEnd:    []string{"*/"},
+		Heredoc:     shellHeredoc,
+		Anchored:    []string{"tflint-ignore:", "tfsec:ignore:", "trivy:ignore:", "checkov:skip="},
+	},
+	{
+		Name:        "kotlin",
+		Extensions:  []string{".kt", ".kts"},
+		LineMarkers: []string{"//"},
+		BlockStart:  []string{"/**", "/*"},
+		BlockEnd:    []string{"*/", "*/"},
+		BlockNested: true,
+		RawStrings:  []string{`"""`},
+		Anchored:    []string{"ktlint-", "noinspection "},
+		DocTags:     []string{"@"},
+		DocMarkers:  []string{"/**"},
+	},
+	{
+		// Scaladoc's "*" continuation lines line up under the second "*" of "/**", which
+		// rewrapBlockComment keeps.
+		Name:        "scala",
+		Extensions:  []string{".scala", ".sc", ".sbt"},
+		LineMarkers: []string{"//"},
+		BlockStart:  []string{"/**", "/*"},
+		BlockEnd:    []string{"*/", "*/"},
+		BlockNested: true,
+		RawStrings:  []string{`"""`},
+		Anchored:    []string{"scalafmt:", "scalastyle:", "scalafix:", "noinspection "},
+		DocTags:     []string{"@"},
+		DocMarkers:  []string{"/**"},
+	},
+	{
+		Name:        "php",
+		Extensions:  []string{".php", ".phtml"},
+		LineMarkers: []string{"//", "#"},
+		BlockStart:  []string{"/**", "/*"},
+		BlockEnd:    []string{"*/", "*/"},
+		Directives:  []string{"["}, // attributes, such as "#[Route('/')]"
+		Anchored:    []string{"phpcs:", "@phpstan-", "@psalm-", "@codeCoverageIgnore"},
+		DocTags:     []string{"@"},
+		DocMarkers:  []string{"/**"},
+	},
+	{
+		// Only the prose inside template comments is rewrapped; actions are code.
+		Name:       "gotemplate",
+		Extensions: []string{".tmpl", ".gotmpl"},
+		BlockStart: []string{"{{/*", "{{- /*"},
+		BlockEnd:   []string{"*/}}", "*/ -}}"},
+		BlockBare:  true,
+	},
+	{
+		Name:       "gohtml",
+		Extensions: []string{".gohtml"},
+		BlockStart: []string{"{{/*", "{{- /*", "<!--"},
+		BlockEnd:   []string{"*/}}", "*/ -}}", "-->"},
+		BlockBare:  true,
 	},
 	{
 		Name:        "python",
-		Extensions:  []string{".py"},
+		Extensions:  []string{".py", ".pyi"},
 		LineMarkers: []string{"#"},
+		Levels:      []string{"#:"}, // Sphinx attribute doc comments
+		Docstrings:  []string{`"""`, `'''`},
+		Strings:     []string{`"""`, `'''`},
+		Anchored:    []string{"noqa", "nosec", "type:", "pylint:", "pyright:", "fmt:"},
+		Column:      79, // PEP 8
 	},
 	{
 		Name:        "shell",
 		Extensions:  []string{".sh", ".bash", ".zsh"},
 		LineMarkers: []string{"#"},
+		Anchored:    []string{"shellcheck "},
+		Heredoc:     shellHeredoc,
 	},
 	{
 		Name:        "ruby",
 		Extensions:  []string{".rb"},
 		LineMarkers: []string{"#"},
+		BlockStart:  []string{"=begin"},
+		BlockEnd:    []string{"=end"},
+		BlockBare:   true,
+		DocTags:     []string{"@"},
+		Anchored:    []string{"rubocop:", ":nocov:"},
+		Heredoc:     rubyHeredoc,
+	},
+	{
+		Name:        "perl",
+		Extensions:  []string{".pl", ".pm", ".t", ".pod"},
+		LineMarkers: []string{"#"},
+		Pod:         true,
+		Anchored:    []string{"no critic", "use critic", "<<<", ">>>"}, // Perl::Critic and perltidy
+		Heredoc:     rubyHeredoc,
+	},
+	{
+		Name:        "r",
+		Extensions:  []string{".r"},
+		LineMarkers: []string{"#"},
+		Levels:      []string{"#'"}, // roxygen2 documentation
+		DocTags:     []string{"@"},
+		CodeTags:    []string{"@examples", "@examplesIf", "@usage"},
+		Anchored:    []string{"nolint"}, // lintr
+		Column:      80,                 // tidyverse style guide
+	},
+	{
+		// Assembly comment syntax depends on the assembler. GNU as and Go's assembler take "#" (on
+		// x86), "//" and "/* */" comments in .s files, which often go through the C preprocessor;
+		// NASM and MASM take ";" comments in .asm files. Map other files to a dialect with
+		// [languages] in .rewrap.toml, or define one with [language.NAME].
+		Name:        "gas",
+		Extensions:  []string{".s"},
+		LineMarkers: []string{"#", "//"},
+		BlockStart:  []string{"/*"},
+		BlockEnd:    []string{"*/"},
+		Directives:  []string{"include", "define", "undef", "ifdef", "ifndef", "if ", "elif", "else", "endif", "error", "pragma"},
 	},
 	{
+		Name:        "nasm",
+		Extensions:  []string{".asm", ".nasm"},
+		LineMarkers: []string{";"},
+	},
+	{
+		// Doc comments are Markdown, as rustdoc renders them.
 		Name:        "rust",
 		Extensions:  []string{".rs"},
-		LineMarkers: []string{"//"},
+		LineMarkers: []string{"//!", "///", "//"},
+		BlockStart:  []string{"/*"},
+		BlockEnd:    []string{"*/"},
+		Markdown:    []string{"//!", "///"},
+	},
+	{
+		Name:        "elm",
+		Extensions:  []string{".elm"},
+		LineMarkers: []string{"--"},
+		BlockStart:  []string{"{-|", "{-"},
+		BlockEnd:    []string{"-}"},
+		BlockBare:   true,
+		BlockNested: true,
+		DocTags:     []string{"@"},
+		Markdown:    []string{"{-|"},
+	},
+	{
+		Name:        "lua",
+		Extensions:  []string{".lua"},
+		LineMarkers: []string{"---", "--"},
+		// Long brackets may have any number of "=" signs; levels up to three cover real code.
+		BlockStart: []string{"--[[", "--[=[", "--[==[", "--[===["},
+		BlockEnd:   []string{"]]", "]=]", "]==]", "]===]"},
+		BlockBare:  true,
+		RawStrings: []string{"[[", "[=[", "[==[", "[===["},
+		RawEnd:     []string{"]]", "]=]", "]==]", "]===]"},
+		DocTags:    []string{"@"},
+	},
+	{
+		Name:        "fsharp",
+		Extensions:  []string{".fs", ".fsi", ".fsx"},
+		LineMarkers: []string{"///", "//"},
+		BlockStart:  []string{"(*"},
+		BlockEnd:    []string{"*)"},
+		BlockBare:   true,
+		BlockNested: true,
+		DocTags:     []string{"<"},
+		DocMarkers:  []string{"///"},
+	},
+	{
+		Name:        "gleam",
+		Extensions:  []string{".gleam"},
+		LineMarkers: []string{"////", "///", "//"},
+		Markdown:    []string{"////", "///"},
+	},
+	{
+		// Doc comments are Markdown, with callouts such as "- Parameter x:" as list items.
+		Name:        "swift",
+		Extensions:  []string{".swift"},
+		LineMarkers: []string{"///", "//"},
 		BlockStart:  []string{"/*"},
 		BlockEnd:    []string{"*/"},
+		BlockNested: true,
+		Strings:     []string{`"""`},
+		Anchored:    []string{"swiftlint:", "MARK:"},
+		Markdown:    []string{"///"},
+	},
+	{
+		// In the Lisp family, ";;;" starts a top-level or section comment, ";;" a comment on the
+		// code that follows and ";" one at the end of a line. The levels are never merged.
+		Name:        "lisp",
+		Extensions:  []string{".lisp", ".lsp", ".asd"},
+		LineMarkers: []string{";;;;", ";;;", ";;", ";"},
+		BlockStart:  []string{"#|"},
+		BlockEnd:    []string{"|#"},
+		BlockBare:   true,
+		BlockNested: true,
+	},
+	{
+		Name:        "elisp",
+		Extensions:  []string{".el"},
+		Filenames:   []string{".emacs"},
+		LineMarkers: []string{";;;;", ";;;", ";;", ";"},
+		Directives:  []string{"###autoload"},
+	},
+	{
+		Name:        "scheme",
+		Extensions:  []string{".scm", ".ss", ".sld"},
+		LineMarkers: []string{";;;;", ";;;", ";;", ";"},
+		BlockStart:  []string{"#|"},
+		BlockEnd:    []string{"|#"},
+		BlockBare:   true,
+		BlockNested: true,
+	},
+	{
+		Name:        "racket",
+		Extensions:  []string{".rkt"},
+		LineMarkers: []string{";;;;", ";;;", ";;", ";"},
+		BlockStart:  []string{"#|"},
+		BlockEnd:    []string{"|#"},
+		BlockBare:   true,
+		BlockNested: true,
+	},
+	{
+		Name:        "clojure",
+		Extensions:  []string{".clj", ".cljs", ".cljc", ".edn"},
+		LineMarkers: []string{";;;;", ";;;", ";;", ";"},
+	},
+	{
+		// The body of a @moduledoc, @doc or @typedoc heredoc is Markdown, as ExDoc renders it.
+		Name:        "elixir",
+		Extensions:  []string{".ex", ".exs"},
+		LineMarkers: []string{"#"},
+		Docstrings:  []string{`"""`, `'''`},
+		DocAttrs:    []string{"@moduledoc", "@doc", "@typedoc"},
+		Strings:     []string{`"""`, `'''`},
+		Markdown:    []string{`"""`, `'''`},
+		Anchored:    []string{"credo:"},
+		Column:      98, // mix format
 	},
 	{
 		Name:       "markdown",
 		Extensions: []string{".md", ".markdown"},
+		Column:     80,
+	},
+	{
+		Name:       "rst",
+		Extensions: []string{".rst", ".rest"},
+		Column:     80,
+	},
+	{
+		Name:       "asciidoc",
+		Extensions: []string{".adoc", ".asciidoc"},
+	},
+	{
+		Name:       "org",
+		Extensions: []string{".org"},
+	},
+	{
+		Name: "systemd",
+		Extensions: []string{
+			".service", ".socket", ".timer", ".mount", ".automount", ".swap", ".path", ".target",
+			".slice", ".scope", ".network", ".netdev", ".link",
+		},
+		LineMarkers: []string{"#", ";"},
+	},
+	{
+		Name:        "starlark",
+		Extensions:  []string{".bzl", ".star", ".bazel"},
+		Filenames:   []string{"BUILD", "WORKSPACE"},
+		ExactNames:  true,
+		LineMarkers: []string{"#"},
+		Docstrings:  []string{`"""`, `'''`},
+		Strings:     []string{`"""`, `'''`},
+	},
+	{
+		Name:        "nginx",
+		Extensions:  []string{".nginx"},
+		Filenames:   []string{"nginx.conf", "nginx/*.conf", "nginx/sites-available/*", "nginx/sites-enabled/*"},
+		LineMarkers: []string{"#"},
+	},
+	{
+		Name:       "apache",
+		Extensions: []string{".htaccess"},
+		Filenames: []string{
+			".htaccess", ".htpasswd", "httpd.conf", "apache2.conf", "httpd/*.conf",
+			"apache2/sites-available/*", "apache2/sites-enabled/*", "apache2/conf-available/*",
+		},
+		LineMarkers: []string{"#"},
+	},
+	{
+		Name:        "conf",
+		Extensions:  []string{".conf"},
+		LineMarkers: []string{"#", ";"},
+	},
+	{
+		Name:        "ini",
+		Extensions:  []string{".ini", ".cfg", ".gitconfig", ".editorconfig"},
+		Filenames:   []string{".gitconfig", ".gitmodules", ".editorconfig", ".git/config", "git/config"},
+		LineMarkers: []string{";", "#"},
+	},
+	{
+		// Generic "#" comments for config files with no code semantics worth modeling.
+		Name:       "hash",
+		Extensions: []string{".env"},
+		Filenames: []string{
+			"Caddyfile", "Procfile", ".env", ".env.*", ".gitignore", ".gitattributes", ".dockerignore",
+			"CODEOWNERS", "requirements*.txt",
+		},
+		LineMarkers: []string{"#"},
+	},
+	{
+		// Translator ("# ") and extracted ("#. ") comments are wrapped; references ("#:"), flags
+		// ("#,"), previous strings ("#|") and obsolete entries ("#~") are left alone.
+		Name:        "po",
+		Extensions:  []string{".po", ".pot"},
+		LineMarkers: []string{"#.", "#"},
+		Directives:  []string{":", ",", "|", "~"},
+	},
+	{
+		// Lines in block scalars and quoted scalars are never comments; see yamlMask.
+		Name:        "yaml",
+		Extensions:  []string{".yaml", ".yml"},
+		LineMarkers: []string{"#"},
+		Anchored:    []string{"yaml-language-server:", "yamllint ", "@schema"},
+	},
+	{
+		Name:        "toml",
+		Extensions:  []string{".toml"},
+		Filenames:   []string{"Pipfile"},
+		LineMarkers: []string{"#"},
+		Strings:     []string{`"""`},
+		RawStrings:  []string{"'''"}, // literal strings
+	},
+	{
+		// OpenAPI/Swagger specs have no extension of their own; see DetectLanguage.
+		Name:        "openapi",
+		LineMarkers: []string{"#"},
 	},
 }
 
-// extensionMap is built at init time for fast lookup.
-var extensionMap map[string]*Language
+var (
+	languagesMu  sync.RWMutex
+	extensionMap map[string]*Language // built at init time for fast lookup
+)
 
 func init() {
 	extensionMap = make(map[string]*Language)
-	for i := range languages {
-		for _, ext := range languages[i].Extensions {
-			extensionMap[ext] = &languages[i]
+	for _, lang := range languages {
+		for _, ext := range lang.Extensions {
+			extensionMap[ext] = lang
+		}
+	}
+}
+
+// RegisterLanguage adds a language, so that it is found by name, extension and file name like the
+// built-in ones. Built-in languages take precedence when a file name matches more than one
+// language's Filenames. It is safe to call at any time, including while other goroutines rewrap.
+//
+// The name and extensions must be lower case, and extensions must start with a dot. It returns an
+// error if the name, or one of the extensions or file names, is already used by another language.
+func RegisterLanguage(lang Language) error {
+	if err := checkLanguage(lang); err != nil {
+		return err
+	}
+
+	languagesMu.Lock()
+	defer languagesMu.Unlock()
+	for _, other := range languages {
+		if other.Name == lang.Name || slices.Contains(other.Extensions, "."+lang.Name) {
+			return fmt.Errorf("language %s is already registered", lang.Name)
+		}
+		for _, ext := range lang.Extensions {
+			if slices.Contains(other.Extensions, ext) {
+				return fmt.Errorf("language %s: extension %s is already used by %s", lang.Name, ext, other.Name)
+			}
+		}
+		for _, name := range lang.Filenames {
+			if slices.Contains(other.Filenames, name) {
+				return fmt.Errorf("language %s: file name %s is already used by %s", lang.Name, name, other.Name)
+			}
+		}
+	}
+	languages = append(languages, &lang)
+	for _, ext := range lang.Extensions {
+		extensionMap[ext] = &lang
+	}
+	return nil
+}
+
+// checkLanguage returns an error if lang cannot be registered, whatever the other languages.
+func checkLanguage(lang Language) error {
+	if lang.Name == "" {
+		return errors.New("language has no name")
+	}
+	if lang.Name != strings.ToLower(lang.Name) {
+		return fmt.Errorf("language name %q must be lower case", lang.Name)
+	}
+	if lang.Name == "text" {
+		return errors.New(`language name "text" is reserved for plain text`)
+	}
+	if len(lang.BlockStart) > 0 && len(lang.BlockEnd) != 1 && len(lang.BlockEnd) != len(lang.BlockStart) {
+		return fmt.Errorf("language %s: BlockEnd must have one marker or one for each BlockStart", lang.Name)
+	}
+	if len(lang.RawEnd) > 0 && len(lang.RawEnd) != len(lang.RawStrings) {
+		return fmt.Errorf("language %s: RawEnd must have one marker for each of RawStrings", lang.Name)
+	}
+	for _, ext := range lang.Extensions {
+		if !strings.HasPrefix(ext, ".") || ext != strings.ToLower(ext) {
+			return fmt.Errorf("language %s: extension %q must be lower case and start with a dot", lang.Name, ext)
 		}
 	}
+	return nil
 }
 
 // LanguageFromExtension returns the language for the given file extension (including the dot).
 // Returns nil if no language matches.
 func LanguageFromExtension(ext string) *Language {
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
 	return extensionMap[strings.ToLower(ext)]
 }
 
-// LanguageFromFilename returns the language for the given filename.
+// LanguageFromFilename returns the language for the given filename. Well-known file names (see
+// Language.Filenames) take precedence over the extension.
 func LanguageFromFilename(filename string) *Language {
+	if lang := languageFromFilenamePattern(filename); lang != nil {
+		return lang
+	}
 	return LanguageFromExtension(filepath.Ext(filename))
 }
 
+// languageFromFilenamePattern returns the first language with a Filenames entry matching filename.
+// Patterns are matched against the base name, or against the trailing path elements if the pattern
+// contains a slash (e.g., "nginx/sites-available/*"). Matching ignores case, so "NGINX.CONF" is an
+// nginx config, unless the language sets ExactNames.
+func languageFromFilenamePattern(filename string) *Language {
+	elems := strings.Split(filepath.ToSlash(filename), "/")
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+	for _, lang := range languages {
+		if lang.matchesFilename(elems) {
+			return lang
+		}
+	}
+	return nil
+}
+
+// matchesFilename reports whether one of the language's Filenames patterns matches the path
+// elements elems; see languageFromFilenamePattern.
+func (l *Language) matchesFilename(elems []string) bool {
+	for _, pattern := range l.Filenames {
+		n := strings.Count(pattern, "/") + 1
+		if n > len(elems) {
+			continue
+		}
+		tail := strings.Join(elems[len(elems)-n:], "/")
+		if !l.ExactNames {
+			pattern, tail = strings.ToLower(pattern), strings.ToLower(tail)
+		}
+		if ok, _ := path.Match(pattern, tail); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesName reports whether the lower-case name is the language's name or one of its extensions,
+// with or without the dot.
+func (l *Language) matchesName(lower string) bool {
+	return l.Name == lower || slices.ContainsFunc(l.Extensions, func(ext string) bool {
+		return "."+lower == ext || lower == ext
+	})
+}
+
+// openAPIPattern matches the top-level version key of an OpenAPI or Swagger document, in YAML or
+// JSON form.
+var openAPIPattern = regexp.MustCompile(`(?m)^(?:openapi|swagger)\s*:|"(?:openapi|swagger)"\s*:`)
+
+// DetectLanguage returns the language for a file, using its contents when the filename alone is not
+// enough. YAML and JSON files that declare an "openapi" or "swagger" version are detected as
+// OpenAPI specs. Returns nil if no language matches.
+func DetectLanguage(filename string, src []byte) *Language {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml", ".json":
+		if openAPIPattern.Match(src) {
+			return LanguageFromName("openapi")
+		}
+	}
+	return LanguageFromFilename(filename)
+}
+
 // LanguageFromName returns the language by its name or extension alias (case-insensitive). For
 // example, both "markdown" and "md" match the Markdown language.
 func LanguageFromName(name string) *Language {
 	lower := strings.ToLower(name)
-	for i := range languages {
-		if languages[i].Name == lower {
-			return &languages[i]
-		}
-		for _, ext := range languages[i].Extensions {
-			if "."+lower == ext || lower == ext {
-				return &languages[i]
-			}
+	languagesMu.RLock()
+	defer languagesMu.RUnlock()
+	for _, lang := range languages {
+		if lang.matchesName(lower) {
+			return lang
 		}
 	}
 	return nil
 }
+
+// docTags returns the DocTags that apply in a comment with the marker, such as "/// " or "/**":
+// all of them if DocMarkers is empty, and none if the marker is not one of DocMarkers.
+func (l *Language) docTags(marker string) []string {
+	if len(l.DocMarkers) > 0 && !slices.Contains(l.DocMarkers, strings.TrimSpace(marker)) {
+		return nil
+	}
+	return l.DocTags
+}
+
+// column returns the language's Column, or 0 for plain text (a nil language).
+func (l *Language) column() int {
+	if l == nil {
+		return 0
+	}
+	return l.Column
+}
+
+// prose reports whether l is plain text (nil) or a markup language such as Markdown, which are
+// rewrapped as a whole rather than as comments in code.
+func (l *Language) prose() bool {
+	return l == nil || l.Name == "markdown" || l.Name == "rst" || l.Name == "asciidoc" || l.Name == "org"
+}
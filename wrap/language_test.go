@@ -0,0 +1,144 @@
+package wrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		filename string
+		src      string
+		want     string // language name, or "" for nil
+	}{
+		{"main.go", "package main", "go"},
+		{"api.yaml", "openapi: 3.0.0\ninfo:\n", "openapi"},
+		{"api.yml", "swagger: '2.0'\n", "openapi"},
+		{"api.json", `{"openapi": "3.1.0"}`, "openapi"},
+		{"config.yaml", "name: test\n", "yaml"},
+		{"config.json", `{"name": "test"}`, ""},
+		{"notes.txt", "openapi: 3.0.0\n", ""},
+		{"fetch.R", "f <- function() {}\n", "r"},
+		{"fetch.r", "f <- function() {}\n", "r"},
+		{"index.rst", "Title\n=====\n", "rst"},
+		{"index.adoc", "= Title\n", "asciidoc"},
+		{"notes.org", "* Title\n", "org"},
+	}
+	for _, tt := range tests {
+		lang := DetectLanguage(tt.filename, []byte(tt.src))
+		if tt.want == "" {
+			assert.Nil(t, lang, "DetectLanguage(%q)", tt.filename)
+			continue
+		}
+		if assert.NotNil(t, lang, "DetectLanguage(%q)", tt.filename) {
+			assert.Equal(t, tt.want, lang.Name, "DetectLanguage(%q)", tt.filename)
+		}
+	}
+}
+
+func TestLanguageFromFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string // language name, or "" for nil
+	}{
+		{"main.go", "go"},
+		{"/etc/nginx/nginx.conf", "nginx"},
+		{"/etc/nginx/NGINX.CONF", "nginx"},
+		{"/etc/nginx/sites-available/default", "nginx"},
+		{"/etc/nginx/sites-enabled/example.com", "nginx"},
+		{"/etc/nginx/conf.d/gzip.conf", "conf"},
+		{"site/.htaccess", "apache"},
+		{"site/.HTACCESS", "apache"},
+		{"/etc/apache2/apache2.conf", "apache"},
+		{"/etc/apache2/sites-available/000-default.conf", "apache"},
+		{"/etc/httpd/conf/httpd.conf", "apache"},
+		{"/etc/ssh/sshd_config", ""},
+		{"pkg/BUILD", "starlark"},
+		{"pkg/BUILD.bazel", "starlark"},
+		{"WORKSPACE", "starlark"},
+		{"defs.bzl", "starlark"},
+		{"scripts/build", ""},
+		{"Workspace", ""},
+		{"Caddyfile", "hash"},
+		{"app/Procfile", "hash"},
+		{".env", "hash"},
+		{".env.production", "hash"},
+		{"prod.env", "hash"},
+		{".github/CODEOWNERS", "hash"},
+		{"requirements-dev.txt", "hash"},
+		{"notes.txt", ""},
+		{"php.ini", "ini"},
+		{"setup.cfg", "ini"},
+		{"/home/me/.gitconfig", "ini"},
+		{".editorconfig", "ini"},
+		{".gitmodules", "ini"},
+		{"repo/.git/config", "ini"},
+		{"/home/me/.config/git/config", "ini"},
+	}
+	for _, tt := range tests {
+		lang := LanguageFromFilename(tt.filename)
+		if tt.want == "" {
+			assert.Nil(t, lang, "LanguageFromFilename(%q)", tt.filename)
+			continue
+		}
+		if assert.NotNil(t, lang, "LanguageFromFilename(%q)", tt.filename) {
+			assert.Equal(t, tt.want, lang.Name, "LanguageFromFilename(%q)", tt.filename)
+		}
+	}
+}
+
+func TestRegisterLanguage(t *testing.T) {
+	lang := Language{
+		Name:        "zig-test",
+		Extensions:  []string{".zigtest"},
+		Filenames:   []string{"build.zigtest.zon"},
+		LineMarkers: []string{"//"},
+	}
+	require.NoError(t, RegisterLanguage(lang))
+	assert.Equal(t, "zig-test", LanguageFromName("zig-test").Name)
+	assert.Equal(t, "zig-test", LanguageFromName("ZIGTEST").Name)
+	assert.Equal(t, "zig-test", LanguageFromFilename("dir/a.zigtest").Name)
+	assert.Equal(t, "zig-test", LanguageFromFilename("build.zigtest.zon").Name)
+	got := Source([]byte("// one two three four\nconst a = 1;\n"), LanguageFromName("zig-test"), 12, 0)
+	assert.Equal(t, "// one two\n// three\n// four\nconst a = 1;\n", string(got))
+
+	tests := []struct {
+		lang Language
+		want string
+	}{
+		{Language{}, "language has no name"},
+		{Language{Name: "Zig"}, `language name "Zig" must be lower case`},
+		{Language{Name: "text"}, `language name "text" is reserved for plain text`},
+		{Language{Name: "go"}, "language go is already registered"},
+		{Language{Name: "py"}, "language py is already registered"},
+		{Language{Name: "zig-test"}, "language zig-test is already registered"},
+		{Language{Name: "x", Extensions: []string{".go"}}, "language x: extension .go is already used by go"},
+		{Language{Name: "x", Extensions: []string{"x"}}, `language x: extension "x" must be lower case and start with a dot`},
+		{Language{Name: "x", Filenames: []string{".htaccess"}}, "language x: file name .htaccess is already used by apache"},
+		{Language{Name: "x", BlockStart: []string{"/*"}}, "language x: BlockEnd must have one marker or one for each BlockStart"},
+	}
+	for _, tt := range tests {
+		assert.EqualError(t, RegisterLanguage(tt.lang), tt.want, tt.lang.Name)
+	}
+	assert.Nil(t, LanguageFromName("x"))
+}
+
+func TestDefaultProfile(t *testing.T) {
+	p := DefaultProfile(LanguageFromName("python"))
+	assert.Equal(t, 79, p.Column)
+	assert.Equal(t, DefaultTabWidth, p.TabWidth)
+	assert.Contains(t, p.Anchored, "noqa")
+	assert.Equal(t, Options{Column: 79, TabWidth: DefaultTabWidth}, p.Options())
+
+	// The profile is a copy.
+	p.Anchored[0] = "changed"
+	assert.NotContains(t, LanguageFromName("python").Anchored, "changed")
+
+	p = DefaultProfile(LanguageFromName("go"))
+	assert.Equal(t, DefaultColumn, p.Column)
+	assert.Equal(t, []string{"go:generate"}, p.Continued)
+
+	assert.Equal(t, Profile{Column: DefaultColumn, TabWidth: DefaultTabWidth}, DefaultProfile(nil))
+}
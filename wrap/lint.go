@@ -0,0 +1,54 @@
+package wrap
+
+import (
+	"cmp"
+	"strings"
+)
+
+// LongLine is a line of source wider than the column.
+type LongLine struct {
+	Line   int  // 1-based line number
+	Width  int  // display width, with tabs expanded
+	Column int  // the column the line is wider than
+	Text   bool // the line is comment or prose text that rewrap wraps, not code
+}
+
+// LongLines returns the lines of src that are wider than opts.Column, in order. Long text lines
+// can usually be fixed by rewrapping; long code lines need to be fixed by hand. If lang is nil or
+// a markup language such as Markdown, every line is text.
+func LongLines(src []byte, lang *Language, opts Options) []LongLine {
+	opts.Column = cmp.Or(opts.Column, lang.column(), DefaultColumn)
+	opts.TabWidth = cmp.Or(opts.TabWidth, DefaultTabWidth)
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	isText := make([]bool, len(lines))
+	if lang.prose() {
+		for i := range isText {
+			isText[i] = true
+		}
+	} else {
+		n := 0
+		for _, seg := range parseSegments(lines, lang) {
+			for _, line := range seg.lines {
+				isText[n] = seg.typ != segmentCode
+				if seg.typ == segmentComment {
+					// Lines kept by opts.Rules, such as anchored lines, are left as they are, so
+					// they are like code.
+					content := strings.TrimPrefix(strings.TrimLeft(line, " \t"), strings.TrimRight(seg.marker, " "))
+					isText[n] = protectedLine(content, lang, opts) == ""
+				}
+				n++
+			}
+		}
+	}
+
+	var long []LongLine
+	for i, line := range lines {
+		if w := displayWidth(line, opts.TabWidth); w > opts.Column {
+			long = append(long, LongLine{Line: i + 1, Width: w, Column: opts.Column, Text: isText[i]})
+		}
+	}
+	return long
+}
@@ -0,0 +1,23 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongLines(t *testing.T) {
+	goLang := LanguageFromName("go")
+	src := "// " + strings.Repeat("x", 30) + "\nfunc f() { return " + strings.Repeat("y", 30) + " }\n\nvar short = 1\n"
+	assert.Equal(t, []LongLine{
+		{Line: 1, Width: 33, Column: 30, Text: true},
+		{Line: 2, Width: 50, Column: 30, Text: false},
+	}, LongLines([]byte(src), goLang, Options{Column: 30}))
+	assert.Empty(t, LongLines([]byte(src), goLang, Options{}))
+	assert.Equal(t, []LongLine{{Line: 2, Width: 50, Column: 40, Text: true}}, LongLines([]byte(src), nil, Options{Column: 40}))
+
+	// Anchored lines are not rewrapped, so they are reported as code.
+	src = "// text\n// +kubebuilder:" + strings.Repeat("z", 30) + "\n"
+	assert.Equal(t, []LongLine{{Line: 2, Width: 46, Column: 30, Text: false}}, LongLines([]byte(src), goLang, Options{Column: 30}))
+}
@@ -0,0 +1,328 @@
+package wrap
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// item is a run of text that wraps as a unit: a prose paragraph, a single list item, or a doc tag
+// paragraph such as "@param name description".
+type item struct {
+	indent string // leading whitespace before the list marker or tag
+	marker string // list marker including trailing space, e.g., "- " or "1. ", or an option term and the gap after it; empty for prose
+	tag    bool   // item starts with a doc tag
+	hang   string // continuation indent of a tag item, relative to indent
+	text   string
+	raw    []string // original lines of a verbatim item, which is emitted unchanged
+	tight  bool     // true if no blank line separated this item from the previous one
+}
+
+// defaultTagHang is the continuation indent of a doc tag paragraph that has no continuation lines
+// to take it from.
+const defaultTagHang = "    "
+
+// verbatimTags are doc tags whose body is code, such as "@example", and is never rewrapped.
+var verbatimTags = []string{"example"}
+
+// verbatimBlocks maps doc tags that start a block of code or other verbatim text, such as Doxygen's
+// "\code", to the tags that end them. The block is kept as is.
+var verbatimBlocks = map[string]string{
+	"code":      "endcode",
+	"verbatim":  "endverbatim",
+	"dot":       "enddot",
+	"msc":       "endmsc",
+	"startuml":  "enduml",
+	"f[":        "f]",
+	"latexonly": "endlatexonly",
+	"htmlonly":  "endhtmlonly",
+	"xmlonly":   "endxmlonly",
+}
+
+// xmlTagLinePattern matches a line of XML doc comment that consists only of tags, such as
+// "<summary>" or "</para>".
+var xmlTagLinePattern = regexp.MustCompile(`^(</?[A-Za-z][^<>]*>\s*)+$`)
+
+// optionPattern matches a line of command-line help, such as "-v, --verbose   print more": one or
+// more options, an optional argument name, and a gap of at least two spaces or a tab before the
+// description.
+var optionPattern = regexp.MustCompile(`^-{1,2}[A-Za-z0-9?][^\s,]*(?:, ?-{1,2}[A-Za-z0-9][^\s,]*)*(?:[ =]<?[A-Za-z][^\s]*)?(?: {2,}|\t+)\S`)
+
+// splitItems splits text into prose paragraphs, list items and doc tag paragraphs. A line starting
+// with a list marker or one of the tag prefixes (e.g., "@" for "@param") begins a new item; the
+// lines following it (up to the next marker, tag or blank line) are continuation text of that item.
+// The body of a verbatim tag (see verbatimTags) runs until the next tag or the next blank line that
+// is not followed by more indented lines, and is kept as is, as is a block from a tag such as
+// "\code" to its end tag (see verbatimBlocks). With the "<" tag prefix (XML doc
+// comments), lines holding only tags are kept on their own line and <code> elements are kept as is.
+// With the "@" tag prefix (Javadoc and the like), lines holding only HTML tags, such as "<p>", are
+// kept on their own line, and <pre> elements and {@code ...} tags that start a line and span
+// several lines are kept as is.
+func splitItems(text string, tags []string) []item {
+	var items []item
+	var current *item
+	var words []string
+	blank := false
+	flush := func() {
+		if current != nil {
+			current.text = strings.Join(words, "\n")
+			items = append(items, *current)
+			current = nil
+			words = nil
+		}
+	}
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		indent := line[:leadingWidth(line)]
+		if end := verbatimBlockEnd(lines[i:], tags); end > 0 {
+			flush()
+			raw := make([]string, end)
+			for j, line := range lines[i : i+end] {
+				raw[j] = strings.TrimRight(line, " \t")
+			}
+			items = append(items, item{indent: indent, tag: true, raw: raw, tight: !blank})
+			i += end - 1
+			blank = false
+			continue
+		}
+		if isVerbatimTag(trimmed, tags) {
+			flush()
+			raw := []string{line}
+			for i+1 < len(lines) && !isDocTag(strings.TrimSpace(lines[i+1]), tags) {
+				next := lines[i+1]
+				if strings.TrimSpace(next) == "" && !continuesDeeper(lines[i+1:], len(indent)) {
+					break
+				}
+				raw = append(raw, strings.TrimRight(next, " \t"))
+				i++
+			}
+			items = append(items, item{indent: indent, tag: true, raw: raw, tight: !blank})
+			blank = false
+			continue
+		}
+		if slices.Contains(tags, "<") && (xmlTagLinePattern.MatchString(trimmed) || strings.HasPrefix(trimmed, "<code>")) {
+			flush()
+			raw := []string{strings.TrimRight(line, " \t")}
+			if strings.HasPrefix(trimmed, "<code") {
+				for i+1 < len(lines) && !strings.Contains(lines[i], "</code>") {
+					raw = append(raw, strings.TrimRight(lines[i+1], " \t"))
+					i++
+				}
+			}
+			items = append(items, item{raw: raw, tight: !blank})
+			blank = false
+			continue
+		}
+		if slices.Contains(tags, "@") && (strings.HasPrefix(trimmed, "<pre>") || xmlTagLinePattern.MatchString(trimmed) || strings.HasPrefix(trimmed, "{@code") && braceDepth(trimmed) > 0) {
+			flush()
+			raw := []string{strings.TrimRight(line, " \t")}
+			pre, depth := strings.HasPrefix(trimmed, "<pre>"), braceDepth(trimmed)
+			for i+1 < len(lines) && (pre && !strings.Contains(lines[i], "</pre>") || !pre && depth > 0) {
+				i++
+				depth += braceDepth(lines[i])
+				raw = append(raw, strings.TrimRight(lines[i], " \t"))
+			}
+			items = append(items, item{raw: raw, tight: !blank})
+			blank = false
+			continue
+		}
+		if trimmed == "" {
+			flush()
+			blank = true
+			continue
+		}
+		if marker := cmp.Or(listMarker(trimmed), optionTerm(trimmed)); marker != "" {
+			flush()
+			current = &item{indent: indent, marker: marker, tight: !blank}
+			words = append(words, strings.TrimSpace(trimmed[len(marker):]))
+		} else if isDocTag(trimmed, tags) {
+			flush()
+			current = &item{indent: indent, tag: true, tight: !blank}
+			words = append(words, trimmed)
+		} else {
+			if current == nil {
+				current = &item{tight: !blank}
+			} else if current.tag && current.hang == "" && len(words) == 1 && strings.HasPrefix(indent, current.indent) {
+				current.hang = indent[len(current.indent):]
+			}
+			words = append(words, trimmed)
+		}
+		blank = false
+	}
+	flush()
+	return items
+}
+
+// braceDepth returns the number of braces that s opens and leaves open, or closes if negative.
+func braceDepth(s string) int {
+	return strings.Count(s, "{") - strings.Count(s, "}")
+}
+
+// listMarker returns the list marker (including one trailing space) at the start of s, or "" if s
+// does not start with a bullet ("-", "*", "+", "•") or ordered ("1.", "1)") marker.
+func listMarker(s string) string {
+	for _, b := range []string{"-", "*", "+", "•"} {
+		if strings.HasPrefix(s, b+" ") {
+			return b + " "
+		}
+	}
+	n := 0
+	for n < len(s) && n < 3 && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n > 0 && n+1 < len(s) && (s[n] == '.' || s[n] == ')') && s[n+1] == ' ' {
+		return s[:n+2]
+	}
+	return ""
+}
+
+// optionTerm returns the options and argument name at the start of a line of command-line help,
+// with the gap after them, so that the description is wrapped with its continuation lines aligned
+// under it. It returns "" if s is not such a line; see optionPattern.
+func optionTerm(s string) string {
+	m := optionPattern.FindStringIndex(s)
+	if m == nil {
+		return ""
+	}
+	return s[:m[1]-1]
+}
+
+// isDocTag reports whether s starts with one of the tag prefixes followed by a letter, as in
+// "@param" or "\brief".
+func isDocTag(s string, tags []string) bool {
+	for _, t := range tags {
+		if len(s) > len(t) && strings.HasPrefix(s, t) && unicode.IsLetter(rune(s[len(t)])) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVerbatimTag reports whether s starts with a doc tag listed in verbatimTags.
+func isVerbatimTag(s string, tags []string) bool {
+	if !isDocTag(s, tags) {
+		return false
+	}
+	for _, t := range tags {
+		if name, ok := strings.CutPrefix(s, t); ok {
+			name, _, _ = strings.Cut(name, " ")
+			return slices.Contains(verbatimTags, name)
+		}
+	}
+	return false
+}
+
+// tagName returns the name of the doc tag that s starts with, such as "param" for "@param x" or
+// "code" for "\code{.py}", or "" if s does not start with a doc tag.
+func tagName(s string, tags []string) string {
+	if !isDocTag(s, tags) {
+		return ""
+	}
+	for _, t := range tags {
+		if name, ok := strings.CutPrefix(s, t); ok {
+			end := strings.IndexAny(name, " \t{")
+			if end < 0 {
+				end = len(name)
+			}
+			return name[:end]
+		}
+	}
+	return ""
+}
+
+// verbatimBlockEnd returns the number of lines in the verbatim block (see verbatimBlocks) that
+// starts lines, up to and including its end tag, or 0 if lines starts with no such block or the
+// block does not end.
+func verbatimBlockEnd(lines []string, tags []string) int {
+	end, ok := verbatimBlocks[tagName(strings.TrimSpace(lines[0]), tags)]
+	if !ok {
+		return 0
+	}
+	for j := 1; j < len(lines); j++ {
+		if tagName(strings.TrimSpace(lines[j]), tags) == end {
+			return j + 1
+		}
+	}
+	return 0
+}
+
+// continuesDeeper reports whether the first non-blank line in lines is indented deeper than width.
+func continuesDeeper(lines []string, width int) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return leadingWidth(line) > width
+		}
+	}
+	return false
+}
+
+// isBullet reports whether marker is an unordered list marker.
+func isBullet(marker string) bool {
+	marker = strings.TrimSpace(marker)
+	return marker == "-" || marker == "*" || marker == "+" || marker == "•"
+}
+
+// normalizeBullet replaces the bullet of an unordered list marker with bullet. Ordered markers are
+// returned unchanged, as is every marker when bullet is empty.
+func normalizeBullet(marker, bullet string) string {
+	if bullet == "" || !isBullet(marker) {
+		return marker
+	}
+	return bullet + " "
+}
+
+// wrapItems wraps text like wrapText, but recognizes list items so that each item keeps its own
+// line, marker and hanging indent instead of being merged into the surrounding paragraph. Doc tag
+// paragraphs (see splitItems) likewise start on their own line, with continuation lines indented by
+// the original continuation indent or defaultTagHang. If opts.Bullet is non-empty, unordered list
+// markers are rewritten to use it.
+func wrapItems(text, prefix, subsequentPrefix string, tags []string, opts Options) []string {
+	// A word that looks like a list marker, such as "-" or "12.", or like a doc tag, such as
+	// "@alice", is never wrapped to the start of a line, where the next pass would take it for the
+	// start of an item.
+	canStartLine := opts.canStartLine
+	opts.canStartLine = func(prefix, word string) bool {
+		return listMarker(word+" ") == "" && !isDocTag(word, tags) && (canStartLine == nil || canStartLine(prefix, word))
+	}
+	items := splitItems(text, tags)
+	if len(items) == 0 {
+		return []string{prefix}
+	}
+	var result []string
+	for i, it := range items {
+		first := prefix
+		if i > 0 {
+			first = subsequentPrefix
+			if !it.tight {
+				result = append(result, strings.TrimRight(subsequentPrefix, " "))
+			}
+		}
+		if it.raw != nil {
+			result = append(result, first+it.raw[0])
+			for _, line := range it.raw[1:] {
+				result = append(result, strings.TrimRight(subsequentPrefix+line, " "))
+			}
+			continue
+		}
+		if it.tag {
+			hang := it.hang
+			if hang == "" && !strings.HasPrefix(it.text, "<") {
+				hang = defaultTagHang
+			}
+			result = append(result, wrapParagraph(it.text, first+it.indent, subsequentPrefix+it.indent+hang, opts, true)...)
+			continue
+		}
+		if it.marker == "" {
+			result = append(result, wrapParagraph(it.text, first, subsequentPrefix, opts, true)...)
+			continue
+		}
+		marker := normalizeBullet(it.marker, opts.Bullet)
+		hang := subsequentPrefix + it.indent + strings.Repeat(" ", displayWidth(marker, opts.TabWidth))
+		result = append(result, wrapParagraph(it.text, first+it.indent+marker, hang, opts, true)...)
+	}
+	return result
+}
@@ -0,0 +1,168 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListMarker(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"- item", "- "},
+		{"* item", "* "},
+		{"+ item", "+ "},
+		{"• item", "• "},
+		{"1. item", "1. "},
+		{"12) item", "12) "},
+		{"-flag", ""},
+		{"--flag value", ""},
+		{"1.5 seconds", ""},
+		{"1999. The year", ""},
+		{"plain text", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, listMarker(tt.input), "listMarker(%q)", tt.input)
+	}
+}
+
+func TestOptionTerm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"-v, --verbose   print more", "-v, --verbose   "},
+		{"--column int  column width", "--column int  "},
+		{"--column=N\tcolumn width", "--column=N\t"},
+		{"-t <tag>  image tag", "-t <tag>  "},
+		{"-h  help", "-h  "},
+		{"--verbose print more", ""},
+		{"-1  one entry per line", "-1  "},
+		{"- item", ""},
+		{"--  end of flags", ""},
+		{"plain  text", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, optionTerm(tt.input), "optionTerm(%q)", tt.input)
+	}
+}
+
+func TestWrapItems(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		bullet string
+		want   []string
+	}{
+		{
+			name: "items keep their own lines",
+			text: "intro text\n- one\n- two",
+			want: []string{
+				"# intro text",
+				"# - one",
+				"# - two",
+			},
+		},
+		{
+			name: "hanging indent",
+			text: "* a long list item that needs to wrap",
+			want: []string{
+				"# * a long list item that",
+				"#   needs to wrap",
+			},
+		},
+		{
+			name: "continuation lines join the item",
+			text: "1. first\n   continued\n2. second",
+			want: []string{
+				"# 1. first continued",
+				"# 2. second",
+			},
+		},
+		{
+			name: "blank lines preserved",
+			text: "- one\n\n- two",
+			want: []string{
+				"# - one",
+				"#",
+				"# - two",
+			},
+		},
+		{
+			name:   "normalize bullets",
+			text:   "* one\n• two\n+ three\n- four\n1. five",
+			bullet: "-",
+			want: []string{
+				"# - one",
+				"# - two",
+				"# - three",
+				"# - four",
+				"# 1. five",
+			},
+		},
+		{
+			name:   "nested items keep indent",
+			text:   "* one\n  * nested item that is long enough to wrap",
+			bullet: "-",
+			want: []string{
+				"# - one",
+				"#   - nested item that is",
+				"#     long enough to wrap",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrapItems(tt.text, "# ", "# ", nil, Options{Column: 28, TabWidth: 4, Bullet: tt.bullet})
+			require.Equal(t, tt.want, got, "got:\n%s", strings.Join(got, "\n"))
+		})
+	}
+}
+
+func TestWrapItems_DocTags(t *testing.T) {
+	text := "Summary line.\n@param name the name of the thing to create\n@param size\n  the size in bytes of the new thing\n@return nothing"
+	got := wrapItems(text, " * ", " * ", []string{"@"}, Options{Column: 32, TabWidth: 4})
+	want := []string{
+		" * Summary line.",
+		" * @param name the name of the",
+		" *     thing to create",
+		" * @param size the size in bytes",
+		" *   of the new thing",
+		" * @return nothing",
+	}
+	require.Equal(t, want, got, "got:\n%s", strings.Join(got, "\n"))
+}
+
+func TestWrapItems_DocTagInProse(t *testing.T) {
+	// A tag-like word in prose stays on the line before, where the next pass keeps it in the
+	// paragraph.
+	text := "Ask the owners, ping @alice or @bob before merging"
+	got := wrapItems(text, " * ", " * ", []string{"@"}, Options{Column: 27, TabWidth: 4})
+	want := []string{
+		" * Ask the owners, ping @alice",
+		" * or @bob before merging",
+	}
+	require.Equal(t, want, got, "got:\n%s", strings.Join(got, "\n"))
+	require.Equal(t, want, wrapItems(strings.Join([]string{"Ask the owners, ping @alice", "or @bob before merging"}, "\n"), " * ", " * ", []string{"@"}, Options{Column: 27, TabWidth: 4}))
+}
+
+func TestWrapItems_XMLDoc(t *testing.T) {
+	text := "<summary>\nAdds two numbers together and returns the sum.\n</summary>\n<param name=\"a\">The first number.</param>\n<code>\nlet  x = add 1 2\n</code>"
+	got := wrapItems(text, "/// ", "/// ", []string{"<"}, Options{Column: 32, TabWidth: 4})
+	want := []string{
+		"/// <summary>",
+		"/// Adds two numbers together",
+		"/// and returns the sum.",
+		"/// </summary>",
+		"/// <param name=\"a\">The first",
+		"/// number.</param>",
+		"/// <code>",
+		"/// let  x = add 1 2",
+		"/// </code>",
+	}
+	require.Equal(t, want, got, "got:\n%s", strings.Join(got, "\n"))
+}
@@ -2,39 +2,67 @@ package wrap
 
 import (
 	"bytes"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/text"
 )
 
 // processMarkdown rewraps paragraph text in Markdown source while preserving all structural
 // elements (headings, code blocks, blockquotes, tables, thematic breaks, HTML) verbatim.
 // Paragraphs inside list items are rewrapped with their marker/indentation preserved.
-func processMarkdown(src []byte, column, tabWidth int) []byte {
+func processMarkdown(src []byte, opts Options) []byte {
+	opts.markdown = true
+	tabWidth := opts.TabWidth
 	// Normalize line endings.
 	normalized := bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
 	normalized = bytes.ReplaceAll(normalized, []byte("\r"), []byte("\n"))
+	if !opts.markdownWithinLimits(normalized) {
+		return normalized
+	}
 
 	reader := text.NewReader(normalized)
-	md := goldmark.New(goldmark.WithExtensions(extension.Table))
+	md := goldmark.New(goldmark.WithExtensions(extension.Table, extension.Footnote))
 	doc := md.Parser().Parse(reader)
 
 	lines := strings.Split(string(normalized), "\n")
+	newlines := newlineOffsets(normalized)
 
 	type paragraphInfo struct {
-		start       int    // inclusive line number (0-indexed)
-		end         int    // exclusive line number
-		firstPrefix string // prefix for first wrapped line
-		contPrefix  string // prefix for continuation wrapped lines
-		text        string // text content from segments (markers stripped)
+		start       int      // inclusive line number (0-indexed)
+		end         int      // exclusive line number
+		firstPrefix string   // prefix for first wrapped line
+		contPrefix  string   // prefix for continuation wrapped lines
+		text        string   // text content from segments (markers stripped)
+		raw         []string // lines to output instead of wrapped text, such as the rows of a table
 	}
 	var paragraphs []paragraphInfo
 
 	// Walk the full AST to find paragraphs at any nesting depth.
 	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if table, ok := node.(*extast.Table); ok && entering && opts.FormatTables {
+			// Only top-level tables, whose lines have no prefix.
+			if start := tableStart(table, newlines); start >= 0 && table.Parent().Kind() == ast.KindDocument {
+				end := start + 1 + table.ChildCount()
+				paragraphs = append(paragraphs, paragraphInfo{start: start, end: end, raw: formatTable(lines[start:end], table.Alignments, tabWidth)})
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		if heading, ok := node.(*ast.Heading); ok && entering && opts.ExtendUnderlines && heading.Parent().Kind() == ast.KindDocument {
+			if segs := heading.Lines(); segs.Len() > 0 {
+				start := byteOffsetToLine(newlines, segs.At(0).Start)
+				end := byteOffsetToLine(newlines, segs.At(segs.Len()-1).Stop-1) + 2
+				if raw := extendUnderline(lines[start:min(end, len(lines))], tabWidth); raw != nil {
+					paragraphs = append(paragraphs, paragraphInfo{start: start, end: end, raw: raw})
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
 		if !entering || (node.Kind() != ast.KindParagraph && node.Kind() != ast.KindTextBlock) {
 			return ast.WalkContinue, nil
 		}
@@ -56,25 +84,25 @@ func processMarkdown(src []byte, column, tabWidth int) []byte {
 		lineStart := lineStartOffset(normalized, firstSeg.Start)
 		srcPrefix := string(normalized[lineStart:firstSeg.Start])
 
+		// The prefix holds the markers of every container the paragraph is nested in, such as
+		// "> - " for an item in a blockquote, or "- > - " for an item in a blockquote in an item.
 		var firstPrefix, contPrefix string
 		switch parent.Kind() {
 		case ast.KindDocument:
 			// Top-level paragraph, no prefix.
-		case ast.KindBlockquote:
+		case ast.KindBlockquote, ast.KindListItem:
+			firstPrefix = replaceListBullets(srcPrefix, opts.Bullet)
+			contPrefix = continuationPrefix(srcPrefix, tabWidth)
+		case extast.KindFootnote:
+			// Later paragraphs of a footnote are indented by four spaces, like continuation lines.
 			firstPrefix = srcPrefix
-			contPrefix = srcPrefix
-		case ast.KindListItem:
-			firstPrefix = srcPrefix
-			// Preserve blockquote markers ("> ") in continuation prefix, replacing
-			// only the list-marker portion with spaces.
-			bqPrefix := blockquotePrefix(srcPrefix)
-			contPrefix = bqPrefix + strings.Repeat(" ", displayWidth(srcPrefix, tabWidth)-displayWidth(bqPrefix, tabWidth))
+			contPrefix = "    "
 		default:
 			// Inside other structure - skip.
 			return ast.WalkContinue, nil
 		}
-		startLine := byteOffsetToLine(normalized, firstSeg.Start)
-		endLine := byteOffsetToLine(normalized, lastSeg.Stop-1) + 1
+		startLine := byteOffsetToLine(newlines, firstSeg.Start)
+		endLine := byteOffsetToLine(newlines, lastSeg.Stop-1) + 1
 
 		// Extract text content from segments (markers already stripped by parser).
 		var segTexts []string
@@ -93,7 +121,8 @@ func processMarkdown(src []byte, column, tabWidth int) []byte {
 		return ast.WalkContinue, nil
 	})
 
-	// Build output by processing line ranges.
+	// Build output by processing line ranges. Footnotes are walked last, wherever they are.
+	slices.SortFunc(paragraphs, func(a, b paragraphInfo) int { return a.start - b.start })
 	var out []string
 	i := 0
 	for _, p := range paragraphs {
@@ -102,8 +131,14 @@ func processMarkdown(src []byte, column, tabWidth int) []byte {
 			out = append(out, lines[i])
 			i++
 		}
-		wrapped := wrapText(p.text, p.firstPrefix, p.contPrefix, column, tabWidth)
-		out = append(out, wrapped...)
+		if !opts.selected(p.start, p.end) || !opts.withinLimits(lines[p.start:p.end]) {
+			continue // passed through with the lines after it
+		}
+		if p.raw != nil {
+			out = append(out, p.raw...)
+		} else {
+			out = append(out, wrapHardBreaks(p.text, p.firstPrefix, p.contPrefix, opts)...)
+		}
 		i = p.end
 	}
 	// Pass through remaining lines.
@@ -120,20 +155,114 @@ func processMarkdown(src []byte, column, tabWidth int) []byte {
 	return []byte(result)
 }
 
-// blockquotePrefix returns the blockquote marker portion of a line prefix.
-// For "> - " it returns "> ", for "> > - " it returns "> > ", and for "- " it returns "".
-func blockquotePrefix(prefix string) string {
-	end := 0
-	for i := 0; i < len(prefix); i++ {
-		if prefix[i] == '>' {
-			if i+1 < len(prefix) && prefix[i+1] == ' ' {
-				end = i + 2
-			} else {
-				end = i + 1
+// linkDefinitionPattern matches a line that looks like a link reference definition, such as
+// "[label]: https://example.com".
+var linkDefinitionPattern = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*\S`)
+
+// atxHeadingPattern matches the start of an ATX heading, such as "## Usage".
+var atxHeadingPattern = regexp.MustCompile(`^ {0,3}#{1,6}(\s|$)`)
+
+// wrapHardBreaks wraps a Markdown paragraph like wrapText, but keeps its hard line breaks: a line
+// ending in a backslash or two or more spaces ends a line of the output as well. A line that looks
+// like a link reference definition, which is text when it follows a paragraph without a blank
+// line, is kept on its own line.
+func wrapHardBreaks(text, firstPrefix, contPrefix string, opts Options) []string {
+	var out []string
+	prefix := func() string {
+		if len(out) == 0 {
+			return firstPrefix
+		}
+		return contPrefix
+	}
+	lines := strings.Split(text, "\n")
+	start := 0
+	for i, line := range lines {
+		if linkDefinitionPattern.MatchString(line) {
+			if start < i {
+				out = append(out, wrapText(strings.Join(lines[start:i], "\n"), prefix(), contPrefix, opts)...)
 			}
+			out = append(out, prefix()+line)
+			start = i + 1
+			continue
+		}
+		trimmed := strings.TrimRight(line, " ")
+		if i < len(lines)-1 && !strings.HasSuffix(line, "\\") && len(line)-len(trimmed) < 2 {
+			continue
+		}
+		wrapped := wrapText(strings.Join(lines[start:i+1], "\n"), prefix(), contPrefix, opts)
+		if i < len(lines)-1 {
+			wrapped[len(wrapped)-1] += line[len(trimmed):] // the spaces of the break
+		}
+		out = append(out, wrapped...)
+		start = i + 1
+	}
+	return out
+}
+
+// extendUnderline returns the lines of a setext heading, its text and then its underline of "="
+// or "-", with the underline lengthened to the width of the text if it is shorter. It returns nil
+// if lines are not a setext heading.
+func extendUnderline(lines []string, tabWidth int) []string {
+	if len(lines) < 2 || atxHeadingPattern.MatchString(lines[0]) {
+		return nil
+	}
+	underline := strings.TrimSpace(lines[len(lines)-1])
+	if underline == "" || strings.Trim(underline, underline[:1]) != "" || underline[0] != '=' && underline[0] != '-' {
+		return nil
+	}
+	width := 0
+	for _, line := range lines[:len(lines)-1] {
+		width = max(width, displayWidth(strings.TrimSpace(line), tabWidth))
+	}
+	out := slices.Clone(lines)
+	if len(underline) < width {
+		indent := lines[len(lines)-1][:leadingWidth(lines[len(lines)-1])]
+		out[len(out)-1] = indent + strings.Repeat(underline[:1], width)
+	}
+	return out
+}
+
+// continuationPrefix returns the prefix of the continuation lines of a paragraph whose first line
+// has prefix: the blockquote markers (">") are kept, and list markers are replaced with spaces of
+// the same width, so that "> - " gives ">   " and "- > " gives "  > ".
+func continuationPrefix(prefix string, tabWidth int) string {
+	var b strings.Builder
+	start := 0 // start of the text since the last blockquote marker
+	for i := 0; i < len(prefix); i++ {
+		if prefix[i] != '>' {
+			continue
+		}
+		gap := prefix[start:i]
+		if strings.TrimSpace(gap) != "" {
+			gap = strings.Repeat(" ", displayWidth(prefix[:i], tabWidth)-displayWidth(b.String(), tabWidth))
+		}
+		b.WriteString(gap)
+		b.WriteByte('>')
+		start = i + 1
+	}
+	rest := prefix[start:]
+	if strings.TrimSpace(rest) != "" {
+		rest = strings.Repeat(" ", displayWidth(prefix, tabWidth)-displayWidth(b.String(), tabWidth))
+	}
+	return b.String() + rest
+}
+
+// replaceListBullets replaces the bullet list markers in prefix (e.g., the "*" and "+" in
+// "> * + ") with bullet. The prefix is returned unchanged if bullet is empty.
+func replaceListBullets(prefix, bullet string) string {
+	if bullet == "" {
+		return prefix
+	}
+	var b strings.Builder
+	for i := 0; i < len(prefix); i++ {
+		c := prefix[i]
+		if (c == '-' || c == '*' || c == '+') && (i+1 == len(prefix) || prefix[i+1] == ' ' || prefix[i+1] == '\t') {
+			b.WriteString(bullet)
+			continue
 		}
+		b.WriteByte(c)
 	}
-	return prefix[:end]
+	return b.String()
 }
 
 // lineStartOffset returns the byte offset of the start of the line containing the given offset.
@@ -146,13 +275,131 @@ func lineStartOffset(src []byte, offset int) int {
 	return 0
 }
 
-// byteOffsetToLine converts a byte offset in src to a 0-indexed line number.
-func byteOffsetToLine(src []byte, offset int) int {
-	line := 0
-	for i := 0; i < offset && i < len(src); i++ {
-		if src[i] == '\n' {
-			line++
+// newlineOffsets returns the offsets of the newlines in src, in order.
+func newlineOffsets(src []byte) []int {
+	var offsets []int
+	for i, c := range src {
+		if c == '\n' {
+			offsets = append(offsets, i)
 		}
 	}
+	return offsets
+}
+
+// byteOffsetToLine converts a byte offset to a 0-indexed line number, given the offsets of the
+// newlines in the source from newlineOffsets.
+func byteOffsetToLine(newlines []int, offset int) int {
+	line, _ := slices.BinarySearch(newlines, offset)
 	return line
 }
+
+// wrapMarkdownLines rewraps lines of a comment body as Markdown. The caller reduces opts.Column by
+// the width of the comment prefix and adds the prefix back to each line. Lines that start with one of
+// tags (such as Elm's "@docs") are kept verbatim and end the paragraph before them.
+func wrapMarkdownLines(lines []string, tags []string, opts Options) []string {
+	var out []string
+	var chunk []string
+	flush := func() {
+		if len(chunk) == 0 {
+			return
+		}
+		md := string(processMarkdown([]byte(strings.Join(chunk, "\n")), opts))
+		out = append(out, strings.Split(md, "\n")...)
+		chunk = nil
+	}
+	for _, line := range lines {
+		if isDocTag(line, tags) {
+			flush()
+			out = append(out, line)
+			continue
+		}
+		chunk = append(chunk, line)
+	}
+	flush()
+	return out
+}
+
+// rewrapMarkdownBlock rewraps a block comment whose body is Markdown, such as Elm's "{-| ... -}".
+// Text on the opening line stays there, and the end marker is put on its own line.
+func rewrapMarkdownBlock(seg segment, lang *Language, opts Options) []string {
+	first := strings.TrimPrefix(seg.lines[0], seg.indent)
+	firstText := strings.TrimSpace(strings.TrimPrefix(first, seg.marker))
+
+	last := seg.lines[len(seg.lines)-1]
+	before, _, _ := strings.Cut(last, seg.end)
+
+	var body []string
+	if firstText != "" {
+		// Keep the start marker as the first word, so the first line's width accounts for it.
+		body = append(body, seg.marker+" "+firstText)
+	}
+	middle := append(append([]string{}, seg.lines[1:len(seg.lines)-1]...), strings.TrimRight(before, " \t"))
+	for i, line := range middle {
+		if strings.TrimSpace(line) == "" {
+			if i < len(middle)-1 {
+				body = append(body, "")
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, seg.indent) {
+			return seg.lines
+		}
+		body = append(body, strings.TrimRight(line[len(seg.indent):], " \t"))
+	}
+
+	inner := opts
+	inner.Column = max(opts.Column-displayWidth(seg.indent, opts.TabWidth), 1)
+	wrapped := wrapMarkdownLines(body, lang.DocTags, inner)
+	if firstText != "" && (len(wrapped) == 0 || !strings.HasPrefix(wrapped[0], seg.marker+" ")) {
+		return seg.lines
+	}
+
+	var out []string
+	if firstText == "" {
+		out = append(out, seg.lines[0])
+	}
+	for _, line := range wrapped {
+		if line == "" {
+			out = append(out, "")
+		} else {
+			out = append(out, seg.indent+line)
+		}
+	}
+	return append(out, seg.indent+seg.end)
+}
+
+// rewrapMarkdownDocstring rewraps a docstring whose body is Markdown, such as Elixir's
+// `@doc """`. The quotes stay on lines of their own, and the body keeps the indentation of the
+// closing quotes, which the language strips from each line. Docstrings laid out otherwise are left
+// as they are.
+func rewrapMarkdownDocstring(seg segment, lang *Language, opts Options) []string {
+	last := seg.lines[len(seg.lines)-1]
+	if len(seg.lines) < 3 || !strings.HasSuffix(strings.TrimRight(seg.lines[0], " \t"), seg.marker) ||
+		strings.TrimSpace(last) != seg.marker {
+		return seg.lines
+	}
+	bodyIndent := last[:leadingWidth(last)]
+	var body []string
+	for _, line := range seg.lines[1 : len(seg.lines)-1] {
+		if strings.TrimSpace(line) == "" {
+			body = append(body, "")
+			continue
+		}
+		if !strings.HasPrefix(line, bodyIndent) {
+			return seg.lines
+		}
+		body = append(body, strings.TrimRight(line[len(bodyIndent):], " \t"))
+	}
+
+	inner := opts
+	inner.Column = max(opts.Column-displayWidth(bodyIndent, opts.TabWidth), 1)
+	out := []string{seg.lines[0]}
+	for _, line := range wrapMarkdownLines(body, lang.DocTags, inner) {
+		if line == "" {
+			out = append(out, "")
+		} else {
+			out = append(out, bodyIndent+line)
+		}
+	}
+	return append(out, last)
+}
@@ -0,0 +1,230 @@
+package wrap
+
+import (
+	"regexp"
+	"strings"
+)
+
+// yamlKeyPattern matches a YAML mapping entry, optionally preceded by a sequence dash, capturing the
+// leading indentation (including any "- "), the key, and the value.
+var yamlKeyPattern = regexp.MustCompile(`^(\s*(?:-\s+)?)([^\s#'"{\[][^:#]*?|"[^"]*"|'[^']*')\s*:(?:\s+(.*))?$`)
+
+// yamlBlockHeaderPattern matches a block scalar header such as "|", ">-" or "|+2", with an optional
+// trailing comment.
+var yamlBlockHeaderPattern = regexp.MustCompile(`^[|>]([-+]?[1-9]?|[1-9][-+])\s*(#.*)?$`)
+
+// yamlKey is a parsed YAML mapping entry.
+type yamlKey struct {
+	column int    // display column of the key, which is the indentation of its value
+	name   string // key with any quotes removed
+	value  string // text after "key:", trimmed
+}
+
+// parseYAMLKey parses line as a YAML mapping entry ("key: value").
+func parseYAMLKey(line string) (yamlKey, bool) {
+	m := yamlKeyPattern.FindStringSubmatch(line)
+	if m == nil {
+		return yamlKey{}, false
+	}
+	name := m[2]
+	if len(name) >= 2 && (name[0] == '"' || name[0] == '\'') {
+		name = name[1 : len(name)-1]
+	}
+	return yamlKey{
+		column: len(m[1]),
+		name:   strings.TrimSpace(name),
+		value:  strings.TrimSpace(m[3]),
+	}, true
+}
+
+// yamlBlockEnd returns the index after the last content line of a block scalar whose header is on
+// line i and whose parent is indented to column. Trailing blank lines are not part of the block.
+func yamlBlockEnd(lines []string, i, column int) int {
+	end := i + 1
+	for j := i + 1; j < len(lines); j++ {
+		trimmed := strings.TrimLeft(lines[j], " ")
+		if trimmed == "" {
+			continue
+		}
+		if len(lines[j])-len(trimmed) <= column {
+			break
+		}
+		end = j + 1
+	}
+	return end
+}
+
+// processOpenAPI rewraps an OpenAPI/Swagger YAML document. In addition to "#" comments, the prose in
+// "description" fields is rewrapped: block scalars are rewrapped in place (as Markdown for literal
+// "|" scalars, which is what OpenAPI descriptions are) and single-line scalars that exceed the column
+// are converted to folded ">-" block scalars. JSON documents are returned unchanged, since JSON
+// strings cannot span lines.
+func processOpenAPI(lines []string, lang *Language, opts Options) []string {
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			if strings.HasPrefix(trimmed, "{") {
+				return lines
+			}
+			break
+		}
+	}
+
+	var out []string
+	var pending []string
+	pendingStart := 0 // line index of pending[0]
+	flush := func() {
+		out = append(out, processLines(pending, pendingStart, lang, opts)...)
+		pending = nil
+	}
+	add := func(i int) {
+		if len(pending) == 0 {
+			pendingStart = i
+		}
+		pending = append(pending, lines[i])
+	}
+	for i := 0; i < len(lines); {
+		key, ok := parseYAMLKey(lines[i])
+		if !ok {
+			add(i)
+			i++
+			continue
+		}
+		if yamlBlockHeaderPattern.MatchString(key.value) {
+			end := yamlBlockEnd(lines, i, key.column)
+			flush()
+			if key.name == "description" && opts.selected(i, end) && opts.withinLimits(lines[i:end]) {
+				out = append(out, rewrapYAMLBlockScalar(lines[i:end], key, opts)...)
+			} else {
+				out = append(out, lines[i:end]...)
+			}
+			i = end
+			continue
+		}
+		if key.name == "description" && displayWidth(lines[i], opts.TabWidth) > opts.Column && opts.selected(i, i+1) && opts.withinLimits(lines[i:i+1]) {
+			// A folded scalar joins its lines with single spaces, so a value with runs of spaces,
+			// tabs or surrounding space would change and is left alone.
+			if text, ok := yamlSingleLineScalar(key.value); ok && text == strings.Join(strings.Fields(text), " ") && !isYAMLContinued(lines, i, key.column) {
+				flush()
+				header := lines[i][:strings.Index(lines[i], ":")+1] + " >-"
+				indent := strings.Repeat(" ", key.column+2)
+				out = append(out, header)
+				out = append(out, wrapText(text, indent, indent, opts)...)
+				i++
+				continue
+			}
+		}
+		add(i)
+		i++
+	}
+	flush()
+	return out
+}
+
+// rewrapYAMLBlockScalar rewraps the content of a block scalar. The header line is kept as is. Literal
+// ("|") content is treated as Markdown; folded (">") content is wrapped as plain paragraphs. Blocks
+// with an explicit indentation indicator, more-indented lines or runs of blank lines are returned
+// unchanged because rewrapping them would change the scalar's value.
+func rewrapYAMLBlockScalar(block []string, key yamlKey, opts Options) []string {
+	header := key.value
+	if strings.ContainsAny(strings.Fields(header)[0], "123456789") || len(block) < 2 {
+		return block
+	}
+	content := block[1:]
+	indent := -1
+	for _, line := range content {
+		if trimmed := strings.TrimLeft(line, " "); trimmed != "" {
+			indent = len(line) - len(trimmed)
+			break
+		}
+	}
+	if indent < 0 {
+		return block
+	}
+	prefix := strings.Repeat(" ", indent)
+	var dedented []string
+	for i, line := range content {
+		if strings.TrimSpace(line) == "" {
+			if header[0] == '>' && i > 0 && dedented[i-1] == "" {
+				return block
+			}
+			dedented = append(dedented, "")
+			continue
+		}
+		if !strings.HasPrefix(line, prefix) {
+			return block
+		}
+		rest := line[indent:]
+		if header[0] == '>' && (rest[0] == ' ' || rest[0] == '\t') {
+			return block
+		}
+		dedented = append(dedented, rest)
+	}
+
+	var wrapped []string
+	if header[0] == '|' {
+		inner := opts
+		inner.Column = max(opts.Column-indent, 1)
+		inner.Lines = nil
+		md := string(processMarkdown([]byte(strings.Join(dedented, "\n")), inner))
+		for line := range strings.SplitSeq(md, "\n") {
+			if line == "" {
+				wrapped = append(wrapped, "")
+			} else {
+				wrapped = append(wrapped, prefix+line)
+			}
+		}
+	} else {
+		for _, line := range wrapText(strings.Join(dedented, "\n"), prefix, prefix, opts) {
+			if strings.TrimSpace(line) == "" {
+				line = ""
+			}
+			wrapped = append(wrapped, line)
+		}
+	}
+	return append([]string{block[0]}, wrapped...)
+}
+
+// yamlSingleLineScalar returns the text of a plain or quoted scalar that is complete on one line. It
+// returns false for values that cannot safely be moved into a block scalar, such as flow
+// collections, anchors, tags, double-quoted strings with escapes, or plain scalars with a trailing
+// comment.
+func yamlSingleLineScalar(value string) (string, bool) {
+	if value == "" {
+		return "", false
+	}
+	switch value[0] {
+	case '"':
+		if len(value) < 2 || value[len(value)-1] != '"' || strings.ContainsAny(value[1:len(value)-1], `"\`) {
+			return "", false
+		}
+		return value[1 : len(value)-1], true
+	case '\'':
+		if len(value) < 2 || value[len(value)-1] != '\'' {
+			return "", false
+		}
+		inner := value[1 : len(value)-1]
+		if strings.Count(inner, "'")%2 != 0 {
+			return "", false
+		}
+		return strings.ReplaceAll(inner, "''", "'"), true
+	case '|', '>', '&', '*', '!', '{', '[', '@', '`', '%', '#':
+		return "", false
+	}
+	if strings.Contains(value, " #") {
+		return "", false
+	}
+	return value, true
+}
+
+// isYAMLContinued reports whether the scalar on line i continues onto the following lines, which
+// happens when the next non-blank line is indented deeper than the key.
+func isYAMLContinued(lines []string, i, column int) bool {
+	for j := i + 1; j < len(lines); j++ {
+		trimmed := strings.TrimLeft(lines[j], " ")
+		if trimmed == "" {
+			continue
+		}
+		return len(lines[j])-len(trimmed) > column
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package wrap
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// orgBlockBegin matches the first line of a block, such as "#+BEGIN_SRC go", and captures its
+	// type, and orgDrawer the first line of a drawer, such as ":PROPERTIES:".
+	orgBlockBegin = regexp.MustCompile(`(?i)^#\+begin_(\S+)`)
+	orgDrawer     = regexp.MustCompile(`^:[\w-]+:$`)
+
+	// orgStructure matches a line that stands on its own: a headline, a keyword such as
+	// "#+TITLE:", a comment, a table row, a fixed-width line (": text"), a horizontal rule, a
+	// planning line such as "SCHEDULED: <2024-01-01>" or a LaTeX environment.
+	orgStructure = regexp.MustCompile(`^\*+ |^\*+$|^#\+|^#( |$)|^\||^\+-|^:( |$)|^-{5,}$|^(SCHEDULED|DEADLINE|CLOSED):|^\\(begin|end)\{`)
+)
+
+// processOrg rewraps the paragraphs of an Org document, including list items. Headlines,
+// keywords, comments, tables, planning lines, fixed-width lines, drawers (such as property
+// drawers) and blocks (from "#+BEGIN_SRC" to "#+END_SRC", and the like) are kept as they are.
+func processOrg(lines []string, opts Options) []string {
+	opts.canStartLine = orgCanStartLine
+	var out []string
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			out = append(out, line)
+			i++
+			continue
+		}
+		if end := orgBlockEnd(lines, i); end > i {
+			out = append(out, lines[i:end]...)
+			i = end
+			continue
+		}
+		if orgStructure.MatchString(orgLine(line)) {
+			out = append(out, line)
+			i++
+			continue
+		}
+		// A paragraph ends at a list item; a list item's paragraph runs on to the items after it.
+		start, isList := i, listMarker(trimmed) != ""
+		for i++; i < len(lines); i++ {
+			next := strings.TrimSpace(lines[i])
+			if next == "" || orgBlockEnd(lines, i) > i || orgStructure.MatchString(orgLine(lines[i])) || (!isList && listMarker(next) != "") {
+				break
+			}
+		}
+		para := lines[start:i]
+		indent := line[:leadingWidth(line)]
+		switch {
+		case !opts.selected(start, i) || !opts.withinLimits(para):
+			out = append(out, para...)
+		case isList:
+			text := make([]string, len(para))
+			for j, line := range para {
+				text[j], _ = strings.CutPrefix(line, indent)
+			}
+			out = append(out, wrapItems(strings.Join(text, "\n"), indent, indent, nil, opts)...)
+		default:
+			out = append(out, wrapText(strings.Join(para, "\n"), indent, indent, opts)...)
+		}
+	}
+	return out
+}
+
+// orgCanStartLine reports whether a wrapped line may start with word after prefix: not if the
+// line would become a headline, keyword, comment, table row, list item or other structure.
+func orgCanStartLine(prefix, word string) bool {
+	line := prefix + word + " "
+	return !orgStructure.MatchString(orgLine(line)) && listMarker(strings.TrimLeft(line, " \t")) == "" &&
+		!orgDrawer.MatchString(word) && !orgBlockBegin.MatchString(word)
+}
+
+// orgLine returns line without its indentation, except that a headline is only a headline at the
+// start of a line: an indented "* " starts a list item.
+func orgLine(line string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed != line && strings.HasPrefix(trimmed, "*") {
+		return line
+	}
+	return trimmed
+}
+
+// orgBlockEnd returns the index of the line after the block or drawer that starts at lines[i], or
+// i if none does. A block or drawer that is not closed is not one, as in Org.
+func orgBlockEnd(lines []string, i int) int {
+	trimmed := strings.TrimSpace(lines[i])
+	closing := ":END:"
+	if m := orgBlockBegin.FindStringSubmatch(trimmed); m != nil {
+		closing = "#+end_" + m[1]
+	} else if !orgDrawer.MatchString(trimmed) || strings.EqualFold(trimmed, closing) {
+		return i
+	}
+	for j := i + 1; j < len(lines); j++ {
+		if strings.EqualFold(strings.TrimSpace(lines[j]), closing) {
+			return j + 1
+		}
+	}
+	return i
+}
@@ -0,0 +1,63 @@
+package wrap
+
+import (
+	"regexp"
+	"strings"
+)
+
+// podCommand matches a POD command paragraph's first line, such as "=head1 NAME" or "=cut", which
+// starts POD documentation in Perl code when it is at the start of a line.
+var podCommand = regexp.MustCompile(`^=([a-zA-Z]\w*)`)
+
+// tryPod tries to parse POD documentation starting at line index i: from a command line through the
+// "=cut" line that ends it, or through the end of the source if none does. See Language.Pod.
+func tryPod(lines []string, i int) (segment, int) {
+	m := podCommand.FindStringSubmatch(lines[i])
+	if m == nil || m[1] == "cut" {
+		return segment{}, i
+	}
+	for j := i + 1; j < len(lines); j++ {
+		if m := podCommand.FindStringSubmatch(lines[j]); m != nil && m[1] == "cut" {
+			return segment{typ: segmentPod, lines: lines[i : j+1], marker: "="}, j + 1
+		}
+	}
+	return segment{typ: segmentPod, lines: lines[i:], marker: "="}, len(lines)
+}
+
+// rewrapPod rewraps the ordinary paragraphs of POD documentation. Command paragraphs ("=head1",
+// "=item"), verbatim paragraphs (those that start indented), and everything from "=begin" to
+// "=end" or in a "=for" paragraph, which is for a particular formatter, are kept as they are.
+func rewrapPod(seg segment, opts Options) []string {
+	var out []string
+	region := "" // the formatter of the "=begin" region we are in, if any
+	for i := 0; i < len(seg.lines); {
+		if strings.TrimSpace(seg.lines[i]) == "" {
+			out = append(out, seg.lines[i])
+			i++
+			continue
+		}
+		start := i
+		for i < len(seg.lines) && strings.TrimSpace(seg.lines[i]) != "" {
+			i++
+		}
+		para := seg.lines[start:i]
+		first := para[0]
+		if m := podCommand.FindStringSubmatch(first); m != nil {
+			fields := strings.Fields(first)
+			switch {
+			case m[1] == "begin" && len(fields) > 1:
+				region = fields[1]
+			case m[1] == "end":
+				region = ""
+			}
+			out = append(out, para...)
+			continue
+		}
+		if region != "" || first[0] == ' ' || first[0] == '\t' {
+			out = append(out, para...)
+			continue
+		}
+		out = append(out, wrapText(strings.Join(para, "\n"), "", "", opts)...)
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTryPod(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int // index after the POD, or 0 if there is none
+	}{
+		{"through cut", "=head1 NAME\n\nx - y\n\n=cut\nmy $x;", 5},
+		{"to the end", "=pod\n\ntext", 3},
+		{"cut alone", "=cut\nmy $x;", 0},
+		{"indented", "  =head1 NAME\n=cut", 0},
+		{"not a command", "=1;\n=cut", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, end := tryPod(strings.Split(tt.input, "\n"), 0)
+			assert.Equal(t, tt.want, end)
+		})
+	}
+}
+
+func TestSource_Shebang(t *testing.T) {
+	src := "#!/usr/bin/env perl\n# one two three four\nprint 1;\n"
+	got := Source([]byte(src), LanguageFromName("perl"), 14, 4)
+	assert.Equal(t, "#!/usr/bin/env perl\n# one two\n# three four\nprint 1;\n", string(got))
+}
@@ -1,41 +1,260 @@
 package wrap
 
 import (
+	"bytes"
+	"cmp"
 	"go/doc/comment"
+	"regexp"
+	"slices"
 	"strings"
 )
 
+// DefaultColumn and DefaultTabWidth are used when Options leaves the column or tab width unset. A
+// language with its own Column uses that instead of DefaultColumn.
+const (
+	DefaultColumn   = 100
+	DefaultTabWidth = 4
+)
+
+// exampleOutput matches the start of the output section of a Go example function, as go test does.
+var exampleOutput = regexp.MustCompile(`(?i)^[[:space:]]*(unordered )?output:`)
+
+// Options controls how text is rewrapped.
+type Options struct {
+	Column   int // wrapping column width; 0 means the language's Column, or DefaultColumn
+	TabWidth int // tab display width for column calculations; 0 means DefaultTabWidth
+
+	// Bullet, if non-empty, is used for every unordered list item in comments, plain text and
+	// Markdown, replacing "*", "+" and "•" bullets. Go doc comment lists always use "-", matching
+	// gofmt.
+	Bullet string
+
+	// ExpandTabs converts tabs within comment text to spaces, using TabWidth. Code and Go doc
+	// comment code blocks are never changed.
+	ExpandTabs bool
+
+	// KeepNarrow keeps a comment block that is already consistently wrapped at a column narrower
+	// than Column, such as a sidebar or quoted text, at that narrower column.
+	KeepNarrow bool
+
+	// MinLines leaves comment blocks with fewer lines than this unchanged, such as a lone "# ok",
+	// unless one of their lines is wider than Column. Zero means every block is rewrapped.
+	MinLines int
+
+	// MixedIndent joins consecutive line comments whose indentation differs in its whitespace but
+	// not in its display width, such as a tab and TabWidth spaces, into one block, which is then
+	// indented like its first line. Otherwise a change of indentation starts a new block.
+	MixedIndent bool
+
+	// FieldComments moves a Go line comment that trails a struct field, or a constant or variable
+	// in a declaration, onto its own line above it when the line is wider than Column, where it is
+	// rewrapped as a doc comment at the field's indentation. Fields that already have a doc
+	// comment, and trailing directives, are left alone. Input that is not valid Go is only
+	// rewrapped as usual.
+	FieldComments bool
+
+	// Lines, if not empty, restricts rewrapping to the comment blocks, and in Markdown and plain
+	// text the paragraphs, that overlap one of the ranges. Everything else is left as it is.
+	Lines []LineRange
+
+	// Prefix, if not empty, is stripped from the start of each line of plain text, which is then
+	// rewrapped with Prefix at the start of each line, such as "> " for quoted mail. It is not used
+	// for source code or Markdown.
+	Prefix string
+
+	// PreserveSentenceStarts keeps the line breaks that do not clearly continue a sentence, such
+	// as semantic line breaks after each sentence: lines are only joined when the first does not
+	// end with terminal punctuation and the second starts with a lower-case letter. Long lines are
+	// still wrapped.
+	PreserveSentenceStarts bool
+
+	// BreakLongWords rewraps lines of plain text that look like data, such as minified code or
+	// base64 (see isDataLine), which are otherwise left as they are, and breaks words wider than
+	// Column at the column, except URLs and paths. It is not used for source code or Markdown.
+	BreakLongWords bool
+
+	// FrontMatterFields lists the keys of Markdown's YAML front matter whose values are prose, such
+	// as "description", to rewrap with their continuation lines indented. Front matter is otherwise
+	// left as it is, and so is TOML front matter.
+	FrontMatterFields []string
+
+	// FormatTables pads the cells of Markdown tables so that their pipes line up, keeping the
+	// alignment of each column. Rows stay on one line, however wide. Otherwise tables are left as
+	// they are.
+	FormatTables bool
+
+	// ExtendUnderlines lengthens the underline of a Markdown setext heading ("===" or "---" under
+	// its text) that is shorter than the heading's text. Otherwise headings are left as they are.
+	ExtendUnderlines bool
+
+	// Anchored lists line comment text, in addition to the language's Anchored, that other tools
+	// look for at the start of a comment line, such as "nolint:" or "+kubebuilder:". A comment line
+	// that starts with one is left as it is, explanation and all, and is never joined with the lines
+	// around it.
+	Anchored []string
+
+	// Rules turns on and off the heuristics that leave comment lines as they are, such as
+	// decoration lines and table rows.
+	Rules Rules
+
+	// Limits bounds the work done on each input, for untrusted input such as in a server. The zero
+	// value means no limits.
+	Limits Limits
+
+	markdown bool // the text is Markdown, whose inline links are never broken across lines
+
+	// canStartLine, if set, reports whether a wrapped line may start with word after prefix. A word
+	// that would change what the line is, such as "*" making an Org line a headline, is kept on
+	// the line before instead.
+	canStartLine func(prefix, word string) bool
+}
+
+// Limits bounds the input that is rewrapped. Input beyond a limit is left unchanged rather than
+// rejected, so a single pathological document or comment cannot use unbounded time or memory. Zero
+// fields mean no limit.
+type Limits struct {
+	MaxInputSize  int // inputs larger than this many bytes are returned unchanged
+	MaxLineLength int // comment blocks and paragraphs with a line longer than this many bytes are left unchanged
+	MaxBlockLines int // comment blocks and paragraphs with more lines than this are left unchanged
+
+	// MaxMarkdownPrefix bounds the nesting of Markdown, which is slow to parse when deep: Markdown
+	// with a line that starts with more than this many bytes of indentation and quote and list
+	// markers is left unchanged.
+	MaxMarkdownPrefix int
+}
+
+// DefaultLimits are limits suitable for untrusted input. They are far beyond what hand-written
+// comments need.
+var DefaultLimits = Limits{
+	MaxInputSize:      16 << 20,
+	MaxLineLength:     64 << 10,
+	MaxBlockLines:     10000,
+	MaxMarkdownPrefix: 1000,
+}
+
+// markdownWithinLimits reports whether Markdown src may be rewrapped under o.Limits.
+func (o Options) markdownWithinLimits(src []byte) bool {
+	if o.Limits.MaxMarkdownPrefix <= 0 {
+		return true
+	}
+	for line := range bytes.Lines(src) {
+		if len(line)-len(bytes.TrimLeft(line, " \t>-*+0123456789.)")) > o.Limits.MaxMarkdownPrefix {
+			return false
+		}
+	}
+	return true
+}
+
+// withinLimits reports whether a comment block or paragraph of lines may be rewrapped under
+// o.Limits.
+func (o Options) withinLimits(lines []string) bool {
+	if o.Limits.MaxBlockLines > 0 && len(lines) > o.Limits.MaxBlockLines {
+		return false
+	}
+	if o.Limits.MaxLineLength > 0 {
+		for _, line := range lines {
+			if len(line) > o.Limits.MaxLineLength {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// tooSmall reports whether a comment block of lines has fewer than o.MinLines lines, none of them
+// wider than o.Column, and so is left unchanged.
+func (o Options) tooSmall(lines []string) bool {
+	if len(lines) >= o.MinLines {
+		return false
+	}
+	for _, line := range lines {
+		if displayWidth(line, o.TabWidth) > o.Column {
+			return false
+		}
+	}
+	return true
+}
+
+// LineRange is a range of lines, numbered from 1. Both Start and End are included.
+type LineRange struct {
+	Start, End int
+}
+
+// selected reports whether the 0-based, half-open range of lines [start, end) overlaps opts.Lines,
+// or whether opts.Lines is empty.
+func (o Options) selected(start, end int) bool {
+	if len(o.Lines) == 0 {
+		return true
+	}
+	for _, r := range o.Lines {
+		if r.Start <= end && r.End > start {
+			return true
+		}
+	}
+	return false
+}
+
 // Source rewraps comment blocks in src according to the given language and column width. If lang is
-// nil, the entire input is treated as plain text.
+// nil, the entire input is treated as plain text. New returns a Wrapper that takes any other
+// settings as options.
 func Source(src []byte, lang *Language, column int, tabWidth int) []byte {
+	return SourceWithOptions(src, lang, Options{Column: column, TabWidth: tabWidth})
+}
+
+// SourceWithOptions is like Source, but takes the full set of Options. Line endings are
+// normalized to "\n", except that input with only "\r\n" line endings keeps them, so that lines
+// that are not rewrapped are unchanged.
+func SourceWithOptions(src []byte, lang *Language, opts Options) []byte {
+	if opts.Limits.MaxInputSize > 0 && len(src) > opts.Limits.MaxInputSize {
+		return src
+	}
+	n := bytes.Count(src, []byte("\n"))
+	if n > 0 && bytes.Count(src, []byte("\r\n")) == n {
+		return bytes.ReplaceAll(sourceWithOptions(src, lang, opts), []byte("\n"), []byte("\r\n"))
+	}
+	return sourceWithOptions(src, lang, opts)
+}
+
+func sourceWithOptions(src []byte, lang *Language, opts Options) []byte {
+	opts.Column = cmp.Or(opts.Column, lang.column(), DefaultColumn)
+	opts.TabWidth = cmp.Or(opts.TabWidth, DefaultTabWidth)
 	text := string(src)
 	// Normalize line endings.
 	text = strings.ReplaceAll(text, "\r\n", "\n")
 	text = strings.ReplaceAll(text, "\r", "\n")
+	if lang != nil && lang.Name == "go" && opts.FieldComments {
+		text, opts.Lines = moveFieldComments(text, opts)
+	}
 
 	lines := strings.Split(text, "\n")
 
 	// Plain text mode: no language, wrap everything.
 	if lang == nil {
-		return []byte(wrapPlainText(lines, column, tabWidth))
+		return []byte(wrapPlainText(lines, opts))
 	}
 
+	opts.BreakLongWords = false // plain text only
+
 	// Markdown mode: use AST-based processing.
 	if lang.Name == "markdown" {
-		return processMarkdown(src, column, tabWidth)
+		if n := frontMatterEnd(lines); n > 0 {
+			return processFrontMatter(lines, n, opts)
+		}
+		return processMarkdown(src, opts)
 	}
 
-	segments := parseSegments(lines, lang)
 	var out []string
-	for _, seg := range segments {
-		switch seg.typ {
-		case segmentCode:
-			out = append(out, seg.lines...)
-		case segmentComment:
-			out = append(out, rewrapLineComments(seg, lang, column, tabWidth)...)
-		case segmentBlock:
-			out = append(out, rewrapBlockComment(seg, lang, column, tabWidth)...)
-		}
+	switch lang.Name {
+	case "openapi":
+		out = processOpenAPI(lines, lang, opts)
+	case "rst":
+		out = processRST(lines, opts)
+	case "asciidoc":
+		out = processAsciiDoc(lines, opts)
+	case "org":
+		out = processOrg(lines, opts)
+	default:
+		out = processLines(lines, 0, lang, opts)
 	}
 	result := strings.Join(out, "\n")
 	// Preserve trailing newline if original had one.
@@ -45,10 +264,109 @@ func Source(src []byte, lang *Language, column int, tabWidth int) []byte {
 	return []byte(result)
 }
 
-// rewrapLineComments rewraps a block of consecutive line comments. Decoration lines (lines
-// consisting entirely of repeated punctuation like //========) are preserved verbatim and act as
-// boundaries between wrappable runs of text.
-func rewrapLineComments(seg segment, lang *Language, column, tabWidth int) []string {
+// processLines splits lines into code and comment segments and rewraps the comments. The first
+// line is line first of the source, counting from 0, for opts.Lines.
+func processLines(lines []string, first int, lang *Language, opts Options) []string {
+	var out []string
+	n := first
+	seenComment := first > 0 // only the source's first comment can be a license header
+	segs := parseSegments(lines, lang)
+	if opts.MixedIndent {
+		segs = mergeMixedIndents(segs, opts.TabWidth)
+	}
+	for _, seg := range segs {
+		start := n
+		n += len(seg.lines)
+		if seg.typ == segmentCode {
+			out = append(out, seg.lines...)
+			continue
+		}
+		top := !seenComment && startsFile(lines[:start-first])
+		seenComment = true
+		if skipReason(seg, start, n, top, opts) != "" {
+			out = append(out, seg.lines...)
+			continue
+		}
+		out = append(out, rewrapSegment(seg, lang, opts)...)
+	}
+	return out
+}
+
+// rewrapSegment rewraps the comment segment seg.
+func rewrapSegment(seg segment, lang *Language, opts Options) []string {
+	opts.Lines = nil
+	if opts.KeepNarrow {
+		if w := existingWidth(seg.lines, opts.TabWidth); w > 0 && w < opts.Column {
+			opts.Column = w
+		}
+	}
+	switch seg.typ {
+	case segmentComment:
+		return rewrapLineComments(seg, lang, opts)
+	case segmentBlock:
+		return rewrapBlockComment(seg, lang, opts)
+	case segmentDocstring:
+		if slices.Contains(lang.Markdown, seg.marker) {
+			return rewrapMarkdownDocstring(seg, lang, opts)
+		}
+		return rewrapDocstring(seg, opts)
+	case segmentPod:
+		return rewrapPod(seg, opts)
+	}
+	return seg.lines
+}
+
+// skipReason returns why the comment segment seg, lines [start, end) of the source, is left as it
+// is, or "" if it is rewrapped. See Block for the reasons. top reports whether seg is the first
+// comment of the source, and at its top; see startsFile.
+func skipReason(seg segment, start, end int, top bool, opts Options) string {
+	switch {
+	case top && opts.Rules.on(SkipLicense) && isLicenseHeader(seg):
+		return SkipLicense
+	case !opts.selected(start, end):
+		return SkipNotSelected
+	case !opts.withinLimits(seg.lines):
+		return SkipLimits
+	case opts.tooSmall(seg.lines):
+		return SkipMinLines
+	case seg.nested:
+		return SkipNested
+	}
+	return ""
+}
+
+// rewrapLineComments rewraps a block of consecutive line comments. Lines kept by Options.Rules,
+// such as decoration lines (repeated punctuation like //========) and anchored lines (see
+// Options.Anchored), are preserved verbatim and act as boundaries between wrappable runs of text.
+func rewrapLineComments(seg segment, lang *Language, opts Options) []string {
+	// A "rewrap:lang=NAME" line at the top of the block says how to rewrap the rest of it. The
+	// line itself is kept.
+	if name, ok := commentLang(seg.lines, seg.marker); ok {
+		if len(seg.lines) == 1 || (name != "markdown" && name != "text") {
+			return seg.lines // nothing to rewrap, or an unknown name
+		}
+		rest := seg
+		rest.lines = seg.lines[1:]
+		as := *lang
+		as.Name, as.Markdown = "", nil // no Go doc comment or Markdown rewrapping
+		if name == "markdown" {
+			as.Markdown = []string{strings.TrimSpace(seg.marker)}
+		}
+		return append([]string{seg.lines[0]}, rewrapLineComments(rest, &as, opts)...)
+	}
+
+	// The "Output:" section of a Go example is compared with what the example prints, so it is
+	// never rewrapped. Such comments are inside a function body, and so indented.
+	if lang.Name == "go" && seg.indent != "" {
+		for i, line := range seg.lines {
+			if exampleOutput.MatchString(strings.TrimPrefix(strings.TrimLeft(line, " \t"), "//")) {
+				head := seg
+				head.lines = seg.lines[:i]
+				return append(rewrapLineComments(head, lang, opts), seg.lines[i:]...)
+			}
+		}
+	}
+
 	// Extract comment text, stripping indent and marker.
 	type commentLine struct {
 		raw     string // original source line
@@ -84,7 +402,7 @@ func rewrapLineComments(seg segment, lang *Language, column, tabWidth int) []str
 					textLines = append(textLines, "")
 				}
 			}
-			out = append(out, rewrapGoDocComment(textLines, seg.indent, column, tabWidth)...)
+			out = append(out, rewrapGoDocComment(textLines, seg.indent, opts)...)
 			runStart = -1
 			return
 		}
@@ -92,18 +410,48 @@ func rewrapLineComments(seg segment, lang *Language, column, tabWidth int) []str
 		for _, cl := range lines[runStart:end] {
 			textLines = append(textLines, cl.content)
 		}
+		if slices.Contains(lang.Markdown, strings.TrimSpace(seg.marker)) {
+			prefix := seg.indent + seg.marker
+			inner := opts
+			inner.Column = max(opts.Column-displayWidth(prefix, opts.TabWidth), 1)
+			for _, line := range wrapMarkdownLines(textLines, lang.DocTags, inner) {
+				out = append(out, strings.TrimRight(prefix+line, " "))
+			}
+			runStart = -1
+			return
+		}
 		{
 			joined := strings.Join(textLines, "\n")
 			prefix := seg.indent + seg.marker
-			out = append(out, wrapText(joined, prefix, prefix, column, tabWidth)...)
+			out = append(out, wrapItems(joined, prefix, prefix, lang.docTags(seg.marker), opts)...)
 		}
 		runStart = -1
 	}
+	inCode := false // after one of lang.CodeTags, up to the next doc tag
 	for i, cl := range lines {
-		if isDecorationLine(cl.content) {
+		if trimmed := strings.TrimSpace(cl.content); hasAnyPrefix(trimmed, lang.DocTags) {
+			tag, _, _ := strings.Cut(trimmed, " ")
+			inCode = slices.Contains(lang.CodeTags, tag)
+		}
+		if inCode {
 			flush(i)
-			out = append(out, seg.indent+seg.marker+cl.content)
-		} else {
+			out = append(out, cl.raw)
+			continue
+		}
+		switch protectedLine(cl.content, lang, opts) {
+		case SkipAnchored, SkipTable, SkipCode:
+			flush(i)
+			out = append(out, cl.raw)
+		case SkipDecoration:
+			flush(i)
+			if strings.Trim(cl.raw, " \t"+strings.TrimSpace(seg.marker)) == "" {
+				// A rule made of the marker itself, such as ";;;;;;;;", would be split by the space
+				// after the marker.
+				out = append(out, cl.raw)
+			} else {
+				out = append(out, seg.indent+seg.marker+cl.content)
+			}
+		default:
 			if runStart < 0 {
 				runStart = i
 			}
@@ -113,10 +461,36 @@ func rewrapLineComments(seg segment, lang *Language, column, tabWidth int) []str
 	return out
 }
 
+// commentLang returns NAME if the first of lines is the line comment "rewrap:lang=NAME", with
+// marker, and whether it is. The names are "markdown" (or "md") and "text".
+func commentLang(lines []string, marker string) (string, bool) {
+	if len(lines) == 0 {
+		return "", false
+	}
+	text, ok := strings.CutPrefix(strings.TrimLeft(lines[0], " \t"), strings.TrimSpace(marker))
+	if !ok {
+		return "", false
+	}
+	name, ok := strings.CutPrefix(strings.TrimSpace(text), "rewrap:lang=")
+	if name == "md" {
+		name = "markdown"
+	}
+	return name, ok
+}
+
+// isAnchored reports whether the comment text content starts with one of the anchored markers of
+// lang or opts, holds Emacs file variables ("-*- mode: lisp -*-"), or is an injection annotation
+// ("language=SQL"), which must stay on one line.
+func isAnchored(content string, lang *Language, opts Options) bool {
+	content = strings.TrimLeft(content, " \t")
+	return hasAnyPrefix(content, lang.Anchored) || hasAnyPrefix(content, opts.Anchored) || strings.Count(content, "-*-") >= 2 ||
+		isInjection(content)
+}
+
 // rewrapGoDocComment rewraps Go doc comments using comment.Parser for structure detection, then
 // renders each block directly to preserve original text content (whitespace, doc link brackets).
 // The textLines parameter contains lines with "//" stripped (preserving leading space or tab).
-func rewrapGoDocComment(textLines []string, indent string, column, tabWidth int) []string {
+func rewrapGoDocComment(textLines []string, indent string, opts Options) []string {
 	prefix := indent + "// "
 	bareMarker := indent + "//"
 
@@ -173,7 +547,7 @@ func rewrapGoDocComment(textLines []string, indent string, column, tabWidth int)
 		switch b := block.(type) {
 		case *comment.Paragraph:
 			text := docInlineText(b.Text)
-			result = append(result, wrapText(text, prefix, prefix, column, tabWidth)...)
+			result = append(result, wrapText(text, prefix, prefix, opts)...)
 		case *comment.Code:
 			lines := strings.Split(strings.TrimRight(b.Text, "\n"), "\n")
 			for _, line := range lines {
@@ -186,7 +560,7 @@ func rewrapGoDocComment(textLines []string, indent string, column, tabWidth int)
 		case *comment.Heading:
 			result = append(result, prefix+"# "+docInlineText(b.Text))
 		case *comment.List:
-			result = append(result, renderDocList(b, prefix, bareMarker, column, tabWidth)...)
+			result = append(result, renderDocList(b, prefix, bareMarker, opts)...)
 		}
 	}
 
@@ -219,7 +593,7 @@ func docInlineText(texts []comment.Text) string {
 }
 
 // renderDocList renders a comment.List using appropriate bullet/number prefixes and wrapText.
-func renderDocList(list *comment.List, prefix, bareMarker string, column, tabWidth int) []string {
+func renderDocList(list *comment.List, prefix, bareMarker string, opts Options) []string {
 	var result []string
 	for i, item := range list.Items {
 		if i > 0 && list.ForceBlankBetween {
@@ -243,9 +617,9 @@ func renderDocList(list *comment.List, prefix, bareMarker string, column, tabWid
 			if para, ok := block.(*comment.Paragraph); ok {
 				text := docInlineText(para.Text)
 				if j == 0 {
-					result = append(result, wrapText(text, firstPrefix, contPrefix, column, tabWidth)...)
+					result = append(result, wrapText(text, firstPrefix, contPrefix, opts)...)
 				} else {
-					result = append(result, wrapText(text, contPrefix, contPrefix, column, tabWidth)...)
+					result = append(result, wrapText(text, contPrefix, contPrefix, opts)...)
 				}
 			}
 		}
@@ -254,29 +628,61 @@ func renderDocList(list *comment.List, prefix, bareMarker string, column, tabWid
 }
 
 // rewrapBlockComment rewraps a block comment (/* ... */).
-func rewrapBlockComment(seg segment, lang *Language, column, tabWidth int) []string {
+func rewrapBlockComment(seg segment, lang *Language, opts Options) []string {
 	if len(seg.lines) == 0 {
 		return seg.lines
 	}
 
-	// Single-line block comments: pass through.
-	if len(seg.lines) == 1 {
+	// Single-line block comments, blocks with nested comments and blocks with code after the end
+	// marker, such as a template action after "*/}}": pass through.
+	if len(seg.lines) == 1 || seg.nested {
+		return seg.lines
+	}
+	if _, after, ok := strings.Cut(seg.lines[len(seg.lines)-1], seg.end); ok && strings.TrimSpace(after) != "" {
 		return seg.lines
 	}
+	if slices.Contains(lang.Markdown, seg.marker) {
+		return rewrapMarkdownBlock(seg, lang, opts)
+	}
 
-	startMarker := lang.BlockStart[0]
-	endMarker := lang.BlockEnd[0]
+	startMarker := seg.marker
+	endMarker := seg.end
+	opener := startMarker
+	bare := lang.BlockBare
+	bodyWidth := -1 // common indentation of the lines in a bare block, relative to the block
+	// Keep the author's layout: text on the opener line ("/* Text") or a bare opener, and a closer
+	// trailing the last line ("text */") or on its own line.
+	openerText, trailingCloser := false, false
+	starred, continued := false, false // whether continuation lines have text, and any of them a "*"
+	// Whether every "*" is under the second "*" of "/**", as Scaladoc has it, rather than the first.
+	underSecond := true
+	starAt := func(line string) {
+		starred = true
+		if !strings.HasPrefix(line, seg.indent) || leadingWidth(line) != len(seg.indent)+2 {
+			underSecond = false
+		}
+	}
 
 	// Extract content lines between start and end markers.
 	var textLines []string
 	for i, line := range seg.lines {
 		stripped := strings.TrimLeft(line, " \t")
+		if bare && i > 0 {
+			// Keep indentation relative to the block so nested structure survives.
+			stripped = strings.TrimPrefix(line, seg.indent)
+		}
 		if i == 0 {
 			// Remove start marker.
 			after := strings.TrimPrefix(stripped, startMarker)
+			if !bare {
+				// Extra stars belong to the opener, as in "/**".
+				stars := len(after) - len(strings.TrimLeft(after, "*"))
+				opener, after = startMarker+after[:stars], after[stars:]
+			}
 			after = strings.TrimSpace(after)
 			if after != "" {
 				textLines = append(textLines, after)
+				openerText = true
 			}
 			continue
 		}
@@ -284,11 +690,24 @@ func rewrapBlockComment(seg segment, lang *Language, column, tabWidth int) []str
 			// Last line - remove end marker.
 			before, _, _ := strings.Cut(stripped, endMarker)
 			before = strings.TrimSpace(before)
-			// Remove leading * if present.
-			before = strings.TrimPrefix(before, "*")
-			before = strings.TrimSpace(before)
+			if !bare {
+				// Remove leading * if present.
+				if strings.HasPrefix(before, "*") {
+					starAt(line)
+				}
+				before = strings.TrimPrefix(before, "*")
+				before = strings.TrimSpace(before)
+			}
 			if before != "" {
 				textLines = append(textLines, before)
+				trailingCloser, continued = true, true
+			}
+			continue
+		}
+		if bare {
+			textLines = append(textLines, stripped)
+			if w := leadingWidth(stripped); strings.TrimSpace(stripped) != "" && (bodyWidth < 0 || w < bodyWidth) {
+				bodyWidth = w
 			}
 			continue
 		}
@@ -298,31 +717,131 @@ func rewrapBlockComment(seg segment, lang *Language, column, tabWidth int) []str
 		if content == stripped {
 			content = strings.TrimPrefix(content, "*")
 		}
+		if content != stripped {
+			starAt(line)
+		} else if strings.TrimSpace(content) != "" {
+			continued = true
+		}
 		textLines = append(textLines, content)
 	}
 
 	// Determine the prefix for wrapped lines.
 	blockPrefix := lang.BlockPrefix
-	if blockPrefix == "" {
+	if starred && underSecond && !bare {
+		blockPrefix = "  * "
+	}
+	if blockPrefix == "" && !bare {
 		blockPrefix = " * "
+		if openerText && continued && !starred {
+			// "/* Text" continued without stars: align with the text after the opener.
+			blockPrefix = strings.Repeat(" ", len(startMarker)+1)
+		}
 	}
 	innerPrefix := seg.indent + blockPrefix
+	if bare && bodyWidth > 0 {
+		// Keep the body's own indentation.
+		bodyIndent := ""
+		for i, line := range textLines {
+			if leadingWidth(line) >= bodyWidth {
+				bodyIndent = line[:bodyWidth]
+				textLines[i] = line[bodyWidth:]
+			}
+		}
+		innerPrefix += bodyIndent
+	}
 
 	joined := strings.Join(textLines, "\n")
-	wrapped := wrapText(joined, innerPrefix, innerPrefix, column, tabWidth)
+	firstPrefix := innerPrefix
+	if openerText {
+		firstPrefix = seg.indent + opener + " "
+	}
+	if trailingCloser {
+		// Wrap the closer with the text, as a final word that never starts a line, since the next
+		// pass would take a line of only the closer for a closer on its own line.
+		joined += " " + endMarker
+		canStartLine := opts.canStartLine
+		opts.canStartLine = func(prefix, word string) bool {
+			return word != endMarker && (canStartLine == nil || canStartLine(prefix, word))
+		}
+	}
+	// Doxygen's "/*!" is a doc comment like "/**".
+	docMarker := opener
+	if strings.HasPrefix(strings.TrimLeft(seg.lines[0], " \t"), startMarker+"!") {
+		docMarker = startMarker + "!"
+	}
+	wrapped := wrapItems(joined, firstPrefix, innerPrefix, lang.docTags(docMarker), opts)
 
 	// Reconstruct block comment.
 	var result []string
-	result = append(result, seg.indent+startMarker)
+	if !openerText {
+		result = append(result, seg.indent+opener)
+	}
 	result = append(result, wrapped...)
-	result = append(result, seg.indent+" "+endMarker)
+	switch {
+	case trailingCloser:
+	case bare:
+		result = append(result, seg.indent+endMarker)
+	case blockPrefix == "  * ":
+		result = append(result, seg.indent+"  "+endMarker)
+	default:
+		result = append(result, seg.indent+" "+endMarker)
+	}
 	return result
 }
 
-// wrapPlainText wraps plain text (no comment markers) preserving paragraph breaks.
-func wrapPlainText(lines []string, column, tabWidth int) string {
-	joined := strings.Join(lines, "\n")
-	wrapped := wrapText(joined, "", "", column, tabWidth)
+// Data such as minified code or base64 is often a single line with few or no spaces, which
+// wrapping would chop into many lines. A line of plain text at least dataLineLength bytes long,
+// with fewer than one space in every dataLineSpacing bytes, is taken to be data; prose has a
+// space every six bytes or so.
+const (
+	dataLineLength  = 256
+	dataLineSpacing = 20
+)
+
+// isDataLine reports whether line is data rather than prose; see dataLineLength.
+func isDataLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= dataLineLength && strings.Count(line, " ")*dataLineSpacing < len(line)
+}
+
+// wrapPlainText wraps plain text (no comment markers) preserving paragraph breaks. Lines of data
+// (see isDataLine) are left as they are unless opts.BreakLongWords is set.
+func wrapPlainText(lines []string, opts Options) string {
+	text := lines
+	if opts.Prefix != "" {
+		text = make([]string, len(lines))
+		for i, line := range lines {
+			text[i] = stripPrefix(line, opts.Prefix)
+		}
+	}
+	data := !opts.BreakLongWords && slices.ContainsFunc(text, isDataLine)
+	isData := func(i int) bool { return data && isDataLine(text[i]) }
+	if len(opts.Lines) > 0 || opts.Limits != (Limits{}) || data {
+		// Rewrap the selected paragraphs, within the limits, one by one. Lines of data are
+		// paragraphs of their own, and left as they are.
+		var out []string
+		for i := 0; i < len(lines); {
+			if strings.TrimSpace(text[i]) == "" || isData(i) {
+				out = append(out, lines[i])
+				i++
+				continue
+			}
+			start := i
+			for i < len(lines) && strings.TrimSpace(text[i]) != "" && !isData(i) {
+				i++
+			}
+			if !opts.selected(start, i) || !opts.withinLimits(lines[start:i]) {
+				out = append(out, lines[start:i]...)
+				continue
+			}
+			inner := opts
+			inner.Lines = nil
+			out = append(out, wrapItems(strings.Join(text[start:i], "\n"), opts.Prefix, opts.Prefix, nil, inner)...)
+		}
+		return strings.Join(out, "\n")
+	}
+	joined := strings.Join(text, "\n")
+	wrapped := wrapItems(joined, opts.Prefix, opts.Prefix, nil, opts)
 	result := strings.Join(wrapped, "\n")
 	// Preserve trailing newline.
 	if len(lines) > 0 && lines[len(lines)-1] == "" {
@@ -332,3 +851,15 @@ func wrapPlainText(lines []string, column, tabWidth int) string {
 	}
 	return result
 }
+
+// stripPrefix returns line without prefix. A line that is prefix without its trailing spaces, such
+// as ">" for "> ", is blank. Other lines are returned as they are.
+func stripPrefix(line, prefix string) string {
+	if rest, ok := strings.CutPrefix(line, prefix); ok {
+		return rest
+	}
+	if strings.TrimRight(line, " \t") == strings.TrimRight(prefix, " \t") {
+		return ""
+	}
+	return line
+}
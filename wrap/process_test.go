@@ -40,3 +40,306 @@ func main() {}
 	assert.GreaterOrEqual(t, commentCount, 2,
 		"expected comment to be wrapped into multiple lines, got %d comment lines\noutput:\n%s", commentCount, got)
 }
+
+func TestSource_Org(t *testing.T) {
+	org := LanguageFromName("org")
+
+	// A word that would make a wrapped line a headline, comment, keyword, list item, table row or
+	// drawer stays on the line before.
+	src := "one two * three # four #+x five - six 1. seven | eight :END: nine\n"
+	want := "one two *\nthree #\nfour #+x\nfive - six 1.\nseven |\neight :END:\nnine\n"
+	got := Source([]byte(src), org, 10, 0)
+	assert.Equal(t, want, string(got))
+	assert.Equal(t, want, string(Source(got, org, 10, 0)))
+
+	// A paragraph ends at a list item.
+	got = Source([]byte("one two three\n- four five six\n"), org, 10, 0)
+	assert.Equal(t, "one two\nthree\n- four\n  five six\n", string(got))
+}
+
+func TestSource_AsciiDoc(t *testing.T) {
+	adoc := LanguageFromName("asciidoc")
+
+	// A paragraph that mentions a description list delimiter is not a list item, and a word that
+	// would make a wrapped line a comment, block title, delimiter or list item stays on the line
+	// before.
+	src := "one two .. note:: text // three .title four ---- five * six CPU:: seven\n"
+	want := "one two .. note::\ntext //\nthree .title\nfour ----\nfive * six CPU::\nseven\n"
+	got := Source([]byte(src), adoc, 12, 0)
+	assert.Equal(t, want, string(got))
+	assert.Equal(t, want, string(Source(got, adoc, 12, 0)))
+}
+
+func TestSource_DocTagsInDocComments(t *testing.T) {
+	// Doc tags start tag paragraphs only in doc comments.
+	js := LanguageFromName("javascript")
+	src := "// Ask the owners, ping @alice or @bob before merging\n/**\n * Summary.\n * @param x the thing\n */\n"
+	want := "// Ask the owners, ping\n// @alice or @bob before\n// merging\n/**\n * Summary.\n * @param x the thing\n */\n"
+	got := Source([]byte(src), js, 27, 0)
+	assert.Equal(t, want, string(got))
+	assert.Equal(t, want, string(Source(got, js, 27, 0)))
+
+	c := LanguageFromName("c")
+	src = "/// Prints the text and a newline \\n at the end of the line when done\n"
+	want = "/// Prints the text and a newline \\n\n/// at the end of the line when\n/// done\n"
+	got = Source([]byte(src), c, 34, 0)
+	assert.Equal(t, want, string(got))
+	assert.Equal(t, want, string(Source(got, c, 34, 0)))
+
+	// XML doc tags apply in F#'s "///" comments, not in "//" ones.
+	fsharp := LanguageFromName("fsharp")
+	src = "// Retry while count\n// <b>stop</b> for good\n/// <summary>\n/// Retries.\n/// </summary>\n"
+	want = "// Retry while count <b>stop</b> for\n// good\n/// <summary>\n/// Retries.\n/// </summary>\n"
+	got = Source([]byte(src), fsharp, 40, 0)
+	assert.Equal(t, want, string(got))
+	assert.Equal(t, want, string(Source(got, fsharp, 40, 0)))
+}
+
+func TestSource_ListMarkerInProse(t *testing.T) {
+	// A word that looks like a list marker stays on the line before, so that rewrapping narrow and
+	// then wide gives back the original line.
+	python := LanguageFromName("python")
+	src := "# Compute the value of xxxx - the result is the index\n"
+	narrow := Source([]byte(src), python, 27, 0)
+	assert.Equal(t, "# Compute the value of xxxx -\n# the result is the index\n", string(narrow))
+	assert.Equal(t, src, string(Source(narrow, python, 100, 0)))
+
+	text := "Compute the value of xxxx - the result is 12. the index\n"
+	narrow = Source([]byte(text), nil, 26, 0)
+	assert.Equal(t, "Compute the value of xxxx -\nthe result is 12. the\nindex\n", string(narrow))
+	assert.Equal(t, text, string(Source(narrow, nil, 100, 0)))
+}
+
+func TestSourceWithOptions_NormalizeBullets(t *testing.T) {
+	md := LanguageFromName("markdown")
+	input := "* one\n* two\n  + nested\n\n1. ordered\n"
+	got := string(SourceWithOptions([]byte(input), md, Options{Column: 80, TabWidth: 4, Bullet: "-"}))
+	assert.Equal(t, "- one\n- two\n  - nested\n\n1. ordered\n", got)
+
+	// Every bullet in the prefix of a nested paragraph is normalized.
+	input = "> * + nested\n> * > quoted\n"
+	got = string(SourceWithOptions([]byte(input), md, Options{Column: 80, TabWidth: 4, Bullet: "-"}))
+	assert.Equal(t, "> - - nested\n> - > quoted\n", got)
+}
+
+func TestSourceWithOptions_ExpandTabs(t *testing.T) {
+	goLang := LanguageFromName("go")
+	input := "// Fields:\tname\n//\n//\tcode\tblock\nfunc main() {\n\tx := 1\n}\n"
+	got := string(SourceWithOptions([]byte(input), goLang, Options{Column: 80, TabWidth: 4, ExpandTabs: true}))
+	want := "// Fields:  name\n//\n//\tcode\tblock\nfunc main() {\n\tx := 1\n}\n"
+	assert.Equal(t, want, got)
+}
+
+func TestSourceWithOptions_KeepNarrow(t *testing.T) {
+	py := LanguageFromName("python")
+	// Wrapped at 30 on purpose: each line is full.
+	narrow := "# The quick brown fox jumps\n# over the lazy dog, then the\n# dog chases the fox around\n# the yard.\nx = 1\n"
+	got := string(SourceWithOptions([]byte(narrow), py, Options{Column: 80, KeepNarrow: true}))
+	assert.Equal(t, narrow, got)
+	got = string(SourceWithOptions([]byte(narrow), py, Options{Column: 80}))
+	assert.Equal(t, "# The quick brown fox jumps over the lazy dog, then the dog chases the fox\n# around the yard.\nx = 1\n", got)
+
+	// Ragged lines are not a deliberate width, so they are rewrapped to the column.
+	ragged := "# The quick\n# brown fox jumps over the lazy\n# dog.\n# Again.\nx = 1\n"
+	got = string(SourceWithOptions([]byte(ragged), py, Options{Column: 80, KeepNarrow: true}))
+	assert.Equal(t, "# The quick brown fox jumps over the lazy dog. Again.\nx = 1\n", got)
+
+	// Too-long lines in a narrow block are wrapped at the block's width.
+	long := "# The quick brown fox jumps\n# over the lazy dog, then the\n# dog chases the fox around\n# the yard, and the yard is very big indeed.\n"
+	got = string(SourceWithOptions([]byte(long), py, Options{Column: 80, KeepNarrow: true}))
+	assert.Equal(t, "# The quick brown fox jumps\n# over the lazy dog, then the\n# dog chases the fox around\n# the yard, and the yard is\n# very big indeed.\n", got)
+}
+
+func TestSourceWithOptions_MinLines(t *testing.T) {
+	py := LanguageFromName("python")
+	src := "#  ok\nx = 1  #  trailing\n#  two\n#  lines\n"
+	got := string(SourceWithOptions([]byte(src), py, Options{Column: 40, MinLines: 2}))
+	assert.Equal(t, "#  ok\nx = 1  #  trailing\n# two lines\n", got)
+	got = string(SourceWithOptions([]byte(src), py, Options{Column: 40}))
+	assert.Equal(t, "# ok\nx = 1  #  trailing\n# two lines\n", got)
+
+	// A lone line that is too long is still wrapped.
+	long := "# one two three four five six seven\n"
+	got = string(SourceWithOptions([]byte(long), py, Options{Column: 20, MinLines: 2}))
+	assert.Equal(t, "# one two three four\n# five six seven\n", got)
+}
+
+func TestSourceWithOptions_MixedIndent(t *testing.T) {
+	py := LanguageFromName("python")
+	src := "if x:\n\t# one two\n    # three\n  # four\n\tpass\n"
+	got := string(SourceWithOptions([]byte(src), py, Options{Column: 40, MixedIndent: true}))
+	assert.Equal(t, "if x:\n\t# one two three\n  # four\n\tpass\n", got)
+	got = string(SourceWithOptions([]byte(src), py, Options{Column: 40}))
+	assert.Equal(t, src, got)
+
+	// The width depends on the tab width.
+	got = string(SourceWithOptions([]byte(src), py, Options{Column: 40, TabWidth: 2, MixedIndent: true}))
+	assert.Equal(t, "if x:\n\t# one two\n    # three\n  # four\n\tpass\n", got)
+}
+
+func TestSourceWithOptions_Lines(t *testing.T) {
+	long := "one two three four five six seven"
+	goLang := LanguageFromName("go")
+	src := "// " + long + "\nvar a = 1\n\n// " + long + "\nvar b = 2\n"
+	got := string(SourceWithOptions([]byte(src), goLang, Options{Column: 20, Lines: []LineRange{{Start: 4, End: 5}}}))
+	assert.Equal(t, "// "+long+"\nvar a = 1\n\n// one two three\n// four five six\n// seven\nvar b = 2\n", got)
+	got = string(SourceWithOptions([]byte(src), goLang, Options{Column: 20, Lines: []LineRange{{Start: 2, End: 3}}}))
+	assert.Equal(t, src, got)
+
+	text := long + "\n\n" + long + "\n"
+	got = string(SourceWithOptions([]byte(text), nil, Options{Column: 20, Lines: []LineRange{{Start: 1, End: 1}}}))
+	assert.Equal(t, "one two three four\nfive six seven\n\n"+long+"\n", got)
+
+	md := LanguageFromName("markdown")
+	got = string(SourceWithOptions([]byte("# Title\n\n"+text), md, Options{Column: 20, Lines: []LineRange{{Start: 5, End: 5}}}))
+	assert.Equal(t, "# Title\n\n"+long+"\n\none two three four\nfive six seven\n", got)
+
+	rst := LanguageFromName("rst")
+	got = string(SourceWithOptions([]byte("Title\n===\n\n"+text), rst, Options{Column: 20, Lines: []LineRange{{Start: 6, End: 6}}}))
+	assert.Equal(t, "Title\n===\n\n"+long+"\n\none two three four\nfive six seven\n", got)
+}
+
+func TestSourceWithOptions_Prefix(t *testing.T) {
+	input := "> one two three four five six seven\n>\n> eight nine\n> ten\n"
+	got := string(SourceWithOptions([]byte(input), nil, Options{Column: 20, Prefix: "> "}))
+	assert.Equal(t, "> one two three four\n> five six seven\n>\n> eight nine ten\n", got)
+
+	// Lines without the prefix get it.
+	got = string(SourceWithOptions([]byte("-- SELECT *\n-- FROM t\nWHERE x\n"), nil, Options{Column: 40, Prefix: "-- "}))
+	assert.Equal(t, "-- SELECT * FROM t WHERE x\n", got)
+}
+
+func TestSourceWithOptions_FrontMatter(t *testing.T) {
+	md := LanguageFromName("markdown")
+	long := "one two three four five six seven"
+	yaml := "---\ntitle: " + long + "\ndescription: " + long + "\ntags: [a, b]\n---\n\n" + long + "\n"
+	got := string(SourceWithOptions([]byte(yaml), md, Options{Column: 20}))
+	assert.Equal(t, "---\ntitle: "+long+"\ndescription: "+long+"\ntags: [a, b]\n---\n\none two three four\nfive six seven\n", got)
+
+	got = string(SourceWithOptions([]byte(yaml), md, Options{Column: 20, FrontMatterFields: []string{"description", "tags"}}))
+	assert.Equal(t, "---\ntitle: "+long+"\ndescription: one two\n  three four five\n  six seven\ntags: [a, b]\n---\n\none two three four\nfive six seven\n", got)
+
+	toml := "+++\ntitle = \"" + long + "\"\n+++\n\n" + long + "\n"
+	got = string(SourceWithOptions([]byte(toml), md, Options{Column: 20}))
+	assert.Equal(t, "+++\ntitle = \""+long+"\"\n+++\n\none two three four\nfive six seven\n", got)
+
+	// Line numbers count the front matter.
+	got = string(SourceWithOptions([]byte(toml+"\n"+long+"\n"), md, Options{Column: 20, Lines: []LineRange{{Start: 7, End: 7}}}))
+	assert.Equal(t, toml+"\none two three four\nfive six seven\n", got)
+}
+
+func TestSourceWithOptions_FormatTables(t *testing.T) {
+	md := LanguageFromName("markdown")
+	src := "| Name | Description of the column, which is wider than the column | Size |\n|:-|:-:|--:|\n| a | b \\| c | 1 |\n|d|\n"
+	got := string(SourceWithOptions([]byte(src), md, Options{Column: 20}))
+	assert.Equal(t, src, got)
+
+	got = string(SourceWithOptions([]byte(src), md, Options{Column: 20, FormatTables: true}))
+	assert.Equal(t, "| Name | Description of the column, which is wider than the column | Size |\n"+
+		"| :--- | :-------------------------------------------------------: | ---: |\n"+
+		"| a    |                          b \\| c                           |    1 |\n"+
+		"| d    |                                                           |      |\n", got)
+
+	// Tables in blockquotes and lists are left as they are.
+	quoted := "> | a | b |\n> |-|-|\n> | c | d |\n"
+	got = string(SourceWithOptions([]byte(quoted), md, Options{Column: 20, FormatTables: true}))
+	assert.Equal(t, quoted, got)
+}
+
+func TestSourceWithOptions_ExtendUnderlines(t *testing.T) {
+	md := LanguageFromName("markdown")
+	src := "A Longer Title\n===\n\nTwo line\nsubtitle\n-\nText after it.\n\n# ATX\n---\n"
+	got := string(SourceWithOptions([]byte(src), md, Options{Column: 20}))
+	assert.Equal(t, src, got)
+
+	got = string(SourceWithOptions([]byte(src), md, Options{Column: 20, ExtendUnderlines: true}))
+	assert.Equal(t, "A Longer Title\n==============\n\nTwo line\nsubtitle\n--------\nText after it.\n\n# ATX\n---\n", got)
+}
+
+func TestSourceWithOptions_BreakLongWords(t *testing.T) {
+	blob := strings.Repeat("QUJD", 100)
+	minified := strings.Repeat("function(a){return a&&a.b?a:{default:a}} ", 10)
+	input := "one two\nthree\n" + blob + "\n" + minified + "\nfour five six\n"
+
+	// Lines of data are left as they are, and the prose around them is rewrapped.
+	got := string(SourceWithOptions([]byte(input), nil, Options{Column: 20}))
+	assert.Equal(t, "one two three\n"+blob+"\n"+minified+"\nfour five six\n", got)
+
+	// Unless words are broken.
+	got = string(SourceWithOptions([]byte("one two "+blob[:44]+" three\n"), nil, Options{Column: 20, BreakLongWords: true}))
+	assert.Equal(t, "one two\nQUJDQUJDQUJDQUJDQUJD\nQUJDQUJDQUJDQUJDQUJD\nQUJD three\n", got)
+
+	// Paths and URLs are not, even when words are broken.
+	path := "internal/storage/postgres/migrations/0001_init.sql"
+	got = string(SourceWithOptions([]byte("see "+path+" and https://example.com/a/long/path/to/a/page\n"), nil, Options{Column: 20, BreakLongWords: true}))
+	assert.Equal(t, "see\n"+path+"\nand\nhttps://example.com/a/long/path/to/a/page\n", got)
+	// Base64 with slashes is still broken.
+	got = string(SourceWithOptions([]byte(blob[:40]+"+/"+blob[:40]+"\n"), nil, Options{Column: 20, BreakLongWords: true}))
+	assert.Equal(t, "QUJDQUJDQUJDQUJDQUJD\nQUJDQUJDQUJDQUJDQUJD\n+/QUJDQUJDQUJDQUJDQU\nJDQUJDQUJDQUJDQUJDQU\nJD\n", got)
+
+	// Source code is never broken.
+	src := "// " + blob[:30] + "\npackage a\n"
+	assert.Equal(t, src, string(SourceWithOptions([]byte(src), LanguageFromName("go"), Options{Column: 20, BreakLongWords: true})))
+}
+
+func TestSource_LanguageColumn(t *testing.T) {
+	words := "# " + strings.Repeat("word ", 30) + "\n"
+	// Python comments are wrapped at 79 columns, as PEP 8 recommends, unless a column is set.
+	got := string(Source([]byte(words), LanguageFromName("python"), 0, 0))
+	assert.Equal(t, 76, len(strings.Split(got, "\n")[0]))
+	got = string(Source([]byte(words), LanguageFromName("python"), 100, 0))
+	assert.Equal(t, 96, len(strings.Split(got, "\n")[0]))
+	// Go has no column of its own.
+	got = string(Source([]byte(strings.ReplaceAll(words, "#", "//")), LanguageFromName("go"), 0, 0))
+	assert.Equal(t, 97, len(strings.Split(got, "\n")[0]))
+}
+
+func TestSourceWithOptions_Limits(t *testing.T) {
+	goLang := LanguageFromName("go")
+	long := "// one two three four five six\n"
+	src := long + "var a = 1\n\n" + "// " + strings.Repeat("x", 40) + " two\nvar b = 2\n"
+	want := "// one two three\n// four five six\nvar a = 1\n\n" + "// " + strings.Repeat("x", 40) + " two\nvar b = 2\n"
+	// The comment with a line over MaxLineLength is left as it is.
+	got := SourceWithOptions([]byte(src), goLang, Options{Column: 20, Limits: Limits{MaxLineLength: 40}})
+	assert.Equal(t, want, string(got))
+
+	got = SourceWithOptions([]byte(long+long), goLang, Options{Column: 20, Limits: Limits{MaxBlockLines: 1}})
+	assert.Equal(t, long+long, string(got))
+	got = SourceWithOptions([]byte(src), goLang, Options{Column: 20, Limits: Limits{MaxInputSize: len(src) - 1}})
+	assert.Equal(t, src, string(got))
+
+	// In plain text and Markdown, the limits apply to each paragraph.
+	text := "one two three four five six\n\n" + strings.Repeat("x", 40) + " two\n"
+	wantText := "one two three four\nfive six\n\n" + strings.Repeat("x", 40) + " two\n"
+	for _, lang := range []*Language{nil, LanguageFromName("markdown")} {
+		got = SourceWithOptions([]byte(text), lang, Options{Column: 20, Limits: Limits{MaxLineLength: 40}})
+		assert.Equal(t, wantText, string(got))
+	}
+	// Deeply nested Markdown is left unchanged.
+	nested := "> > > > one two three four five six\n"
+	got = SourceWithOptions([]byte(nested), LanguageFromName("markdown"), Options{Column: 20, Limits: Limits{MaxMarkdownPrefix: 6}})
+	assert.Equal(t, nested, string(got))
+	got = SourceWithOptions([]byte(nested), LanguageFromName("markdown"), Options{Column: 20, Limits: Limits{MaxMarkdownPrefix: 8}})
+	assert.Equal(t, "> > > > one two\n> > > > three four\n> > > > five six\n", string(got))
+}
+
+func FuzzSourceWithOptions(f *testing.F) {
+	for _, seed := range []string{
+		"// one two three four five six\npackage a\n",
+		"/* a\n * b c d e f g\n */\n",
+		"# Title\n\n- one two three four\n  > quoted text here\n",
+		"def f():\n    \"\"\"Doc string that is long enough.\n\n    Args:\n        x: the x.\n    \"\"\"\n",
+		"{- {- nested -} comment text -}\n",
+		"\xff\xfe // invalid \x80 utf-8\n",
+	} {
+		f.Add(seed, "go", 20)
+		f.Add(seed, "markdown", 10)
+		f.Add(seed, "python", 30)
+	}
+	f.Fuzz(func(t *testing.T, src, lang string, column int) {
+		opts := Options{Column: max(column%200, 1), Limits: DefaultLimits}
+		l := LanguageFromName(lang)
+		got := SourceWithOptions([]byte(src), l, opts)
+		assert.Equal(t, string(got), string(SourceWithOptions([]byte(src), l, opts)), "not deterministic")
+	})
+}
@@ -0,0 +1,49 @@
+package wrap
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Profile is the policy rewrap follows for a language unless told otherwise, for tools that generate
+// comments, such as doc generators and scaffolding tools, and want them formatted as rewrap would.
+type Profile struct {
+	Column   int // wrapping column
+	TabWidth int // tab display width
+
+	// Directives lists the text after a line comment marker that makes the comment a directive,
+	// which is never rewrapped, such as "go:" for "//go:generate". Continued lists those whose
+	// following line comments belong to the directive.
+	Directives []string
+	Continued  []string
+
+	// Anchored lists the line comment text that tools look for at the start of a line, such as
+	// "nolint:", which stays on its own line as it is; see Options.Anchored.
+	Anchored []string
+
+	// DocTags lists the prefixes that start a doc tag paragraph with a hanging indent, such as "@"
+	// for "@param".
+	DocTags []string
+}
+
+// DefaultProfile returns the Profile of lang, or of plain text if lang is nil. Its slices are
+// copies, which the caller may change.
+func DefaultProfile(lang *Language) Profile {
+	p := Profile{
+		Column:   cmp.Or(lang.column(), DefaultColumn),
+		TabWidth: DefaultTabWidth,
+	}
+	if lang != nil {
+		p.Directives = slices.Clone(lang.Directives)
+		p.Continued = slices.Clone(lang.Continued)
+		p.Anchored = slices.Clone(lang.Anchored)
+		p.DocTags = slices.Clone(lang.DocTags)
+	}
+	return p
+}
+
+// Options returns the Options that rewrap the way p describes, for the language p is for. The
+// language's own Directives, Anchored and DocTags apply without being set in Options.
+func (p Profile) Options() Options {
+	return Options{Column: p.Column, TabWidth: p.TabWidth}
+}
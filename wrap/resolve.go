@@ -0,0 +1,130 @@
+package wrap
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// ResolveOptions returns opts with the column and tab width that apply to the file filename, with
+// contents src, in lang (nil for plain text). It is what the rewrap command and its language server
+// use, so that every frontend agrees. Each setting is taken from the first of these that sets it:
+//
+//  1. opts itself, such as from command-line flags
+//  2. a Vim or Emacs modeline in src, such as "vim: set tw=80:" or "-*- fill-column: 72 -*-"
+//  3. the [[paths]] entry for filename in cfg (see Config.PathRule)
+//  4. the [columns] entry for lang in cfg (the column only)
+//  5. cfg's column and tab-width (and min-lines)
+//  6. the .editorconfig files for filename (see EditorConfigForFile)
+//  7. lang's Column (the column only)
+//  8. DefaultColumn and DefaultTabWidth
+//
+// A [[paths]] entry can also turn on PreserveSentenceStarts. cfg may be nil, such as when there is
+// no config file. The result always has Column and TabWidth set.
+func ResolveOptions(filename string, src []byte, lang *Language, cfg *Config, opts Options) (Options, error) {
+	ec, err := EditorConfigForFile(filename)
+	if err != nil {
+		return opts, err
+	}
+	column, tabWidth := modeline(src)
+	opts.Column = cmp.Or(opts.Column, column)
+	opts.TabWidth = cmp.Or(opts.TabWidth, tabWidth)
+	if rule := cfg.PathRule(filename); rule != nil {
+		opts.Column = cmp.Or(opts.Column, rule.Column)
+		opts.TabWidth = cmp.Or(opts.TabWidth, rule.TabWidth)
+		opts.PreserveSentenceStarts = opts.PreserveSentenceStarts || rule.PreserveSentenceStarts
+	}
+	opts = ec.Apply(cfg.ApplyLanguage(opts, lang))
+	opts.Column = cmp.Or(opts.Column, lang.column(), DefaultColumn)
+	opts.TabWidth = cmp.Or(opts.TabWidth, DefaultTabWidth)
+	return opts, nil
+}
+
+// modelineLines is the number of lines at the start and at the end of a file that are searched for
+// modelines, as in Vim.
+const modelineLines = 5
+
+// modeline returns the column and tab width set by Vim modelines ("vim: set tw=80 ts=4:") and
+// Emacs file variables ("-*- fill-column: 80; tab-width: 4 -*-", or a "Local Variables:" list)
+// near the start or end of src, or 0 for those that are not set.
+func modeline(src []byte) (column, tabWidth int) {
+	lines := strings.Split(string(src), "\n")
+	if len(lines) > 2*modelineLines {
+		lines = append(lines[:modelineLines:modelineLines], lines[len(lines)-modelineLines:]...)
+	}
+	set := func(key, value string) {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n <= 0 {
+			return
+		}
+		switch key {
+		case "tw", "textwidth", "fill-column":
+			column = n
+		case "ts", "tabstop", "tab-width":
+			tabWidth = n
+		}
+	}
+	localVars := "" // prefix of the lines of an Emacs "Local Variables:" list we are in
+	inLocalVars := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if inLocalVars {
+			rest, ok := strings.CutPrefix(line, localVars)
+			if !ok || strings.TrimSpace(rest) == "End:" {
+				inLocalVars = false
+				continue
+			}
+			key, value, _ := strings.Cut(rest, ":")
+			set(strings.TrimSpace(key), value)
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(line, "Local Variables:"); ok {
+			localVars, inLocalVars = prefix, true
+			continue
+		}
+		if _, vars, ok := strings.Cut(line, "-*-"); ok {
+			if vars, _, ok := strings.Cut(vars, "-*-"); ok {
+				for v := range strings.SplitSeq(vars, ";") {
+					key, value, _ := strings.Cut(v, ":")
+					set(strings.TrimSpace(key), value)
+				}
+				continue
+			}
+		}
+		for _, v := range vimOptions(line) {
+			key, value, _ := strings.Cut(v, "=")
+			set(key, value)
+		}
+	}
+	return column, tabWidth
+}
+
+// vimOptions returns the options of a Vim modeline in line, such as ["tw=80", "ts=4"] for
+// "// vim: set tw=80 ts=4:" or "# vi:tw=80:ts=4", or nil if there is none.
+func vimOptions(line string) []string {
+	for _, marker := range []string{"vim:", "vi:", "ex:"} {
+		i := strings.Index(line, marker)
+		// The marker must start the line or follow white space.
+		if i < 0 || (i > 0 && line[i-1] != ' ' && line[i-1] != '\t') {
+			continue
+		}
+		rest := strings.TrimLeft(line[i+len(marker):], " \t")
+		if r, ok := cutSet(rest); ok {
+			// In the "set" form, the options end at the next colon.
+			r, _, _ = strings.Cut(r, ":")
+			return strings.Fields(r)
+		}
+		return strings.FieldsFunc(rest, func(r rune) bool { return r == ':' || r == ' ' || r == '\t' })
+	}
+	return nil
+}
+
+// cutSet returns s without a leading "set " or "se ", and whether it had one.
+func cutSet(s string) (string, bool) {
+	for _, p := range []string{"set ", "se "} {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			return rest, true
+		}
+	}
+	return s, false
+}
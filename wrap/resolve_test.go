@@ -0,0 +1,72 @@
+package wrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModeline(t *testing.T) {
+	tests := []struct {
+		src      string
+		column   int
+		tabWidth int
+	}{
+		{"package main\n", 0, 0},
+		{"// vim: set tw=72 ts=8:\npackage main\n", 72, 8},
+		{"# vi:tw=60:ts=2\n", 60, 2},
+		{"/* vim: set textwidth=90 tabstop=3: */\n", 90, 3},
+		{";; -*- mode: lisp; fill-column: 70 -*-\n", 70, 0},
+		{"x\n# Local Variables:\n# tab-width: 4\n# fill-column: 66\n# End:\n", 66, 4},
+		{"// The regex: tw=72 is not a modeline.\n", 0, 0},
+		{"// vim: set tw=0:\n", 0, 0},
+	}
+	for _, tt := range tests {
+		column, tabWidth := modeline([]byte(tt.src))
+		assert.Equal(t, tt.column, column, "column for %q", tt.src)
+		assert.Equal(t, tt.tabWidth, tabWidth, "tab width for %q", tt.src)
+	}
+
+	// Only the first and last lines are searched, as in Vim.
+	src := "// vim: set tw=50:\n" + "x\n" + "x\nx\nx\nx\nx\nx\nx\nx\nx\nx\n"
+	column, _ := modeline([]byte(src))
+	assert.Equal(t, 50, column)
+	column, _ = modeline([]byte("x\nx\nx\nx\nx\nx\n// vim: set tw=50:\nx\nx\nx\nx\nx\nx\n"))
+	assert.Equal(t, 0, column)
+}
+
+func TestResolveOptions(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte("root = true\n[*]\nmax_line_length = 90\ntab_width = 2\n"), 0o644))
+	file := filepath.Join(dir, "main.py")
+	python := LanguageFromName("python")
+
+	opts, err := ResolveOptions(file, []byte("x = 1\n"), python, nil, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 90, opts.Column)
+	assert.Equal(t, 2, opts.TabWidth)
+
+	cfg := &Config{Column: 100, Columns: map[string]int{"python": 88}}
+	opts, err = ResolveOptions(file, []byte("x = 1\n"), python, cfg, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 88, opts.Column)
+
+	// A modeline takes precedence over the config, and flags over both.
+	src := []byte("# vim: set tw=72:\nx = 1\n")
+	opts, err = ResolveOptions(file, src, python, cfg, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 72, opts.Column)
+	opts, err = ResolveOptions(file, src, python, cfg, Options{Column: 60})
+	require.NoError(t, err)
+	assert.Equal(t, 60, opts.Column)
+
+	// Without any settings, the language's own column and the default tab width apply.
+	other := filepath.Join(t.TempDir(), "main.py")
+	opts, err = ResolveOptions(other, nil, python, nil, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 79, opts.Column)
+	assert.Equal(t, DefaultTabWidth, opts.TabWidth)
+}
@@ -0,0 +1,196 @@
+package wrap
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// rstAdornmentChars are the characters that section title adornments and transitions are made of.
+const rstAdornmentChars = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// rstFieldPattern matches the start of a field list item, such as ":param name: text".
+var rstFieldPattern = regexp.MustCompile(`^:[^\s:][^:]*:(\s|$)`)
+
+// rstSimpleTableBorder matches the border of a simple table with two or more columns.
+var rstSimpleTableBorder = regexp.MustCompile(`^=+( +=+)+$`)
+
+// rstAdmonitions are the directives whose content is prose, and rewrapped. The content of every
+// other directive is kept as it is.
+var rstAdmonitions = []string{
+	"admonition", "attention", "caution", "danger", "error", "hint", "important", "note", "tip",
+	"warning", "seealso", "deprecated", "versionadded", "versionchanged",
+}
+
+// processRST rewraps the paragraphs of a reStructuredText document. Section titles are kept, with
+// underlines and overlines lengthened to the width of the title, and so are transitions, tables,
+// line blocks, doctest blocks, directives, comments and literal blocks (the indented text after a
+// paragraph ending in "::"). The content of admonitions such as ".. note::" is rewrapped, as are
+// list items and field list items, with a hanging indent.
+func processRST(lines []string, opts Options) []string {
+	var out []string
+	literal := -1 // paragraphs indented deeper than this are kept as they are
+	for i := 0; i < len(lines); {
+		if strings.TrimSpace(lines[i]) == "" {
+			out = append(out, lines[i])
+			i++
+			continue
+		}
+		start := i
+		i = rstParagraphEnd(lines, i)
+		para := lines[start:i]
+		indent := para[0][:leadingWidth(para[0])]
+		width := displayWidth(indent, opts.TabWidth)
+		trimmed := strings.TrimSpace(para[0])
+		if literal >= 0 && width > literal {
+			out = append(out, para...)
+			continue
+		}
+		literal = -1
+		switch {
+		case !opts.selected(start, i) || !opts.withinLimits(para):
+			out = append(out, para...)
+		case isRSTTitle(para):
+			out = append(out, rstTitle(para, opts.TabWidth)...)
+		case isRSTAdmonition(trimmed) && !slices.ContainsFunc(para[1:], isRSTOption):
+			// The text of an admonition may start on the line of the directive.
+			hang := indent + "   "
+			if len(para) > 1 {
+				hang = para[1][:leadingWidth(para[1])]
+			}
+			out = append(out, wrapParagraph(strings.Join(para, "\n"), indent, hang, opts, true)...)
+		case strings.HasPrefix(trimmed, ".. ") || trimmed == "..":
+			out = append(out, para...)
+			if !isRSTAdmonition(trimmed) {
+				literal = width
+			}
+		case isRSTVerbatim(trimmed):
+			out = append(out, para...)
+		case rstFieldPattern.MatchString(trimmed):
+			out = append(out, rstFields(para, indent, opts)...)
+		case listMarker(trimmed) != "":
+			text := make([]string, len(para))
+			for j, line := range para {
+				text[j], _ = strings.CutPrefix(line, indent)
+			}
+			out = append(out, wrapItems(strings.Join(text, "\n"), indent, indent, nil, opts)...)
+		case len(para) > 1 && leadingWidth(para[1]) > len(indent):
+			// A definition list item: the term, then its definition, indented.
+			if slices.ContainsFunc(para[1:], func(line string) bool { return leadingWidth(line) <= len(indent) }) {
+				out = append(out, para...)
+				break
+			}
+			out = append(out, para[0])
+			inner := opts
+			inner.Lines = nil
+			out = append(out, processRST(para[1:], inner)...)
+		default:
+			out = append(out, wrapText(strings.Join(para, "\n"), indent, indent, opts)...)
+		}
+		if strings.HasSuffix(strings.TrimSpace(para[len(para)-1]), "::") {
+			literal = width
+		}
+	}
+	return out
+}
+
+// rstParagraphEnd returns the index of the line after the paragraph starting at lines[i]: the
+// next blank line, or for a simple table, the line after its bottom border.
+func rstParagraphEnd(lines []string, i int) int {
+	if rstSimpleTableBorder.MatchString(strings.TrimSpace(lines[i])) {
+		border := strings.TrimSpace(lines[i])
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == border && (j+1 == len(lines) || strings.TrimSpace(lines[j+1]) == "") {
+				return j + 1
+			}
+		}
+	}
+	for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+		i++
+	}
+	return i
+}
+
+// isRSTAdornment reports whether s is a line of one repeated punctuation character, as used to
+// underline and overline section titles and for transitions.
+func isRSTAdornment(s string) bool {
+	s = strings.TrimRight(s, " \t")
+	if len(s) < 2 || !strings.ContainsRune(rstAdornmentChars, rune(s[0])) {
+		return false
+	}
+	return strings.Count(s, s[:1]) == len(s)
+}
+
+// isRSTTitle reports whether para is a section title: a line of text with an underline, and
+// optionally an overline of the same character. The adornments may be too short for the title,
+// as they are after the title is edited; rstTitle lengthens them.
+func isRSTTitle(para []string) bool {
+	switch {
+	case len(para) == 2:
+		return !isRSTAdornment(para[0]) && isRSTAdornment(para[1])
+	case len(para) == 3:
+		return isRSTAdornment(para[0]) && isRSTAdornment(para[2]) && para[0][0] == para[2][0] && !isRSTAdornment(para[1])
+	}
+	return false
+}
+
+// rstTitle returns the section title para with its adornments lengthened, if they are shorter than
+// the title.
+func rstTitle(para []string, tabWidth int) []string {
+	out := slices.Clone(para)
+	width := displayWidth(strings.TrimRight(para[len(para)-2], " \t"), tabWidth)
+	for _, i := range []int{0, len(para) - 1} {
+		if i == len(para)-2 {
+			continue // no overline
+		}
+		adornment := strings.TrimRight(para[i], " \t")
+		if len(adornment) < width {
+			out[i] = strings.Repeat(adornment[:1], width)
+		}
+	}
+	if len(para) == 3 && out[0] != out[2] {
+		// An overline and underline must be the same length.
+		n := max(len(out[0]), len(out[2]))
+		out[0] = strings.Repeat(out[0][:1], n)
+		out[2] = out[0]
+	}
+	return out
+}
+
+// isRSTAdmonition reports whether the explicit markup line s starts an admonition.
+func isRSTAdmonition(s string) bool {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(s, ".. "), "::")
+	if !ok {
+		name, _, ok = strings.Cut(strings.TrimPrefix(s, ".. "), ":: ")
+	}
+	return ok && slices.Contains(rstAdmonitions, strings.TrimSpace(name))
+}
+
+// isRSTOption reports whether line is a directive option, such as ":class: tip".
+func isRSTOption(line string) bool {
+	return rstFieldPattern.MatchString(strings.TrimSpace(line))
+}
+
+// isRSTVerbatim reports whether a paragraph starting with the line s is kept as it is: a table, a
+// line block, a doctest block or a transition.
+func isRSTVerbatim(s string) bool {
+	return strings.HasPrefix(s, "+-") || strings.HasPrefix(s, "+=") || strings.HasPrefix(s, "| ") || s == "|" ||
+		strings.HasPrefix(s, ">>>") || rstSimpleTableBorder.MatchString(s) || isRSTAdornment(s)
+}
+
+// rstFields rewraps a field list paragraph. Each field starts on its own line, at indent, and its
+// continuation lines keep their indent, or are indented by defaultTagHang.
+func rstFields(para []string, indent string, opts Options) []string {
+	var out []string
+	for i := 0; i < len(para); {
+		start := i
+		for i++; i < len(para) && !rstFieldPattern.MatchString(strings.TrimSpace(para[i])); i++ {
+		}
+		hang := indent + defaultTagHang
+		if i > start+1 && leadingWidth(para[start+1]) > len(indent) {
+			hang = para[start+1][:leadingWidth(para[start+1])]
+		}
+		out = append(out, wrapParagraph(strings.Join(para[start:i], "\n"), indent, hang, opts, true)...)
+	}
+	return out
+}
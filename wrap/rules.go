@@ -0,0 +1,107 @@
+package wrap
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Rules turns on and off the heuristics that leave comment text as it is, and tunes them. Each rule
+// is named by its Skip constant: SkipAnchored, SkipDecoration, SkipTable, SkipCode and SkipLicense.
+// The first two are on by default; the others are off unless turned on, so that a new rule never
+// changes what rewrap does to files it already formats. The zero value is the default.
+type Rules struct {
+	On  []string // rules turned on
+	Off []string // rules turned off; a rule in both is off
+
+	DecorationMinLength int // the fewest characters of a decoration line; 0 means 1
+	TableMinColumns     int // the fewest cells, separated by "|", of a table row; 0 means 2
+}
+
+// ruleNames are the names of the rules, with those on by default first.
+var ruleNames = []string{SkipAnchored, SkipDecoration, SkipTable, SkipCode, SkipLicense}
+
+// defaultRules is the number of ruleNames that are on by default.
+const defaultRules = 2
+
+// CheckRule returns an error if name is not the name of a rule.
+func CheckRule(name string) error {
+	if !slices.Contains(ruleNames, name) {
+		return fmt.Errorf("unknown rule %q (want one of %s)", name, strings.Join(ruleNames, ", "))
+	}
+	return nil
+}
+
+// on reports whether the rule name is on.
+func (r Rules) on(name string) bool {
+	if slices.Contains(r.Off, name) {
+		return false
+	}
+	return slices.Contains(r.On, name) || slices.Index(ruleNames, name) < defaultRules
+}
+
+// protectedLine returns the rule that keeps the line comment with text content (after the marker)
+// as it is, or "" if there is none.
+func protectedLine(content string, lang *Language, opts Options) string {
+	r := opts.Rules
+	trimmed := strings.TrimSpace(content)
+	switch {
+	case r.on(SkipAnchored) && isAnchored(content, lang, opts):
+		return SkipAnchored
+	case r.on(SkipDecoration) && isDecorationLine(content) && len(trimmed) >= r.DecorationMinLength:
+		return SkipDecoration
+	case r.on(SkipTable) && isTableRow(trimmed, max(r.TableMinColumns, 2)):
+		return SkipTable
+	case r.on(SkipCode) && looksLikeCode(trimmed):
+		return SkipCode
+	}
+	return ""
+}
+
+// tableSeparator matches the row under the header of a Markdown table, such as "|---|:--:|".
+var tableSeparator = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)+\|?$`)
+
+// isTableRow reports whether trimmed is a row of a table with at least minColumns cells, such as
+// "| a | b |", or the separator under a Markdown table's header.
+func isTableRow(trimmed string, minColumns int) bool {
+	if tableSeparator.MatchString(trimmed) {
+		return true
+	}
+	if !strings.HasPrefix(trimmed, "|") || !strings.HasSuffix(trimmed, "|") || len(trimmed) < 2 {
+		return false
+	}
+	return strings.Count(trimmed[1:len(trimmed)-1], "|")+1 >= minColumns
+}
+
+// codeLine matches comment text that looks like a line of code: a statement ending in ";", a line
+// opening or closing a block, an assignment, or a call. A plain "=" is only an assignment when one
+// word follows it, since prose such as "a = b means" uses it too.
+var codeLine = regexp.MustCompile(`(;|\{|^[})\]]+[;,]?)$|^[\w.\[\]]+\s*(:=|[-+*/]=)\s*\S|^[\w.\[\]]+\s*=\s*\S+$|^[\w.]+\(.*\)$`)
+
+// looksLikeCode reports whether trimmed, the text of a comment line, looks like commented-out code.
+func looksLikeCode(trimmed string) bool {
+	return codeLine.MatchString(trimmed)
+}
+
+// licenseWords are the words, in lower case, that make the first comment of a file a license
+// header.
+var licenseWords = []string{"copyright", "license", "spdx-license-identifier"}
+
+// isLicenseHeader reports whether seg, the first comment of a file, is a license header.
+func isLicenseHeader(seg segment) bool {
+	text := strings.ToLower(strings.Join(seg.lines, "\n"))
+	return slices.ContainsFunc(licenseWords, func(w string) bool { return strings.Contains(text, w) })
+}
+
+// startsFile reports whether the code lines before the first comment of a file leave it at the top
+// of the file: they are blank, or a "#!" line, or a PHP "<?php" tag.
+func startsFile(code []string) bool {
+	for _, line := range code {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#!") && !strings.HasPrefix(line, "<?") {
+			return false
+		}
+	}
+	return true
+}
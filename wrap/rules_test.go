@@ -0,0 +1,83 @@
+package wrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRules(t *testing.T) {
+	goLang := LanguageFromName("go")
+	src := `// Copyright 2026 The Authors. Use of this source code is governed by a license.
+
+package a
+
+// Modes:
+// | name | meaning |
+// |------|---------|
+// | a    | the first mode, which is the default |
+// Old code:
+// x := f(y)
+// ---
+// The end of the comment.
+`
+	rewrap := func(rules Rules) string {
+		return string(SourceWithOptions([]byte(src), goLang, Options{Column: 40, Rules: rules}))
+	}
+
+	// By default, only the decoration line is kept.
+	assert.Equal(t, `// Copyright 2026 The Authors. Use of
+// this source code is governed by a
+// license.
+
+package a
+
+// Modes: | name | meaning |
+// |------|---------| | a    | the first
+// mode, which is the default | Old
+// code: x := f(y)
+// ---
+// The end of the comment.
+`, rewrap(Rules{}))
+
+	assert.Equal(t, src, rewrap(Rules{On: []string{SkipTable, SkipCode, SkipLicense}}))
+
+	// A table needs at least TableMinColumns cells.
+	assert.Equal(t, `// Copyright 2026 The Authors. Use of
+// this source code is governed by a
+// license.
+
+package a
+
+// Modes: | name | meaning |
+// |------|---------|
+// | a    | the first mode, which is the
+// default | Old code: x := f(y)
+// ---
+// The end of the comment.
+`, rewrap(Rules{On: []string{SkipTable}, TableMinColumns: 3}))
+
+	// Decoration lines can be joined like any other.
+	assert.Equal(t, `// Copyright 2026 The Authors. Use of
+// this source code is governed by a
+// license.
+
+package a
+
+// Modes: | name | meaning |
+// |------|---------| | a    | the first
+// mode, which is the default | Old
+// code: x := f(y) --- The end of the
+// comment.
+`, rewrap(Rules{Off: []string{SkipDecoration}}))
+	assert.Equal(t, rewrap(Rules{Off: []string{SkipDecoration}}), rewrap(Rules{DecorationMinLength: 4}))
+}
+
+func TestLooksLikeCode(t *testing.T) {
+	for _, s := range []string{"x := f(y)", "return nil;", "if err != nil {", "}", "});", "fmt.Println(x)", "n += 2"} {
+		assert.True(t, looksLikeCode(s), s)
+	}
+	for _, s := range []string{"Returns the value.", "See f(x) for details.", "a = b is an assignment"} {
+		assert.False(t, looksLikeCode(s), s)
+	}
+}
@@ -0,0 +1,196 @@
+package wrap
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Heredoc start patterns for Language.Heredoc. Group 1 is the "-" or "~" flag that allows an
+// indented terminator, and group 2 is the terminator. Ruby requires an upper-case terminator so that
+// "list <<item" is not mistaken for a heredoc.
+var (
+	shellHeredoc = regexp.MustCompile(`^<<(-?)[ \t]*['"\\]?([A-Za-z_][A-Za-z0-9_]*)`)
+	rubyHeredoc  = regexp.MustCompile("^<<([-~]?)['\"`]?([A-Z_][A-Za-z0-9_]*)")
+)
+
+// injectionWord matches the body of a block comment that names the language of the string after it,
+// as in "/* sql */", and injectionHint matches a line that may hold an injection annotation; see
+// stringMask.
+var (
+	injectionWord = regexp.MustCompile(`^\s*[A-Za-z][\w-]*\s*$`)
+	injectionHint = regexp.MustCompile("language=|\\*/\\s*[\"'`]")
+)
+
+// heredoc is a heredoc whose body starts on a following line.
+type heredoc struct {
+	term     string // terminator line
+	indented bool   // the terminator may be indented
+}
+
+// stringMask reports, for each line, whether the line starts inside a multi-line string literal
+// (see Language.Strings and Language.RawStrings) or is part of a heredoc body (see Language.Heredoc). Such lines are data,
+// not comments, even if they look like one, as in a SQL query or a shell script embedded in a
+// triple-quoted string.
+//
+// The scan is deliberately simple: it skips line comments, block comments and single-line '...'
+// and "..." strings so that a delimiter inside them is not mistaken for the start of a string, and
+// treats a backslash as escaping the next character, except in raw strings.
+//
+// A string with an injection annotation, which editors use to highlight it as another language, is
+// data too, even if its quotes do not start a multi-line string in lang, as with a Java text block.
+// The annotation is a block comment naming the language just before the string, as in
+// `/* sql */ "...`, or a "language=SQL" line comment on a line above it.
+func stringMask(lines []string, lang *Language) []bool {
+	if lang != nil && (lang.Name == "yaml" || lang.Name == "openapi") {
+		return yamlMask(lines)
+	}
+	if lang == nil || (len(lang.Strings) == 0 && len(lang.RawStrings) == 0 && lang.Heredoc == nil &&
+		!slices.ContainsFunc(lines, injectionHint.MatchString)) {
+		return nil
+	}
+	mask := make([]bool, len(lines))
+	open := ""         // closing delimiter of the multi-line string we are in, if any
+	raw := false       // the string we are in has no escapes
+	blockEnd := ""     // end marker of the block comment we are in, if any
+	injected := false  // an injection annotation applies to the next string
+	var docs []heredoc // heredocs whose bodies follow, in order
+	for n, line := range lines {
+		if len(docs) > 0 {
+			mask[n] = true
+			term := line
+			if docs[0].indented {
+				term = strings.TrimLeft(line, " \t")
+			}
+			if term == docs[0].term {
+				docs = docs[1:]
+			}
+			continue
+		}
+		mask[n] = open != ""
+		i := 0
+		for i < len(line) {
+			rest := line[i:]
+			switch {
+			case open != "":
+				if rest[0] == '\\' && !raw {
+					i += 2
+				} else if strings.HasPrefix(rest, open) {
+					i += len(open)
+					open = ""
+				} else {
+					i++
+				}
+			case blockEnd != "":
+				if strings.HasPrefix(rest, blockEnd) {
+					i += len(blockEnd)
+					blockEnd = ""
+				} else {
+					i++
+				}
+			case hasAnyPrefix(rest, lang.LineMarkers) && !hasAnyPrefix(rest, lang.BlockStart): // Lua's "--[["
+				if isInjection(strings.TrimPrefix(rest, longestPrefix(rest, lang.LineMarkers))) {
+					injected = true
+				}
+				i = len(line)
+			default:
+				if lang.Heredoc != nil && strings.HasPrefix(rest, "<<") {
+					if strings.HasPrefix(rest, "<<<") { // here-string
+						i += 3
+						continue
+					}
+					if m := lang.Heredoc.FindStringSubmatch(rest); m != nil {
+						docs = append(docs, heredoc{term: m[2], indented: m[1] != ""})
+						i += len(m[0])
+						continue
+					}
+				}
+				if d := longestPrefix(rest, lang.Strings); d != "" {
+					open, raw, injected = d, false, false
+					i += len(d)
+					continue
+				}
+				if d := longestPrefix(rest, lang.RawStrings); d != "" {
+					open, raw, injected = d, true, false
+					if j := slices.Index(lang.RawStrings, d); j < len(lang.RawEnd) {
+						open = lang.RawEnd[j]
+					}
+					i += len(d)
+					continue
+				}
+				if j := prefixIndex(rest, lang.BlockStart); j >= 0 {
+					blockEnd = lang.BlockEnd[min(j, len(lang.BlockEnd)-1)]
+					body, after, ok := strings.Cut(rest[len(lang.BlockStart[j]):], blockEnd)
+					after = strings.TrimLeft(after, " \t")
+					if ok && injectionWord.MatchString(body) && after != "" && strings.ContainsRune("\"'`", rune(after[0])) {
+						injected = true
+					}
+					i += len(lang.BlockStart[j])
+					continue
+				}
+				if injected && strings.ContainsRune("\"'`", rune(rest[0])) {
+					// An annotated string: it ends at the same quote, on this line or a later one.
+					open, raw, injected = rest[:1], false, false
+					if d := longestPrefix(rest, []string{`"""`, `'''`}); d != "" {
+						open = d
+					}
+					i += len(open)
+					continue
+				}
+				if rest[0] == '"' || rest[0] == '\'' {
+					i = skipQuoted(line, i)
+					continue
+				}
+				i++
+			}
+		}
+	}
+	return mask
+}
+
+// isInjection reports whether the line comment text content is an injection annotation, such as
+// "language=SQL", which gives the language of the string that follows.
+func isInjection(content string) bool {
+	return strings.HasPrefix(strings.TrimSpace(content), "language=")
+}
+
+// skipQuoted returns the index after the single-line string literal that starts at line[i], or
+// len(line) if it is not closed on this line.
+func skipQuoted(line string, i int) int {
+	quote := line[i]
+	for j := i + 1; j < len(line); j++ {
+		switch line[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(line)
+}
+
+// hasAnyPrefix reports whether s starts with any of prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	return prefixIndex(s, prefixes) >= 0
+}
+
+// prefixIndex returns the index of the first of prefixes that s starts with, or -1.
+func prefixIndex(s string, prefixes []string) int {
+	for i, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// longestPrefix returns the longest of prefixes that s starts with, or "".
+func longestPrefix(s string, prefixes []string) string {
+	longest := ""
+	for _, p := range prefixes {
+		if len(p) > len(longest) && strings.HasPrefix(s, p) {
+			longest = p
+		}
+	}
+	return longest
+}
@@ -0,0 +1,142 @@
+package wrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringMask(t *testing.T) {
+	python := LanguageFromName("python")
+	src := strings.Join([]string{
+		`x = """`,             // 0: opens a string
+		`# data`,              // 1: inside
+		`"""  # closed`,       // 2: inside at the start of the line
+		`y = "'''"  # quoted`, // 3: delimiter inside a single-line string
+		`# don't '''`,         // 4: delimiter inside a comment
+		`z = b'''a`,           // 5: opens a string
+		`b \''' c`,            // 6: inside; escaped quote does not close it
+		`'''`,                 // 7: inside, then closed
+		`# comment`,           // 8
+	}, "\n")
+	got := stringMask(strings.Split(src, "\n"), python)
+	want := []bool{false, true, true, false, false, false, true, true, false}
+	assert.Equal(t, want, got)
+	assert.Nil(t, stringMask([]string{"// x"}, LanguageFromName("c")))
+}
+
+func TestStringMask_Heredoc(t *testing.T) {
+	shell := LanguageFromName("shell")
+	src := strings.Join([]string{
+		`cat <<EOF; cat <<-"END"`, // 0: two heredocs
+		`# first`,                 // 1
+		`EOF`,                     // 2: ends the first
+		`# second`,                // 3
+		"\tEND",                   // 4: indented terminator ends the second
+		`x=$((1 << 2)) # shift`,   // 5: not a heredoc
+		`# comment`,               // 6
+	}, "\n")
+	got := stringMask(strings.Split(src, "\n"), shell)
+	assert.Equal(t, []bool{false, true, true, true, true, false, false}, got)
+
+	ruby := LanguageFromName("ruby")
+	got = stringMask([]string{"list <<item", "# comment", "x = <<~SQL", "  # data", "  SQL"}, ruby)
+	assert.Equal(t, []bool{false, false, false, true, true}, got)
+}
+
+func TestStringMask_RawStrings(t *testing.T) {
+	goLang := LanguageFromName("go")
+	src := strings.Join([]string{
+		"const usage = `Usage:", // 0: opens a raw string
+		`  // not a comment \`,  // 1: backslash does not escape in raw strings
+		"`",                     // 2: closes it
+		"r := '`' // rune",      // 3: backtick rune literal
+		"/* ` */ // comment",    // 4: backtick in a block comment
+		"// comment",            // 5
+	}, "\n")
+	got := stringMask(strings.Split(src, "\n"), goLang)
+	assert.Equal(t, []bool{false, true, true, false, false, false}, got)
+
+	js := LanguageFromName("javascript")
+	got = stringMask([]string{"const s = `a \\`", "// b`;", "// c"}, js)
+	assert.Equal(t, []bool{false, true, false}, got)
+}
+
+func TestStringMask_LongStrings(t *testing.T) {
+	lua := LanguageFromName("lua")
+	src := strings.Join([]string{
+		"local s = [==[", // 0: opens a long string
+		"-- data ]]",     // 1: "]]" does not close a level-two string
+		"]==]",           // 2: closes it
+		"--[[ a [[ ]]",   // 3: a block comment, not a string
+		"-- comment",     // 4
+	}, "\n")
+	got := stringMask(strings.Split(src, "\n"), lua)
+	assert.Equal(t, []bool{false, true, true, false, false}, got)
+}
+
+func TestStringMask_YAML(t *testing.T) {
+	yaml := LanguageFromName("yaml")
+	src := strings.Join([]string{
+		"script: |",       // 0: block scalar header
+		"  # content",     // 1
+		"",                // 2: blank lines are part of the block
+		"  more",          // 3
+		"# comment",       // 4: ends the block
+		`key: "a # b`,     // 5: opens a multi-line quoted scalar
+		`  # c \" d"`,     // 6: an escaped quote does not close it
+		"- 'it''s' # x",   // 7: "''" is a quote in single-quoted scalars
+		"# comment",       // 8
+		"url: http://a|b", // 9: "|" inside a plain scalar is not a header
+		"  # comment",     // 10
+		"- >-",            // 11: block scalar in a sequence
+		"  # folded",      // 12
+	}, "\n")
+	got := stringMask(strings.Split(src, "\n"), yaml)
+	assert.Equal(t, []bool{false, true, true, true, false, false, true, false, false, false, false, false, true}, got)
+}
+
+func TestStringMask_TOML(t *testing.T) {
+	toml := LanguageFromName("toml")
+	src := strings.Join([]string{
+		`a = """`,       // 0: opens a basic string
+		`# data \"""`,   // 1: an escaped delimiter does not close it
+		`"""`,           // 2: closes it
+		`b = '''C:\'''`, // 3: literal strings have no escapes
+		`c = "'''" # x`, // 4: delimiter inside a single-line string
+		`# comment`,     // 5
+	}, "\n")
+	got := stringMask(strings.Split(src, "\n"), toml)
+	assert.Equal(t, []bool{false, true, true, false, false, false}, got)
+}
+
+func TestStringMask_Injection(t *testing.T) {
+	java := LanguageFromName("java")
+	src := strings.Join([]string{
+		`String q = /* sql */ """`, // 0: annotated text block
+		`  -- not a comment`,       // 1: inside
+		`  """;`,                   // 2: inside, then closed
+		`// language=SQL`,          // 3: annotates the next string
+		`String r = "SELECT 1 \`,   // 4: opens a string continued on the next line
+		`// not a comment";`,       // 5: inside
+		`String s = /* a */ x;`,    // 6: not an annotation of a string
+		`// comment`,               // 7
+	}, "\n")
+	got := stringMask(strings.Split(src, "\n"), java)
+	assert.Equal(t, []bool{false, true, true, false, false, true, false, false}, got)
+	assert.Nil(t, stringMask([]string{"/* a */ x", "// b"}, java))
+}
+
+func TestSource_Injection(t *testing.T) {
+	src := strings.Join([]string{
+		`// Users lists the users.`,
+		`// language=SQL`,
+		`String users = """`,
+		`    // This line is in the query and not a comment, even though it is long enough to wrap.`,
+		`    SELECT * FROM users""";`,
+		``,
+	}, "\n")
+	got := Source([]byte(src), LanguageFromName("java"), 40, 4)
+	assert.Equal(t, src, string(got))
+}
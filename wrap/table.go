@@ -0,0 +1,108 @@
+package wrap
+
+import (
+	"strings"
+
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// tableStart returns the line, counted from 0, of the header row of a Markdown table, found from
+// the first of its cells that is not empty, or -1 if every cell is empty.
+func tableStart(table *extast.Table, newlines []int) int {
+	r := 0
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			if cell.Lines().Len() == 0 {
+				continue
+			}
+			line := byteOffsetToLine(newlines, cell.Lines().At(0).Start)
+			if r == 0 {
+				return line
+			}
+			return line - r - 1 // the header and delimiter rows come before the first body row
+		}
+		r++
+	}
+	return -1
+}
+
+// formatTable returns the rows of a Markdown table with their cells padded so that the pipes line
+// up, and the delimiter row rewritten to match, keeping the alignment of each column. Rows are never
+// wrapped, however wide they are.
+func formatTable(lines []string, alignments []extast.Alignment, tabWidth int) []string {
+	rows := make([][]string, len(lines))
+	widths := make([]int, len(alignments))
+	for i, line := range lines {
+		if i == 1 {
+			continue // the delimiter row
+		}
+		rows[i] = tableCells(line)
+		for j, cell := range rows[i] {
+			if j < len(widths) {
+				widths[j] = max(widths[j], displayWidth(cell, tabWidth))
+			}
+		}
+	}
+	out := make([]string, len(lines))
+	for i, row := range rows {
+		var b strings.Builder
+		b.WriteString("|")
+		for j, align := range alignments {
+			w := max(widths[j], 3)
+			if i == 1 {
+				delim := strings.Repeat("-", w)
+				switch align {
+				case extast.AlignLeft:
+					delim = ":" + delim[1:]
+				case extast.AlignRight:
+					delim = delim[1:] + ":"
+				case extast.AlignCenter:
+					delim = ":" + delim[2:] + ":"
+				}
+				b.WriteString(" " + delim + " |")
+				continue
+			}
+			var cell string
+			if j < len(row) {
+				cell = row[j]
+			}
+			pad := w - displayWidth(cell, tabWidth)
+			switch align {
+			case extast.AlignRight:
+				cell = strings.Repeat(" ", pad) + cell
+			case extast.AlignCenter:
+				cell = strings.Repeat(" ", pad/2) + cell + strings.Repeat(" ", pad-pad/2)
+			default:
+				cell += strings.Repeat(" ", pad)
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		for j := len(alignments); j < len(row); j++ {
+			b.WriteString(" " + row[j] + " |") // cells beyond the header, which GFM ignores
+		}
+		out[i] = b.String()
+	}
+	return out
+}
+
+// tableCells splits a row of a Markdown table into its trimmed cells, at the pipes that are not
+// escaped ("\|"). The pipes at the start and end of the row are optional.
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	start := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, strings.TrimSpace(line[start:i]))
+			start = i + 1
+		}
+	}
+	return append(cells, strings.TrimSpace(line[start:]))
+}
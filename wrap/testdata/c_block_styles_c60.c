@@ -0,0 +1,16 @@
+/* Text on the opener line, continued without a star prefix and
+   aligned with the text, which is long enough to need rewrapping. */
+int a;
+
+/* Text on the opener line with the closer on its own line, long enough to wrap.
+ */
+int b;
+
+/*
+ * Bare opener with the closer trailing the last line of text, long enough. */
+int c;
+
+/**
+ * Javadoc style stays as it is, with the text long enough to need rewrapping.
+ */
+int d;
@@ -0,0 +1,22 @@
+/**
+ * \brief Parses a configuration file and returns the settings it holds, with defaults filled in.
+ *
+ * The file is read once, and a missing setting takes its default value from the built-in table.
+ * \param path The path of the configuration file, relative to the working directory of the process.
+ * \param strict Whether an unknown setting is an error rather than being ignored with a warning.
+ * \return The settings, which are valid until the next call.
+ *
+ * \code{.cpp}
+ * auto settings = parse("rewrap.toml", true); // strict, so that typos in the file are caught
+ *
+ * use(settings);
+ * \endcode
+ * \verbatim
+ * column = 100   # the column, kept as it is even when this line is long
+ * \endverbatim
+ */
+Settings parse(const std::string& path, bool strict);
+
+/// @brief Frees the settings returned by parse, which must not be used after this call returns.
+/// @param settings The settings to free.
+void release(Settings* settings);
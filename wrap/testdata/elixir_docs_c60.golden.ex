@@ -0,0 +1,32 @@
+# This file shows how Elixir comments and documentation
+# heredocs are rewrapped by the tool at sixty columns.
+defmodule Example.Users do
+  @moduledoc """
+  Functions for finding the users of the application, which
+  are stored in the database and cached for a short time.
+
+  ## Examples
+
+      iex> Example.Users.search("ada", 10)
+      [%User{name: "Ada Lovelace"}]
+
+  * The first item of a list that is long enough that it has
+    to be wrapped onto a second line.
+  * A short item.
+  """
+
+  @doc ~S"""
+  Returns the users that match the given `filter`, ordered
+  by the date they signed up, newest first.
+  """
+  def search(filter, limit) do
+    # credo:disable-for-next-line Credo.Check.Readability.LongQuoteBlocks because the query is long
+    query = """
+    # This is part of a string and not a comment, even though it is long enough to wrap.
+    """
+    Repo.search(query, filter, limit)
+  end
+
+  @doc false
+  def internal, do: :ok
+end
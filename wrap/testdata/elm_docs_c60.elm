@@ -0,0 +1,51 @@
+module Queue exposing (Queue, empty, push, pop)
+
+{-| A simple first-in first-out queue, backed by two lists so that both push and pop run in amortized constant time.
+
+# Building
+@docs Queue, empty, push
+
+# Consuming
+@docs pop
+
+-}
+
+-- The front list holds the next elements to pop, and the back list holds newly pushed elements in reverse order.
+type Queue a
+    = Queue (List a) (List a)
+
+
+{-| Create an empty queue. Pushing onto it and then popping
+gives back the same element:
+
+    empty |> push 1 |> pop
+
+-}
+empty : Queue a
+empty =
+    Queue [] []
+
+
+{- Internal note: this comment {- contains a nested comment -} and is left exactly as it was written, even though this line is long.
+-}
+push : a -> Queue a -> Queue a
+push x (Queue front back) =
+    Queue front (x :: back)
+
+
+{-|
+Remove the next element from the queue, returning it together with the remaining queue, or Nothing when empty.
+-}
+pop : Queue a -> Maybe ( a, Queue a )
+pop (Queue front back) =
+    case front of
+        x :: rest ->
+            Just ( x, Queue rest back )
+
+        [] ->
+            case List.reverse back of
+                [] ->
+                    Nothing
+
+                x :: rest ->
+                    Just ( x, Queue rest [] )
@@ -0,0 +1,28 @@
+module Geometry
+
+/// <summary>
+/// Computes the area of a rectangle from its width and
+/// height, both of which must be non-negative.
+/// </summary>
+/// <param name="width">The width of the rectangle, in the
+/// same unit as the height.</param>
+/// <param name="height">The height of the
+/// rectangle.</param>
+/// <returns>The area, in square units.</returns>
+/// <example>
+/// <code>
+/// let a = area 2.0 3.0 // a = 6.0, which is a rather long line that stays as is
+/// </code>
+/// </example>
+let area width height = width * height
+
+// Helpers below are internal and not part of the public API
+// surface of this module.
+(*
+   Block comments nest in F#, so this one is rewrapped only
+   when it contains no nested comments.
+*)
+let private square x = x * x
+
+(* Nested (* comments *) are left exactly as they were written, even when they are long. *)
+let private cube x = x * x * x
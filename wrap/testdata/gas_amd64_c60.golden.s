@@ -0,0 +1,14 @@
+#include "textflag.h"
+#define ZERO X15
+
+// add returns the sum of its arguments, which are passed on
+// the stack by the Go calling convention.
+//
+// func add(a, b int64) int64
+TEXT ·add(SB), NOSPLIT, $0-24
+	MOVQ a+0(FP), AX  // the first argument, loaded into AX
+	# Hash comments are used by GNU as on x86, and are
+	# rewrapped like the slash comments above.
+	ADDQ b+8(FP), AX
+	MOVQ AX, ret+16(FP)
+	RET
@@ -0,0 +1,18 @@
+package api
+
+// WidgetSpec defines the desired state of a widget, which is reconciled by the controller.
+// +kubebuilder:validation:Optional
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+type WidgetSpec struct {
+	// Replicas is the number of copies of the widget to run, which defaults to one when not set.
+	// +optional
+	Replicas int
+}
+
+func closeQuietly(f *os.File) {
+	// The error is ignored on purpose, as the file was only read.
+	// nolint:errcheck // closing a read-only file cannot fail in a way that matters to the caller here
+	// The deferred close in the caller is still
+	// needed for the error path.
+	f.Close()
+}
@@ -0,0 +1,10 @@
+package example
+
+// The closer trails the last word rather than starting a line of its own.
+
+/* Opening text.
+ * Middle line with star prefix that is very long and needs to be rewrapped to fit within the column width.
+ * Closing text. */
+
+/*
+Text on the closing line, which ends right at the col. */
@@ -0,0 +1,24 @@
+package a
+
+// rewrap:lang=markdown
+// Usage of the tool, which is embedded in the README by a generator and so is written in Markdown:
+//
+// - first item of a list that is long enough to need wrapping here
+// - second
+//
+// ```sh
+// tool --flag value --another-flag another-value --third-flag third-value
+// ```
+
+func F() {}
+
+// rewrap:lang=text
+// Plain text
+// in a Go comment:
+//   - not a list
+func G() {}
+
+// rewrap:lang=rst
+// An unknown name leaves the comment
+// as it is.
+func H() {}
@@ -0,0 +1,21 @@
+package example_test
+
+import "fmt"
+
+// ExampleGreet shows how to greet someone, with a doc comment long enough to need rewrapping.
+func ExampleGreet() {
+	fmt.Println("hello, world, this is a long line of output that must stay exactly as printed")
+	fmt.Println("bye")
+	// Output:
+	// hello, world, this is a long line of output that must stay exactly as printed
+	// bye
+}
+
+func ExampleUnordered() {
+	// Print the keys of the map, which come out in an unspecified order that changes between runs.
+	for _, k := range []string{"b", "a"} {
+		fmt.Println(k)
+	}
+	// Unordered output: a
+	// b
+}
@@ -0,0 +1,8 @@
+package example
+
+//go:generate go run ./cmd/gen -type=Kind -output=kind_string.go
+//   -trimprefix=Kind -linecomment -tags=integration,long_enough_to_wrap_at_sixty
+//   -template=./templates/kind.tmpl
+
+// Kind is rewrapped as usual, because it is separated from the directive by a blank line.
+type Kind int
@@ -0,0 +1,12 @@
+package main
+
+// usageText is printed by the help command. This comment is long enough that it gets rewrapped.
+const usageText = `Usage: tool [flags]
+
+// This line is part of the usage text, not a comment, and must stay exactly as it is written.
+/* Nor is this a block comment. */
+`
+
+// query holds SQL, which uses "--" for comments, but the text also contains things that look like Go comments.
+var query = `SELECT 1 // not a comment in Go either, even though it is long enough to be rewrapped
+`
@@ -0,0 +1,19 @@
+# The bucket holds the build artifacts of every branch, which are removed after thirty days by the lifecycle rule.
+# tflint-ignore: terraform_naming_convention
+resource "aws_s3_bucket" "Artifacts" {
+  bucket = "example-artifacts"
+}
+
+// Slash comments are a separate block from the hash comments that follow,
+// even with nothing between them.
+# Hash comment that is long enough to need wrapping at sixty columns.
+
+/*
+ * The policy lets the CI role read and write objects,
+ * but never delete them or change the bucket, which only administrators can do.
+ */
+resource "aws_iam_policy" "ci" {
+  policy = <<-EOT
+    # This is part of the heredoc, not a comment, so it is never rewrapped even though it is long.
+  EOT
+}
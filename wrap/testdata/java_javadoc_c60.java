@@ -0,0 +1,19 @@
+package com.example;
+
+/**
+ * Returns the users that match the given filter, ordered by the date they signed up, newest first. Pass {@code Integer.MAX_VALUE} for no limit.
+ * <p>
+ * Use it like this:
+ * <pre>{@code
+ * List<User> users = repository.list("jane", 10); // the first ten users named jane
+ * }</pre>
+ * @param filter A search string matched against the name and the email address of each user.
+ * @param limit The maximum number of users to return.
+ * @return The matching users, which may be an empty list if there are none.
+ * @throws IllegalArgumentException If the limit is negative or larger than the configured maximum.
+ * @see Repository#search(String, int)
+ */
+public List<User> list(String filter, int limit) {
+    // A line comment, which is long enough to need rewrapping at sixty.
+    return repository.search(filter, limit);
+}
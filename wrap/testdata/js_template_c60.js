@@ -0,0 +1,7 @@
+// Render the page header. This comment is long enough that it needs to be rewrapped at sixty.
+export function header(title) {
+  return `
+    // Inside a template literal: kept verbatim, even though it looks like a long line comment.
+    <h1>${title}</h1>
+  `;
+}
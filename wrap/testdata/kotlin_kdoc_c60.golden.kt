@@ -0,0 +1,22 @@
+// This file shows how Kotlin comments and KDoc blocks are
+// rewrapped by the tool at sixty columns.
+package example
+
+/**
+ * Returns the users that match the given filter, ordered by
+ * the date they signed up, newest first.
+ *
+ * @param filter A search string matched against the name
+ *     and the email address of each user.
+ * @param limit The maximum number of users to return.
+ * @return The matching users, which may be an empty list if
+ *     there are none at all.
+ */
+fun search(filter: String, limit: Int): List<User> {
+    // ktlint-disable max-line-length because the query below is easier to read on a single line
+    val query = """
+        // This is part of a raw string and not a comment, even though it is long enough to wrap.
+    """
+    /* A block comment /* with a nested comment */ that is left as it is, even though it is long. */
+    return repository.search(query, filter, limit)
+}
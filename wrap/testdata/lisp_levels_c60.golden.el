@@ -0,0 +1,22 @@
+;;; widget.el --- Widgets for the impatient  -*- lexical-binding: t -*-
+
+;;; Commentary:
+
+;; This package provides widgets that are drawn quickly,
+;; even in buffers that are very large. It has no
+;; dependencies.
+
+;;; Code:
+
+;;;###autoload
+(defun widget-draw (w)
+  ;; Draw the widget W in the current buffer, starting at
+  ;; point and moving point past it.
+  ;;; A three-semicolon line is a different level and is not
+  ;;; merged into the comment above it.
+  (insert (widget-text w)) ; the text is already escaped, so it can be inserted as it is without any checks
+  (point))
+
+;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;
+;;;; Helpers
+;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;;
@@ -0,0 +1,17 @@
+;;;; A Common Lisp file header that is long enough to need
+;;;; wrapping at sixty columns.
+
+#|
+A block comment in Common Lisp, which may #| nest |# other block comments, is left as it is.
+|#
+
+#|
+A plain block comment, on the other hand, is rewrapped like
+any other block comment in the file.
+|#
+
+;;; Section comment.
+;; A comment about the function below, which is longer than
+;; the column and must be wrapped.
+(defun add (a b)
+  (+ a b))
@@ -0,0 +1,20 @@
+--- Returns the sum of a and b, which are both numbers that the caller has already validated.
+-- @param a the first number, which may be negative or zero or anything that fits in a double
+-- @return number
+local function add(a, b)
+  return a + b -- trailing comment stays
+end
+
+--[[
+  A block comment in Lua runs until the closing brackets and holds prose that is long enough to wrap.
+]]
+
+--[==[ Single-line long-bracket comments are left alone, like other single-line block comments. ]==]
+
+local s = [[
+-- not a comment: this is inside a long string literal and must not be touched at all by rewrap
+]]
+
+local t = [=[
+-- nor is this, inside a level-one long string that may contain ]] without ending the string
+]=]
@@ -0,0 +1,10 @@
+; The boot sector is loaded at 0x7c00 by the BIOS, which jumps to it in real mode with the drive number in DL.
+bits 16
+org 0x7c00
+
+start:
+    ; Clear the direction flag so that string instructions such as lodsb move forward through memory.
+    cld
+    mov si, msg     ; the message to print
+    ;; Section comments with two semicolons are kept apart from the comments around them.
+    ; A short comment.
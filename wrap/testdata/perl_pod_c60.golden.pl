@@ -0,0 +1,45 @@
+#!/usr/bin/perl
+# This script shows how Perl comments and POD documentation
+# are rewrapped by the tool at sixty columns.
+use strict;
+use warnings;
+
+=head1 NAME
+
+search - find the users that match a filter, ordered by the
+date they signed up
+
+=head1 SYNOPSIS
+
+    search --limit 10 ada
+
+=head1 DESCRIPTION
+
+Returns the users that match the given filter, ordered by
+the date they signed up, newest first. The filter is matched
+against the name and the email address of each user.
+
+=over 4
+
+=item B<--limit>
+
+The maximum number of users to return, which defaults to
+twenty when it is not given.
+
+=back
+
+=begin html
+
+<p>This HTML is for one formatter only and is left exactly as it is, even though it is long.</p>
+
+=end html
+
+=cut
+
+my $query = <<"SQL";
+# This is part of a heredoc and not a comment, even though it is long enough to wrap.
+SELECT * FROM users
+SQL
+
+## no critic (ProhibitStringyEval) because the query is built from trusted input only
+print search($query);
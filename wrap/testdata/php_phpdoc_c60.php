@@ -0,0 +1,25 @@
+<?php
+// This file is part of the example application and is only used to show how comments are wrapped.
+
+namespace App\Controller;
+
+# Shell-style comments are comments too, and are wrapped just like the double-slash ones above.
+#[Route('/users', name: 'user_list', methods: ['GET', 'POST'], priority: 10, condition: "true")]
+class UserController
+{
+    /**
+     * Returns the users that match the given filter, ordered by the date they signed up, newest first.
+     *
+     * @param string $filter A search string matched against the name and the email address of each user.
+     * @param int $limit The maximum number of users to return.
+     * @return User[] The matching users, which may be an empty list if there are none.
+     * @throws InvalidArgumentException If the limit is negative or larger than the configured maximum.
+     */
+    public function list(string $filter, int $limit): array
+    {
+        // phpcs:ignore Generic.Files.LineLength.TooLong -- the query is easier to read on one line
+        $users = $this->repository->search($filter, $limit);
+        /* A regular block comment whose text is long enough to need rewrapping at sixty. */
+        return $users;
+    }
+}
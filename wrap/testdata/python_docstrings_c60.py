@@ -0,0 +1,37 @@
+"""Utilities for scheduling background jobs. This module docstring runs past the column and is rewrapped.
+
+The second paragraph is also
+wrapped, joining its short lines.
+"""
+
+import asyncio
+
+
+class Scheduler:
+    """Runs jobs at fixed intervals. The closing quotes stay on their own line, as written.
+
+    Attributes:
+        interval: The number of seconds between runs, which must be positive or the scheduler refuses to start.
+        jobs: The registered jobs.
+    """
+
+    LABEL = """A class attribute string is data, not a docstring, so it is never rewrapped however long it is."""
+
+    def __init__(self, interval):
+        '''Create a scheduler that runs every interval seconds, using single quotes for the docstring.'''
+        self.interval = interval
+
+    async def run(
+        self,
+        limit: int = 0,
+    ) -> None:
+        """Run the registered jobs until cancelled. The signature above spans several lines but this is still the docstring.
+
+        Example:
+
+            >>> asyncio.run(Scheduler(1).run(limit=1))
+        """
+        text = """
+        This string comes after the docstring, so it is data and must stay exactly as it is written here.
+        """
+        await asyncio.sleep(self.interval)
@@ -0,0 +1,4 @@
+## A section comment in the style of Doxygen, which is long enough to be wrapped.
+# An ordinary comment that follows it and must stay a separate block from it.
+#: The number of retries, documented for Sphinx with an attribute doc comment.
+RETRIES = 3
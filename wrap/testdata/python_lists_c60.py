@@ -0,0 +1,12 @@
+# Supported modes, each of which is described in more detail in the documentation that ships with the package:
+#
+# * fast: skips validation entirely and trusts the input, which is only safe for data produced by this tool
+# * safe: validates every record
+# - strict: validates every record and
+#   refuses to continue on the first error
+#
+# Steps:
+# 1. Read the configuration file from disk and merge it with any overrides from the environment.
+# 2. Run.
+def run():
+    pass
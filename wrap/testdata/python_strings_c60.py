@@ -0,0 +1,37 @@
+r"""Helpers for querying the reports database. This module docstring is raw because it mentions C:\reports paths.
+"""
+
+import textwrap
+
+# The queries below are data, not comments, so the lines that look like comments inside them must never be rewrapped.
+REPORT_QUERY = """
+# This line looks like a comment but it is part of the query text and must stay exactly as written.
+SELECT id, name FROM reports WHERE owner = %s
+"""
+
+TEMPLATE = f'''
+    # Report for {{name}}: a long line in an f-string that also looks like a comment to a naive matcher.
+'''
+
+PAYLOAD = b"""
+# bytes that happen to start with a hash and run on for quite a while past the column
+"""
+
+
+def fetch(cursor, owner):
+    u"""Fetch all reports owned by the given user, ordered by name, and return them as a list.
+
+    Args:
+      cursor: An open database cursor. It isn't closed by this function, so callers keep ownership.
+      owner: The user name.
+    """
+    # Don't use string formatting here; the driver quotes parameters for us, which avoids injection.
+    cursor.execute(REPORT_QUERY, (owner,))
+    return cursor.fetchall()
+
+
+def render(name):
+    text = textwrap.dedent("""
+        # Heading for a rendered report, which is long enough that it would be wrapped if it were a comment.
+    """)
+    return text.format(name=name)
@@ -0,0 +1,16 @@
+# rewrap:off
+# This table is aligned by hand:
+#   name    | meaning
+#   --------+------------------------------------------------------------------
+#   column  | the wrapping column, which is long enough to need wrapping here
+# rewrap:on
+x = 1
+
+# This comment is rewrapped as usual, because it is long
+# enough to need rewrapping at sixty.
+
+# rewrap:ignore
+# Only this comment is left alone, even though it is long enough to need rewrapping at sixty.
+y = 2
+# The next comment is rewrapped again, as it is long enough
+# to need rewrapping at sixty columns.
@@ -0,0 +1,9 @@
+# Builds the SQL for the report. This comment is long enough that it needs to be rewrapped.
+def report_sql(list)
+  list << item
+  # The shift above is not a heredoc, so this long comment is still rewrapped as usual.
+  <<~SQL
+    # Not a comment: this line is part of the SQL string and must be left exactly as written.
+    SELECT * FROM reports
+  SQL
+end
@@ -0,0 +1,26 @@
+=begin
+This file was generated by the scaffolding tool and
+documents the public API of the client library in one place.
+
+A second paragraph that was wrapped too early.
+=end
+
+module Example
+  # Fetches a resource from the API and returns the parsed
+  # body, retrying on transient network failures.
+  #
+  # @param path [String] the path of the resource, relative
+  #     to the base URL configured on the client
+  # @param retries [Integer] how many times to retry before
+  #   giving up and raising.
+  # @return [Hash] the parsed response body
+  # @example Fetch a user
+  #   client.fetch("/users/1")
+  #
+  #   client.fetch("/users/2", retries: 5)
+  # @raise [Error] if the request fails after all retries
+  #     have been used up
+  def fetch(path, retries: 3)
+    # TODO
+  end
+end
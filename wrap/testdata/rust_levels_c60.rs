@@ -0,0 +1,12 @@
+//! Crate-level documentation, which is an inner doc comment and is wrapped on its own.
+/// Outer documentation for the function below, which is long enough to be wrapped here.
+///
+/// A second paragraph of the doc comment.
+// An ordinary comment directly after the doc comment, which must not be merged with it.
+fn add(a: i32, b: i32) -> i32 {
+    a + b
+}
+
+//////////////////////////////////////////////////////////////
+// Helpers
+//////////////////////////////////////////////////////////////
@@ -0,0 +1,20 @@
+//! # Rewrap
+//!
+//! A crate that rewraps comments, with a description that is long enough to be wrapped.
+
+/// Parses a configuration file and returns the settings it holds, with defaults filled in.
+///
+/// # Errors
+///
+/// - Returns an error if the file cannot be read, or if it is not valid TOML.
+/// - Returns an error if a setting has the wrong type.
+///
+/// # Examples
+///
+/// ```
+/// let config = parse("rewrap.toml").expect("the configuration file should be valid");
+/// ```
+pub fn parse(path: &str) -> Result<Config, Error> {
+    // An ordinary comment, which is long enough to be wrapped at the column of sixty.
+    todo!()
+}
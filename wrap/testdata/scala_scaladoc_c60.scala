@@ -0,0 +1,22 @@
+// This file shows how Scala comments and Scaladoc blocks are rewrapped by the tool at sixty columns.
+package example
+
+/** Returns the users that match the given filter, ordered by the date they signed up, newest first.
+  *
+  * @param filter A search string matched against the name and the email address of each user.
+  * @param limit The maximum number of users to return.
+  * @return The matching users, which may be an empty list if there are none at all.
+  */
+def search(filter: String, limit: Int): Seq[User] = {
+  // scalafmt: { maxColumn = 120 } applies to the rest of this file, so it is left on its own line
+  val query = """
+    // This is part of a raw string and not a comment, even though it is long enough to wrap.
+  """
+  /* A block comment /* with a nested comment */ that is left as it is, even though it is long. */
+  repository.search(query, filter, limit)
+}
+
+/**
+ * A Scaladoc block in the Javadoc layout, with each star under the first star of the opener, keeps it.
+ */
+object Users
@@ -0,0 +1,15 @@
+# Generate the nginx config for the service. This comment is long enough that it needs to be rewrapped.
+cat > /etc/nginx/conf.d/app.conf <<EOF
+# This line is part of the generated file, not a comment of this script, and is kept as is.
+server { listen 80; }
+EOF
+
+if true; then
+	cat <<-'END'
+	# Indented heredoc body with a tab-stripped terminator, which must not be touched by rewrap.
+	END
+fi
+
+# Here-strings are not heredocs, so the comment after this line is still rewrapped as usual.
+grep foo <<< "bar"
+# A comment after the here-string that is long enough to wrap at the sixty column limit.
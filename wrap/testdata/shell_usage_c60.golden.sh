@@ -0,0 +1,9 @@
+# Usage: deploy.sh [options] environment
+#
+#   -n, --dry-run   show what would be deployed without
+#                   deploying anything at all
+#   -t TAG          deploy the image with this tag instead
+#                   of the latest one built
+deploy() {
+  :
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: MIT
+// This contract is a minimal token used only in the
+// examples and is not meant for production use.
+pragma solidity ^0.8.20;
+
+/// @title A minimal token contract for the documentation
+///     examples
+/// @author The example team
+/// @notice Tracks balances and lets holders move tokens
+///     between accounts without any fees.
+contract Token {
+    mapping(address => uint256) private balances;
+
+    /**
+     * @notice Moves tokens from the caller to another
+     *     account, reverting if the balance is too low.
+     * @dev Emits a {Transfer} event. The check is done
+     *      before the subtraction so that the error message
+     *      is meaningful.
+     * @param to The account that receives the tokens.
+     * @param amount The number of tokens to move, in the
+     *     smallest unit of the token.
+     * @return success Always true; failures revert instead
+     *     of returning false.
+     */
+    function transfer(address to, uint256 amount) external returns (bool success) {
+        /* A regular block comment whose text is long enough to need rewrapping at sixty. */
+        require(balances[msg.sender] >= amount, "insufficient balance");
+        balances[msg.sender] -= amount;
+        balances[to] += amount;
+        return true;
+    }
+}
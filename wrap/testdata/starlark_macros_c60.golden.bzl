@@ -0,0 +1,51 @@
+"""Macros for building and testing the example service,
+shared by every BUILD file in the repository.
+"""
+
+load("@rules_go//go:def.bzl", "go_binary")
+
+# This comment explains why the macro exists and is long
+# enough to need rewrapping at sixty.
+def example_binary(name, srcs, deps = [], visibility = None):
+    """Defines a Go binary for the example service with the
+    standard set of dependencies and flags.
+
+    This paragraph was wrapped at a very narrow width and
+    should be reflowed.
+
+    Args:
+      name: The name of the target. This is also used as the
+          name of the binary that is produced.
+      srcs: Source files.
+      deps: Additional dependencies, which are appended to
+          the standard set of dependencies shared by every
+          service.
+      visibility: Passed through to go_binary.
+
+    Returns:
+      Nothing, but the description of the return value is
+      long enough to wrap around.
+
+    Example:
+        example_binary(
+            name = "server",
+        )
+    """
+    go_binary(
+        name = name,
+        srcs = srcs,
+        deps = deps + ["//lib:common"],
+        visibility = visibility,
+    )
+
+def short_docstring():
+    """One-line docstrings that run past the column are
+    expanded into the multi-line form.
+    """
+    pass
+
+def closing_inline():
+    """Closing quotes on the last line of text.
+
+    They are kept there, with the text before them rewrapped
+    to fit within the column."""
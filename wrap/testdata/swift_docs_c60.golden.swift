@@ -0,0 +1,26 @@
+// This file shows how Swift comments and Markdown doc
+// comments are rewrapped at sixty columns.
+import Foundation
+
+// MARK: - Searching users by name, email address and signup date, newest first
+
+/// Returns the users that match the given filter, ordered
+/// by the date they signed up, newest first.
+///
+/// - Parameter filter: A search string matched against the
+///   name and the email address of each user.
+/// - Parameter limit: The maximum number of users to
+///   return.
+/// - Returns: The matching users, which may be empty.
+///
+/// ```swift
+/// let users = search(filter: "ann", limit: 10) // a code block that is never rewrapped at all
+/// ```
+func search(filter: String, limit: Int) -> [User] {
+    // swiftlint:disable:next line_length because the query below is easier to read on one line
+    let query = """
+    // This is part of a multi-line string and not a comment, even though it is long enough to wrap.
+    """
+    /* A block comment /* with a nested comment */ that is left as it is, even though it is long. */
+    return repository.search(query, filter, limit)
+}
@@ -0,0 +1,18 @@
+/**
+ * Fetches a resource from the API and returns the parsed
+ * body, retrying on transient network failures.
+ * @param {string} path The path of the resource, relative
+ *     to the base URL configured on the client.
+ * @param {number} [retries=3] How many times to retry
+ *     before giving up.
+ * @returns {Promise<object>} The parsed response body.
+ * @throws {NetworkError} If every attempt fails, or the
+ *     response cannot be parsed as JSON.
+ * @example
+ * const user = await fetchJSON("/users/1");
+ * const posts = await fetchJSON("/users/1/posts", 5); // retry more for the slow endpoint
+ */
+export async function fetchJSON(path: string, retries = 3): Promise<object> {
+  // @ts-expect-error the client is untyped, and this comment is long but kept on one line
+  return client.get(path, { retries });
+}
@@ -1,14 +1,17 @@
 package wrap
 
 import (
+	"regexp"
+	"slices"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
-// wrapText wraps the given text to fit within columnWidth, accounting for the prefix added to each
+// wrapText wraps the given text to fit within opts.Column, accounting for the prefix added to each
 // line. The first line uses prefix, subsequent lines use subsequentPrefix. Paragraph breaks (blank
 // lines) are preserved.
-func wrapText(text string, prefix string, subsequentPrefix string, columnWidth int, tabWidth int) []string {
+func wrapText(text string, prefix string, subsequentPrefix string, opts Options) []string {
 	if text == "" {
 		return []string{prefix}
 	}
@@ -20,7 +23,7 @@ func wrapText(text string, prefix string, subsequentPrefix string, columnWidth i
 			// Blank line between paragraphs, using the subsequent prefix trimmed of trailing space.
 			result = append(result, strings.TrimRight(subsequentPrefix, " "))
 		}
-		lines := wrapParagraph(para, prefix, subsequentPrefix, columnWidth, tabWidth, i == 0)
+		lines := wrapParagraph(para, prefix, subsequentPrefix, opts, i == 0)
 		result = append(result, lines...)
 	}
 	return result
@@ -35,7 +38,7 @@ func splitParagraphs(text string) []string {
 		trimmed := strings.TrimSpace(line)
 		if trimmed == "" {
 			if len(current) > 0 {
-				paragraphs = append(paragraphs, strings.Join(current, " "))
+				paragraphs = append(paragraphs, strings.Join(current, "\n"))
 				current = nil
 			}
 		} else {
@@ -43,24 +46,142 @@ func splitParagraphs(text string) []string {
 		}
 	}
 	if len(current) > 0 {
-		paragraphs = append(paragraphs, strings.Join(current, " "))
+		paragraphs = append(paragraphs, strings.Join(current, "\n"))
 	}
 	return paragraphs
 }
 
-// wrapParagraph wraps a single paragraph of text using greedy line breaking.
-func wrapParagraph(text string, prefix, subsequentPrefix string, columnWidth, tabWidth int, isFirst bool) []string {
+// inlineTagEnd returns the end of the word text[start:end], extended to the end of the word after
+// a Javadoc {@code ...} or {@literal ...} tag that it opens and that closes on the same line, so
+// that the tag is never broken across lines. Otherwise, it returns end.
+func inlineTagEnd(text string, start, end int) int {
+	word := text[start:end]
+	k := max(strings.LastIndex(word, "{@code"), strings.LastIndex(word, "{@literal"))
+	if k < 0 || braceDepth(word[k:]) <= 0 {
+		return end
+	}
+	depth := 0
+	for j := start + k; j < len(text) && text[j] != '\n'; j++ {
+		switch text[j] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				for j++; j < len(text) && text[j] != ' ' && text[j] != '\t' && text[j] != '\n'; j++ {
+				}
+				return j
+			}
+		}
+	}
+	return end
+}
+
+// maxSpanLength bounds the search for the end of a Markdown link in linkEnd, and of a code span
+// in codeSpanEnd, so that text with many unclosed brackets or backticks takes linear time. Longer
+// links and code spans are wrapped like other text.
+const maxSpanLength = 2048
+
+// linkEnd returns the end of the word text[start:end], extended to the end of the word after a
+// Markdown inline link or image, such as "[the docs](https://example.com)", or reference link,
+// such as "[the docs][docs]", that it starts, so that the link is never broken across lines: a line
+// that is too long for it overflows instead. Otherwise, it returns end.
+func linkEnd(text string, start, end int) int {
+	k := strings.IndexByte(text[start:end], '[')
+	if k < 0 {
+		return end
+	}
+	limit := min(len(text), start+k+maxSpanLength)
+	j := closingBracket(text[:limit], start+k)
+	if j < 0 || j+1 >= limit || text[j+1] != '(' && text[j+1] != '[' {
+		return end
+	}
+	if j = closingBracket(text[:limit], j+1); j < end {
+		return end
+	}
+	for j++; j < len(text) && text[j] != ' ' && text[j] != '\t' && text[j] != '\n'; j++ {
+	}
+	return j
+}
+
+// codeSpanEnd returns the end of the word text[start:end], extended to the end of the word after an
+// inline code span, such as "`go test ./...`", that it opens, so that the span is never broken
+// across lines. A span is closed by a run of as many backticks as opened it; a backtick that is
+// not closed is an ordinary character. Otherwise, it returns end.
+func codeSpanEnd(text string, start, end int) int {
+	for i := start; i < end; {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		n := backtickRun(text, i)
+		limit := min(len(text), i+maxSpanLength)
+		j := i + n
+		for j < limit && (text[j] != '`' || backtickRun(text, j) != n) {
+			if text[j] == '`' {
+				j += backtickRun(text, j)
+			} else {
+				j++
+			}
+		}
+		if j >= limit {
+			return end
+		}
+		if i = j + n; i > end {
+			for end = i; end < len(text) && text[end] != ' ' && text[end] != '\t' && text[end] != '\n'; end++ {
+			}
+		}
+	}
+	return end
+}
+
+// backtickRun returns the number of backticks in the run that starts at text[i].
+func backtickRun(text string, i int) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == '`' {
+		n++
+	}
+	return n
+}
+
+// closingBracket returns the index of the bracket that closes the one at text[i], "[" or "(", or
+// -1 if it is not closed.
+func closingBracket(text string, i int) int {
+	open, close := text[i], byte(']')
+	if open == '(' {
+		close = ')'
+	}
+	depth := 0
+	for ; i < len(text); i++ {
+		switch text[i] {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// wrapParagraph wraps a single paragraph of text using greedy line breaking. Line breaks in text
+// are spaces, except that with opts.PreserveSentenceStarts, those at a sentence break (see
+// sentenceBreak) are kept.
+func wrapParagraph(text string, prefix, subsequentPrefix string, opts Options, isFirst bool) []string {
+	columnWidth, tabWidth := opts.Column, opts.TabWidth
 	// Split into tokens that preserve the original inter-word spacing. Each token has the
 	// whitespace that preceded it (empty for the first token) and the word text.
 	type token struct {
-		gap  string // whitespace before this word in the original text
-		word string
+		gap     string // whitespace before this word in the original text
+		word    string
+		newLine bool // the word starts a new line
 	}
 	var tokens []token
+	urlLine := false // the last token is a URL on a line of its own so far
 	i := 0
 	for i < len(text) {
 		gapStart := i
-		for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+		for i < len(text) && (text[i] == ' ' || text[i] == '\t' || text[i] == '\n') {
 			i++
 		}
 		if i >= len(text) {
@@ -68,14 +189,56 @@ func wrapParagraph(text string, prefix, subsequentPrefix string, columnWidth, ta
 		}
 		gap := text[gapStart:i]
 		wordStart := i
-		for i < len(text) && text[i] != ' ' && text[i] != '\t' {
+		for i < len(text) && text[i] != ' ' && text[i] != '\t' && text[i] != '\n' {
 			i++
 		}
-		tokens = append(tokens, token{gap: gap, word: text[wordStart:i]})
+		i = inlineTagEnd(text, wordStart, i)
+		i = codeSpanEnd(text, wordStart, i)
+		if opts.markdown {
+			i = linkEnd(text, wordStart, i)
+		}
+		tok := token{gap: gap, word: text[wordStart:i]}
+		if strings.Contains(tok.word, "\n") {
+			tok.word = strings.ReplaceAll(tok.word, "\n", " ") // a link spanning lines
+		}
+		if strings.Contains(gap, "\n") {
+			tok.gap = " "
+			tok.newLine = opts.PreserveSentenceStarts && len(tokens) > 0 && sentenceBreak(tokens[len(tokens)-1].word, tok.word)
+			if urlLine {
+				// A line that is only a URL is kept on its own line, so that it stays easy to
+				// click, copy and search for.
+				tokens[len(tokens)-1].newLine = true
+				tok.newLine = true
+			}
+		}
+		urlLine = (len(tokens) == 0 || strings.Contains(gap, "\n")) && isURL(tok.word)
+		tokens = append(tokens, tok)
 	}
 	if len(tokens) == 0 {
 		return nil
 	}
+	if urlLine {
+		tokens[len(tokens)-1].newLine = true
+	}
+	if opts.BreakLongWords {
+		// Words wider than a line are broken into pieces that fit, each on a line of its own.
+		width := max(columnWidth-displayWidth(subsequentPrefix, tabWidth), 1)
+		var broken []token
+		for _, tok := range tokens {
+			if isURL(tok.word) || isPath(tok.word) {
+				broken = append(broken, tok) // URLs and paths are never broken
+				continue
+			}
+			for j, piece := range breakWord(tok.word, width, tabWidth) {
+				if j > 0 {
+					tok.gap, tok.newLine = " ", true
+				}
+				tok.word = piece
+				broken = append(broken, tok)
+			}
+		}
+		tokens = broken
+	}
 
 	var lines []string
 	currentPrefix := prefix
@@ -91,13 +254,18 @@ func wrapParagraph(text string, prefix, subsequentPrefix string, columnWidth, ta
 	for idx, tok := range tokens {
 		wordWidth := displayWidth(tok.word, tabWidth)
 		if line.Len() > 0 {
-			gapWidth := displayWidth(tok.gap, tabWidth)
+			gap := tok.gap
+			if opts.ExpandTabs && strings.Contains(gap, "\t") {
+				gap = expandTabs(gap, displayWidth(currentPrefix, tabWidth)+lineWidth, tabWidth)
+			}
+			gapWidth := displayWidth(gap, tabWidth)
 			if idx == 0 {
 				gapWidth = 0
 			}
 			// Use a single space as the minimum gap for wrapping decisions.
 			breakWidth := max(gapWidth, 1)
-			if lineWidth+breakWidth+wordWidth > available {
+			if (tok.newLine || lineWidth+breakWidth+wordWidth > available) &&
+				(opts.canStartLine == nil || opts.canStartLine(subsequentPrefix, tok.word)) {
 				lines = append(lines, currentPrefix+line.String())
 				line.Reset()
 				lineWidth = 0
@@ -106,7 +274,7 @@ func wrapParagraph(text string, prefix, subsequentPrefix string, columnWidth, ta
 			} else {
 				// Preserve original spacing within a line.
 				if gapWidth > 0 {
-					line.WriteString(tok.gap)
+					line.WriteString(gap)
 				} else {
 					line.WriteByte(' ')
 					gapWidth = 1
@@ -123,6 +291,88 @@ func wrapParagraph(text string, prefix, subsequentPrefix string, columnWidth, ta
 	return lines
 }
 
+// urlSchemes are the prefixes of the words that isURL reports as URLs.
+var urlSchemes = []string{"http://", "https://", "ftp://", "mailto:"}
+
+// isURL reports whether word is a URL, such as "https://example.com", possibly in angle brackets or
+// parentheses.
+func isURL(word string) bool {
+	word = strings.TrimLeft(word, "<([\"'")
+	return slices.ContainsFunc(urlSchemes, func(scheme string) bool {
+		return len(word) > len(scheme) && strings.HasPrefix(strings.ToLower(word[:len(scheme)]), scheme)
+	})
+}
+
+// pathPattern matches a slash-separated path of at least two elements, such as "net/http",
+// "./cmd/main.go" or "/etc/hosts".
+var pathPattern = regexp.MustCompile(`^(~|\.{1,2})?/?[\w.@-]+(/[\w.@-]+)+/?$`)
+
+// maxPathElement is the longest element of a path that isPath reports, so that data such as base64,
+// which also has slashes, is not mistaken for one.
+const maxPathElement = 64
+
+// isPath reports whether word is a file or import path, such as "internal/storage/db.go", possibly
+// quoted or in brackets.
+func isPath(word string) bool {
+	word = strings.Trim(word, "`\"'()[]<>,;:")
+	if !pathPattern.MatchString(word) {
+		return false
+	}
+	for elem := range strings.SplitSeq(word, "/") {
+		if len(elem) > maxPathElement {
+			return false
+		}
+	}
+	return true
+}
+
+// breakWord splits word into pieces no wider than width, or returns it whole if it fits.
+func breakWord(word string, width, tabWidth int) []string {
+	var pieces []string
+	start, w := 0, 0
+	for i, r := range word {
+		rw := displayWidth(string(r), tabWidth)
+		if w+rw > width && i > start {
+			pieces = append(pieces, word[start:i])
+			start, w = i, 0
+		}
+		w += rw
+	}
+	return append(pieces, word[start:])
+}
+
+// sentenceBreak reports whether a line ending with the word prev and a line starting with the word
+// next are separate sentences, or at least not clearly one: unless prev ends without terminal
+// punctuation and next starts with a lower-case letter.
+func sentenceBreak(prev, next string) bool {
+	r, _ := utf8.DecodeRuneInString(next)
+	if !unicode.IsLower(r) {
+		return true
+	}
+	prev = strings.TrimRight(prev, `"')]}”’`)
+	return strings.HasSuffix(prev, ".") || strings.HasSuffix(prev, "!") || strings.HasSuffix(prev, "?") || strings.HasSuffix(prev, ":")
+}
+
+// expandTabs replaces the tabs in s with spaces up to the next tab stop, assuming s starts at
+// display column col.
+func expandTabs(s string, col, tabWidth int) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] == '\t' {
+			n := tabWidth - (col % tabWidth)
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			i++
+		} else {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+size])
+			col++
+			i += size
+		}
+	}
+	return b.String()
+}
+
 // displayWidth calculates the display width of a string, expanding tabs to tabWidth columns.
 func displayWidth(s string, tabWidth int) int {
 	col := 0
@@ -138,3 +388,40 @@ func displayWidth(s string, tabWidth int) int {
 	}
 	return col
 }
+
+// existingWidth returns the column that lines are already wrapped at, or 0 if they don't look
+// deliberately wrapped. The width is that of the longest line followed by more text in the same
+// paragraph; the last line of a paragraph may be shorter, or longer if text was added to it. Lines
+// count as wrapped at that width when the first word of each line would not have fit at the end of
+// the line before it, and there are at least two such line breaks. Words are taken to be fields with
+// a letter or digit, so that comment markers and "*" prefixes are skipped.
+func existingWidth(lines []string, tabWidth int) int {
+	var widths []int // widths of the lines followed by more text in the same paragraph
+	var next []string
+	for i := 1; i < len(lines); i++ {
+		if firstWord(lines[i-1]) != "" && firstWord(lines[i]) != "" {
+			widths = append(widths, displayWidth(strings.TrimRight(lines[i-1], " \t"), tabWidth))
+			next = append(next, firstWord(lines[i]))
+		}
+	}
+	if len(widths) < 2 {
+		return 0
+	}
+	width := slices.Max(widths)
+	for i, w := range widths {
+		if w+1+displayWidth(next[i], tabWidth) <= width {
+			return 0
+		}
+	}
+	return width
+}
+
+// firstWord returns the first field of line that contains a letter or digit, or "".
+func firstWord(line string) string {
+	for f := range strings.FieldsSeq(line) {
+		if strings.IndexFunc(f, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			return f
+		}
+	}
+	return ""
+}
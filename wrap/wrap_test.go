@@ -76,10 +76,50 @@ func TestWrapText(t *testing.T) {
 				"narrow width",
 			},
 		},
+		{
+			// Words break only at whitespace, so paths and import paths are never split.
+			name:             "path moves to the next line whole",
+			text:             "the handler in cmd/server/main.go imports net/http",
+			prefix:           "// ",
+			subsequentPrefix: "// ",
+			columnWidth:      24,
+			tabWidth:         4,
+			want: []string{
+				"// the handler in",
+				"// cmd/server/main.go",
+				"// imports net/http",
+			},
+		},
+		{
+			name:             "path longer than the column stays whole",
+			text:             "see internal/storage/postgres/migrations/0001_init.sql for the schema",
+			prefix:           "// ",
+			subsequentPrefix: "// ",
+			columnWidth:      20,
+			tabWidth:         4,
+			want: []string{
+				"// see",
+				"// internal/storage/postgres/migrations/0001_init.sql",
+				"// for the schema",
+			},
+		},
+		{
+			name:             "inline code tag kept whole",
+			text:             "returns {@code new int[] {1, 2}}, or null",
+			prefix:           " * ",
+			subsequentPrefix: " * ",
+			columnWidth:      24,
+			tabWidth:         4,
+			want: []string{
+				" * returns",
+				" * {@code new int[] {1, 2}},",
+				" * or null",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := wrapText(tt.text, tt.prefix, tt.subsequentPrefix, tt.columnWidth, tt.tabWidth)
+			got := wrapText(tt.text, tt.prefix, tt.subsequentPrefix, Options{Column: tt.columnWidth, TabWidth: tt.tabWidth})
 			require.Len(t, got, len(tt.want), "got:\n%s\nwant:\n%s",
 				strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
 			for i := range got {
@@ -105,3 +145,124 @@ func TestDisplayWidth(t *testing.T) {
 		assert.Equal(t, tt.want, got, "displayWidth(%q, %d)", tt.s, tt.tabWidth)
 	}
 }
+
+func TestExpandTabs(t *testing.T) {
+	tests := []struct {
+		s    string
+		col  int
+		want string
+	}{
+		{"\t", 0, "    "},
+		{"\t", 3, " "},
+		{"\t", 4, "    "},
+		{"a\tb", 0, "a   b"},
+		{"no tabs", 2, "no tabs"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, expandTabs(tt.s, tt.col, 4), "expandTabs(%q, %d)", tt.s, tt.col)
+	}
+}
+
+func TestWrapText_ExpandTabs(t *testing.T) {
+	opts := Options{Column: 40, TabWidth: 4, ExpandTabs: true}
+	got := wrapText("key:\tvalue", "# ", "# ", opts)
+	// "# key:" ends at column 6, so the tab advances to column 8.
+	assert.Equal(t, []string{"# key:  value"}, got)
+
+	opts.ExpandTabs = false
+	got = wrapText("key:\tvalue", "# ", "# ", opts)
+	assert.Equal(t, []string{"# key:\tvalue"}, got)
+}
+
+func TestWrapText_PreserveSentenceStarts(t *testing.T) {
+	text := "First sentence.\nSecond sentence that\ngoes on (and on)\nThird, after a line without a period.\nwhy:\nlower case after a colon"
+	opts := Options{Column: 40, TabWidth: 4, PreserveSentenceStarts: true}
+	assert.Equal(t, []string{
+		"# First sentence.",
+		"# Second sentence that goes on (and on)",
+		"# Third, after a line without a period.",
+		"# why:",
+		"# lower case after a colon",
+	}, wrapText(text, "# ", "# ", opts))
+
+	// Without the option, the lines are joined.
+	opts.PreserveSentenceStarts = false
+	assert.Equal(t, []string{
+		"# First sentence. Second sentence that",
+		"# goes on (and on) Third, after a line",
+		"# without a period. why: lower case",
+		"# after a colon",
+	}, wrapText(text, "# ", "# ", opts))
+}
+
+func TestSentenceBreak(t *testing.T) {
+	assert.False(t, sentenceBreak("the", "end"))
+	assert.False(t, sentenceBreak("e.g,", "über"))
+	assert.True(t, sentenceBreak("end.", "next"))
+	assert.True(t, sentenceBreak(`"end?"`, "next"))
+	assert.True(t, sentenceBreak("(see below)", "Next"))
+	assert.True(t, sentenceBreak("the", "2nd"))
+}
+
+func TestWrapText_URLs(t *testing.T) {
+	opts := Options{Column: 30, TabWidth: 4}
+	// A line that is only a URL stays on its own line.
+	text := "See the docs at\nhttps://example.com/docs/wrapping\nfor details."
+	assert.Equal(t, []string{
+		"# See the docs at",
+		"# https://example.com/docs/wrapping",
+		"# for details.",
+	}, wrapText(text, "# ", "# ", opts))
+
+	// A URL in a sentence is joined like any other word, and overflows rather than being broken.
+	opts.BreakLongWords = true
+	text = "See https://example.com/docs/wrapping for details."
+	assert.Equal(t, []string{
+		"# See",
+		"# https://example.com/docs/wrapping",
+		"# for details.",
+	}, wrapText(text, "# ", "# ", opts))
+}
+
+func TestIsURL(t *testing.T) {
+	assert.True(t, isURL("https://example.com"))
+	assert.True(t, isURL("<http://example.com>"))
+	assert.True(t, isURL("(ftp://example.com/file)."))
+	assert.True(t, isURL("mailto:someone@example.com"))
+	assert.False(t, isURL("https://"))
+	assert.False(t, isURL("example.com"))
+}
+
+func TestWrapText_CodeSpans(t *testing.T) {
+	opts := Options{Column: 24, TabWidth: 4}
+	text := "Run it with `some --flag value` to see, or ``a ` b``. A stray ` is a character."
+	assert.Equal(t, []string{
+		"# Run it with",
+		"# `some --flag value` to",
+		"# see, or ``a ` b``. A",
+		"# stray ` is a",
+		"# character.",
+	}, wrapText(text, "# ", "# ", opts))
+}
+
+func TestCodeSpanEnd(t *testing.T) {
+	tests := []struct {
+		text string
+		want string // the word that starts text
+	}{
+		{"plain word", "plain"},
+		{"`one` two", "`one`"},
+		{"`one two` three", "`one two`"},
+		{"(`one two`), three", "(`one two`),"},
+		{"`a` and `b c`", "`a`"},
+		{"`a`b c` d", "`a`b"},
+		{"``a ` b`` c", "``a ` b``"},
+		{"`not closed", "`not"},
+		{"``not `closed` by one", "``not"},
+	}
+	for _, tt := range tests {
+		end := strings.IndexAny(tt.text, " ")
+		got := tt.text[:codeSpanEnd(tt.text, 0, end)]
+		assert.Equal(t, tt.want, got, "codeSpanEnd(%q)", tt.text)
+	}
+}
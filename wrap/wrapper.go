@@ -0,0 +1,120 @@
+package wrap
+
+// Wrapper rewraps source with a fixed language and set of options. Create one with New.
+type Wrapper struct {
+	lang *Language
+	opts Options
+}
+
+// Option configures a Wrapper.
+type Option func(*Wrapper)
+
+// New returns a Wrapper configured by opts. Without options, it wraps plain text at DefaultColumn
+// with DefaultTabWidth.
+func New(opts ...Option) *Wrapper {
+	w := new(Wrapper)
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Source rewraps src, as SourceWithOptions does.
+func (w *Wrapper) Source(src []byte) []byte {
+	return SourceWithOptions(src, w.lang, w.opts)
+}
+
+// WithLanguage sets the language of the source. A nil language, the default, means plain text.
+func WithLanguage(lang *Language) Option {
+	return func(w *Wrapper) { w.lang = lang }
+}
+
+// WithOptions replaces all options at once, such as those returned by Config.Apply.
+func WithOptions(opts Options) Option {
+	return func(w *Wrapper) { w.opts = opts }
+}
+
+// WithColumn sets Options.Column.
+func WithColumn(column int) Option {
+	return func(w *Wrapper) { w.opts.Column = column }
+}
+
+// WithTabWidth sets Options.TabWidth.
+func WithTabWidth(tabWidth int) Option {
+	return func(w *Wrapper) { w.opts.TabWidth = tabWidth }
+}
+
+// WithBullet sets Options.Bullet.
+func WithBullet(bullet string) Option {
+	return func(w *Wrapper) { w.opts.Bullet = bullet }
+}
+
+// WithExpandTabs sets Options.ExpandTabs.
+func WithExpandTabs(expand bool) Option {
+	return func(w *Wrapper) { w.opts.ExpandTabs = expand }
+}
+
+// WithKeepNarrow sets Options.KeepNarrow.
+func WithKeepNarrow(keep bool) Option {
+	return func(w *Wrapper) { w.opts.KeepNarrow = keep }
+}
+
+// WithMixedIndent sets Options.MixedIndent.
+func WithMixedIndent(mixed bool) Option {
+	return func(w *Wrapper) { w.opts.MixedIndent = mixed }
+}
+
+// WithFieldComments sets Options.FieldComments.
+func WithFieldComments(move bool) Option {
+	return func(w *Wrapper) { w.opts.FieldComments = move }
+}
+
+// WithRules sets Options.Rules.
+func WithRules(rules Rules) Option {
+	return func(w *Wrapper) { w.opts.Rules = rules }
+}
+
+// WithMinLines sets Options.MinLines.
+func WithMinLines(n int) Option {
+	return func(w *Wrapper) { w.opts.MinLines = n }
+}
+
+// WithPreserveSentenceStarts sets Options.PreserveSentenceStarts.
+func WithPreserveSentenceStarts(preserve bool) Option {
+	return func(w *Wrapper) { w.opts.PreserveSentenceStarts = preserve }
+}
+
+// WithLines sets Options.Lines.
+func WithLines(lines ...LineRange) Option {
+	return func(w *Wrapper) { w.opts.Lines = lines }
+}
+
+// WithPrefix sets Options.Prefix.
+func WithPrefix(prefix string) Option {
+	return func(w *Wrapper) { w.opts.Prefix = prefix }
+}
+
+// WithBreakLongWords sets Options.BreakLongWords.
+func WithBreakLongWords(brk bool) Option {
+	return func(w *Wrapper) { w.opts.BreakLongWords = brk }
+}
+
+// WithFormatTables sets Options.FormatTables.
+func WithFormatTables(format bool) Option {
+	return func(w *Wrapper) { w.opts.FormatTables = format }
+}
+
+// WithExtendUnderlines sets Options.ExtendUnderlines.
+func WithExtendUnderlines(extend bool) Option {
+	return func(w *Wrapper) { w.opts.ExtendUnderlines = extend }
+}
+
+// WithFrontMatterFields sets Options.FrontMatterFields.
+func WithFrontMatterFields(fields ...string) Option {
+	return func(w *Wrapper) { w.opts.FrontMatterFields = fields }
+}
+
+// WithLimits sets Options.Limits, such as to DefaultLimits for untrusted input.
+func WithLimits(limits Limits) Option {
+	return func(w *Wrapper) { w.opts.Limits = limits }
+}
@@ -0,0 +1,24 @@
+package wrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapper(t *testing.T) {
+	t.Parallel()
+
+	src := []byte("package a\n\n// one two three four five six\nvar a = 1\n")
+	w := New(WithLanguage(LanguageFromName("go")), WithColumn(20), WithTabWidth(8))
+	assert.Equal(t, string(Source(src, LanguageFromName("go"), 20, 8)), string(w.Source(src)))
+	assert.Equal(t, "package a\n\n// one two three\n// four five six\nvar a = 1\n", string(w.Source(src)))
+
+	// Without options, the source is plain text wrapped at DefaultColumn.
+	text := []byte("one\ntwo\n")
+	assert.Equal(t, "one two\n", string(New().Source(text)))
+
+	// Later options override earlier ones.
+	w = New(WithOptions(Options{Column: 10, Prefix: "> "}), WithColumn(12))
+	assert.Equal(t, "> one two\n> three\n", string(w.Source([]byte("> one two three\n"))))
+}
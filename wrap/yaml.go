@@ -0,0 +1,78 @@
+package wrap
+
+import "strings"
+
+// yamlMask is stringMask for YAML: it reports, for each line, whether the line is part of a scalar
+// rather than a comment, even if it starts with "#". Such lines are the content of block scalars
+// ("key: |" or "- >-" and the more-indented lines that follow) and the continuation lines of quoted
+// scalars that span several lines.
+func yamlMask(lines []string) []bool {
+	mask := make([]bool, len(lines))
+	block := -1    // indentation of the parent of the block scalar we are in, or -1
+	var quote byte // quote of the multi-line scalar we are in, if any
+	for n, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if block >= 0 {
+			if strings.TrimSpace(line) == "" || indent > block {
+				mask[n] = true
+				continue
+			}
+			block = -1
+		}
+		i := 0
+		if quote != 0 {
+			mask[n] = true
+			if i = yamlQuoteEnd(line, 0, quote); i < 0 {
+				continue
+			}
+			quote = 0
+		}
+		start := true // a scalar may start at i
+		for ; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+				i = len(line) // a comment
+			case (c == '"' || c == '\'') && start:
+				if end := yamlQuoteEnd(line, i+1, c); end >= 0 {
+					i = end - 1
+				} else {
+					quote = c
+					i = len(line)
+				}
+				start = false
+			case (c == '|' || c == '>') && start:
+				if yamlBlockHeaderPattern.MatchString(line[i:]) {
+					block = indent
+					i = len(line)
+				}
+				start = false
+			case c == ' ' || c == '\t':
+			case c == '-' || c == '?' || c == ':' || c == '[' || c == '{' || c == ',':
+				// A scalar may follow an indicator, such as "- ", "key: " or "[".
+				start = c == '[' || c == '{' || c == ',' || i+1 == len(line) || line[i+1] == ' '
+			default:
+				start = false
+			}
+		}
+	}
+	return mask
+}
+
+// yamlQuoteEnd returns the index after the quote that closes a scalar quoted with quote, searching
+// line from i, or -1 if the scalar does not end on this line. Double-quoted scalars have backslash
+// escapes; in single-quoted ones, a quote is written as two.
+func yamlQuoteEnd(line string, i int, quote byte) int {
+	for ; i < len(line); i++ {
+		switch {
+		case quote == '"' && line[i] == '\\':
+			i++
+		case line[i] == quote && quote == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			i++
+		case line[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}